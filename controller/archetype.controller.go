@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"carbon-service/service"
+	"net/http"
+	"strconv"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type archetypeController struct {
+	archetypeService service.ArchetypeService
+}
+
+// NewArchetypeController sets up routes and handlers for building archetypes.
+func NewArchetypeController(router *gin.Engine, as service.ArchetypeService) {
+	ac := &archetypeController{
+		archetypeService: as,
+	}
+
+	router.POST("/archetypes", ac.createArchetype)
+	router.GET("/archetypes", ac.getArchetypes)
+	router.GET("/archetypes/:id", ac.getArchetype)
+	router.POST("/buildings/from-archetype", ac.createBuilding)
+}
+
+// createArchetype stores a new archetype with its default parameters and assemblies.
+// endpoint: POST /archetypes
+func (ac *archetypeController) createArchetype(ctx *gin.Context) {
+	var req service.CreateArchetypeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	archetype, err := ac.archetypeService.CreateArchetype(req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, archetype)
+}
+
+// getArchetypes fetches all archetypes.
+// endpoint: GET /archetypes
+func (ac *archetypeController) getArchetypes(ctx *gin.Context) {
+	archetypes, err := ac.archetypeService.GetAllArchetypes()
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, "Error fetching archetypes")
+		return
+	}
+	ctx.JSON(http.StatusOK, archetypes)
+}
+
+// getArchetype fetches an archetype with its default assemblies by its ID.
+// endpoint: GET /archetypes/:id
+func (ac *archetypeController) getArchetype(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	archetype, err := ac.archetypeService.GetArchetype(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusNotFound, "Archetype not found")
+		return
+	}
+	ctx.JSON(http.StatusOK, archetype)
+}
+
+// createBuilding instantiates a building from an archetype and a few inputs.
+// endpoint: POST /buildings/from-archetype
+func (ac *archetypeController) createBuilding(ctx *gin.Context) {
+	var req service.BuildingFromArchetypeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	building, err := ac.archetypeService.CreateBuildingFromArchetype(req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithBuilding(ctx, http.StatusCreated, building)
+}
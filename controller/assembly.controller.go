@@ -2,6 +2,7 @@ package controller
 
 import (
 	"carbon-service/service"
+	"carbon-service/service/converter"
 	"net/http"
 	"strconv"
 
@@ -25,19 +26,30 @@ func NewAssemblyController(router *gin.Engine, as service.AssemblyService, cs se
 	router.POST("/assemblies", ac.createAssembly)
 	router.GET("/assemblies/:id", ac.getAssembly)
 	router.GET("/assemblies", ac.getAssemblies)
+	router.GET("/assemblies/templates", ac.getTemplates)
+	router.PUT("/assemblies/:id/template", ac.setTemplate)
+	router.POST("/assemblies/:id/clone", ac.cloneAssembly)
+	router.GET("/assemblies/:id/clones", ac.getClones)
 	router.GET("/assemblies/:id/total-carbon", ac.getTotalCarbon)
+	router.GET("/assemblies/:id/carbon-per-m2", ac.getCarbonPerArea)
+	router.PUT("/assemblies/:id/layers", ac.setLayers)
+	router.POST("/assemblies/:id/materials", ac.addMaterial)
+	router.PUT("/assemblies/:id/materials", ac.reorderMaterials)
+	router.PUT("/assemblies/:id/materials/:materialId", ac.updateMaterialQuantity)
+	router.DELETE("/assemblies/:id/materials/:materialId", ac.removeMaterial)
+	router.POST("/assemblies/:id/components", ac.addComponent)
+	router.PUT("/assemblies/:id/components/:childId", ac.updateComponentQuantity)
+	router.DELETE("/assemblies/:id/components/:childId", ac.removeComponent)
 }
 
 func (ac *assemblyController) createAssembly(ctx *gin.Context) {
-	var req struct {
-		Name string `json:"name" binding:"required"`
-	}
+	var req service.CreateAssemblyRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	assembly, err := ac.assemblyService.CreateAssembly(req.Name)
+	assembly, err := ac.assemblyService.CreateAssembly(req)
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
 		return
@@ -68,16 +80,334 @@ func (ac *assemblyController) getAssemblies(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, assemblies)
 }
 
+// getTotalCarbon fetches the whole life carbon of one unit of an assembly in an absolute
+// output unit. With detailed=true the response includes the breakdown of the
+// result in kgCO2e down to the EPDs.
+// endpoint: GET /assemblies/:id/total-carbon?unit=tCO2e&detailed=true
 func (ac *assemblyController) getTotalCarbon(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
+	unit := converter.OutputUnit(ctx.Query("unit"))
+	if unit == "" {
+		system, ok := requestUnit(ctx)
+		if !ok {
+			return
+		}
+		unit = converter.OutputUnit(system.Carbon)
+	}
+	scale, err := unit.Scale()
+	if err != nil || scale.PerArea() {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid unit")
+		return
+	}
+	detailed, ok := parseDetailed(ctx)
+	if !ok {
+		return
+	}
+	if detailed {
+		breakdown, err := ac.assemblyService.ComputeBreakdown(uint(id))
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"total_carbon": scale.Convert(breakdown.Subtotal, false), "unit": scale.Carbon, "breakdown": breakdown})
+		return
+	}
 	carbon, err := ac.assemblyService.ComputeTotalCarbon(uint(id))
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"total_carbon": carbon})
+	ctx.JSON(http.StatusOK, gin.H{"total_carbon": scale.Convert(carbon, false), "unit": scale.Carbon})
+}
+
+// getCarbonPerArea fetches the whole life carbon of one m2 of an assembly's
+// layers, in a per area output unit that defaults to the carbon per area unit
+// of the request's unit system.
+// endpoint: GET /assemblies/:id/carbon-per-m2?unit=lbCO2e/ft2
+func (ac *assemblyController) getCarbonPerArea(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	unit := converter.OutputUnit(ctx.Query("unit"))
+	if unit == "" {
+		system, ok := requestUnit(ctx)
+		if !ok {
+			return
+		}
+		unit = converter.OutputUnit(system.CarbonPerArea)
+	}
+	scale, err := unit.Scale()
+	if err != nil || !scale.PerArea() {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid unit")
+		return
+	}
+	carbon, err := ac.assemblyService.ComputeCarbonPerArea(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"carbon_per_m2": scale.Convert(carbon, true), "unit": unit})
+}
+
+// setLayers replaces the layers of an assembly with the given ordered list.
+// endpoint: PUT /assemblies/:id/layers
+func (ac *assemblyController) setLayers(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req struct {
+		Layers []service.LayerRequest `json:"layers" binding:"dive"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.SetLayers(uint(id), req.Layers)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
+}
+
+// addMaterial adds a material with a quantity to an assembly.
+// endpoint: POST /assemblies/:id/materials
+func (ac *assemblyController) addMaterial(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req service.AddMaterialRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.AddMaterial(uint(id), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, assembly)
+}
+
+// reorderMaterials sets the order of the materials of an assembly.
+// endpoint: PUT /assemblies/:id/materials
+func (ac *assemblyController) reorderMaterials(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req struct {
+		MaterialIDs []uint `json:"materialIds" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.ReorderMaterials(uint(id), req.MaterialIDs)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
+}
+
+// updateMaterialQuantity changes the quantity of a material in an assembly.
+// endpoint: PUT /assemblies/:id/materials/:materialId
+func (ac *assemblyController) updateMaterialQuantity(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	materialID, err := strconv.ParseUint(ctx.Param("materialId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid material ID format")
+		return
+	}
+	var req service.MaterialQuantityRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.UpdateMaterialQuantity(uint(id), uint(materialID), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
+}
+
+// removeMaterial removes a material from an assembly.
+// endpoint: DELETE /assemblies/:id/materials/:materialId
+func (ac *assemblyController) removeMaterial(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	materialID, err := strconv.ParseUint(ctx.Param("materialId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid material ID format")
+		return
+	}
+	assembly, err := ac.assemblyService.RemoveMaterial(uint(id), uint(materialID))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
+}
+
+// getTemplates fetches all assemblies in the template library.
+// endpoint: GET /assemblies/templates
+func (ac *assemblyController) getTemplates(ctx *gin.Context) {
+	templates, err := ac.assemblyService.GetTemplates()
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, templates)
+}
+
+// setTemplate marks or unmarks an assembly as a library template.
+// endpoint: PUT /assemblies/:id/template
+func (ac *assemblyController) setTemplate(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req struct {
+		IsTemplate bool `json:"isTemplate"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.SetTemplate(uint(id), req.IsTemplate)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
+}
+
+// cloneAssembly deep-copies a template into a new project assembly.
+// endpoint: POST /assemblies/:id/clone
+func (ac *assemblyController) cloneAssembly(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.CloneAssembly(uint(id), req.Name)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, assembly)
+}
+
+// getClones fetches the assemblies cloned from a template and whether they drifted from it.
+// endpoint: GET /assemblies/:id/clones
+func (ac *assemblyController) getClones(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	clones, err := ac.assemblyService.GetClones(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, clones)
+}
+
+// addComponent adds a sub-assembly with a quantity to an assembly.
+// endpoint: POST /assemblies/:id/components
+func (ac *assemblyController) addComponent(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req service.AddComponentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.AddComponent(uint(id), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, assembly)
+}
+
+// updateComponentQuantity changes the quantity of a sub-assembly in an assembly.
+// endpoint: PUT /assemblies/:id/components/:childId
+func (ac *assemblyController) updateComponentQuantity(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	childID, err := strconv.ParseUint(ctx.Param("childId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid sub-assembly ID format")
+		return
+	}
+	var req struct {
+		Quantity float64 `json:"quantity" binding:"required,gt=0"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	assembly, err := ac.assemblyService.UpdateComponentQuantity(uint(id), uint(childID), req.Quantity)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
+}
+
+// removeComponent removes a sub-assembly from an assembly.
+// endpoint: DELETE /assemblies/:id/components/:childId
+func (ac *assemblyController) removeComponent(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	childID, err := strconv.ParseUint(ctx.Param("childId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid sub-assembly ID format")
+		return
+	}
+	assembly, err := ac.assemblyService.RemoveComponent(uint(id), uint(childID))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, assembly)
 }
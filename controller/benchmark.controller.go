@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"carbon-service/service"
+	"net/http"
+	"strconv"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type benchmarkController struct {
+	benchmarkService service.BenchmarkService
+}
+
+// NewBenchmarkController sets up routes and handlers for benchmark sets and building ratings.
+func NewBenchmarkController(router *gin.Engine, bms service.BenchmarkService) {
+	bc := &benchmarkController{
+		benchmarkService: bms,
+	}
+
+	router.POST("/benchmarks", bc.createBenchmarkSet)
+	router.GET("/benchmarks", bc.getBenchmarkSets)
+	router.GET("/buildings/:id/calculation/benchmarks", bc.rateBuilding)
+}
+
+// createBenchmarkSet stores a new benchmark set.
+// endpoint: POST /benchmarks
+func (bc *benchmarkController) createBenchmarkSet(ctx *gin.Context) {
+	var req service.CreateBenchmarkSetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	set, err := bc.benchmarkService.CreateBenchmarkSet(req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, set)
+}
+
+// getBenchmarkSets fetches all benchmark sets.
+// endpoint: GET /benchmarks
+func (bc *benchmarkController) getBenchmarkSets(ctx *gin.Context) {
+	sets, err := bc.benchmarkService.GetBenchmarkSets()
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, "Error fetching benchmark sets")
+		return
+	}
+	ctx.JSON(http.StatusOK, sets)
+}
+
+// rateBuilding rates a building against the benchmark sets for its typology,
+// optionally only against the set named in the set query parameter, by a
+// methodology, the building's own by default, in the unit system of the request.
+// endpoint: GET /buildings/:id/calculation/benchmarks?set=LETI&methodology=rics-ps-2023
+func (bc *benchmarkController) rateBuilding(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	methodology, ok := parseMethodology(ctx)
+	if !ok {
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	comparison, err := bc.benchmarkService.RateBuilding(uint(id), ctx.Query("set"), methodology)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err := comparison.Present(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, comparison)
+}
@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"carbon-service/model"
 	"carbon-service/service"
+	"carbon-service/service/converter"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -28,6 +31,10 @@ func NewBuildingController(router *gin.Engine, bs service.BuildingService, cs se
 	router.GET("/buildings", bc.getBuildings)
 	router.GET("/buildings/:id/calculation/total-carbon", bc.getTotalCarbon)
 	router.GET("/buildings/:id/calculation/embodied-carbon", bc.getEmbodiedCarbon)
+	router.GET("/buildings/:id/calculation/substructure", bc.getSubstructure)
+	router.GET("/buildings/:id/calculation/hotspots", bc.getHotspots)
+	router.PUT("/buildings/:id/assemblies/:assemblyId", bc.setAssemblyElement)
+	router.GET("/methodologies", bc.getMethodologies)
 }
 
 // createBuilding handles the creation of a new building with the provided data.
@@ -39,13 +46,21 @@ func (bc *buildingController) createBuilding(ctx *gin.Context) {
 		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	building, err := bc.buildingService.CreateBuilding(req)
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
 		return
 	}
-	ctx.JSON(http.StatusCreated, building)
+	respondWithBuilding(ctx, http.StatusCreated, building)
 }
 
 // getBuilding fetches a building by its ID.
@@ -61,7 +76,7 @@ func (bc *buildingController) getBuilding(ctx *gin.Context) {
 		helpers.RespondWithError(ctx, http.StatusNotFound, "Building not found")
 		return
 	}
-	ctx.JSON(http.StatusOK, building)
+	respondWithBuilding(ctx, http.StatusOK, building)
 }
 
 // getBuildings fetches all buildings stored in the system.
@@ -72,39 +87,161 @@ func (bc *buildingController) getBuildings(ctx *gin.Context) {
 		helpers.RespondWithError(ctx, http.StatusInternalServerError, "Error fetching buildings")
 		return
 	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	for i := range buildings {
+		b, err := service.BuildingInUnit(&buildings[i], unit)
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		buildings[i] = *b
+	}
 	ctx.JSON(http.StatusOK, buildings)
 }
 
-// getTotalCarbon fetches the total carbon impact of a building by its ID.
-// endpoint: GET /buildings/:id/calculation/total-carbon
+// getTotalCarbon fetches the total carbon impact of a building by its ID,
+// optionally normalised by one of the service.Normalisation denominators,
+// presented in an output unit and calculated by a methodology, see
+// parseOutput. With detailed=true the response includes the breakdown of the
+// absolute result down to the EPDs. The result is stored as a calculation
+// run, and the run of an earlier request is returned if no input changed.
+// endpoint: GET /buildings/:id/calculation/total-carbon?normalisation=per-m2-gfa&unit=tCO2e/m2&methodology=rics-ps-2023&detailed=true
 func (bc *buildingController) getTotalCarbon(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
-	totalCarbon, err := bc.buildingService.ComputeTotalCarbon(uint(id))
+	opts, unit, ok := parseOutput(ctx)
+	if !ok {
+		return
+	}
+	totalCarbon, err := bc.buildingService.ComputeTotalCarbon(uint(id), opts)
 	if err != nil {
-		helpers.RespondWithError(ctx, http.StatusNotFound, "Building not found or calculation error")
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := totalCarbon.Present(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"totalCarbon": totalCarbon})
+	response := gin.H{"totalCarbon": totalCarbon.Total, "byElement": totalCarbon.ByElement, "byModule": totalCarbon.ByModule, "normalisation": totalCarbon.Normalisation, "unit": totalCarbon.Unit, "methodology": totalCarbon.Methodology, "runId": totalCarbon.RunID, "calculatedAt": totalCarbon.CalculatedAt, "cached": totalCarbon.Cached}
+	if opts.Detailed {
+		response["breakdown"] = totalCarbon.Breakdown
+	}
+	ctx.JSON(http.StatusOK, response)
 }
 
-// getEmbodiedCarbon fetches the embodied carbon of a building by its ID.
-// endpoint: GET /buildings/:id/calculation/embodied-carbon
+// getEmbodiedCarbon fetches the embodied carbon of a building by its ID,
+// optionally normalised by one of the service.Normalisation denominators,
+// presented in an output unit and calculated by a methodology, see parseOutput.
+// With detailed=true the response includes the breakdown of the absolute
+// estimate down to the rates and substructure factors it was estimated with.
+// endpoint: GET /buildings/:id/calculation/embodied-carbon?normalisation=per-m2-gfa&unit=tCO2e/m2&methodology=rics-ps-2023&detailed=true
 func (bc *buildingController) getEmbodiedCarbon(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
-	embodiedCarbon, err := bc.buildingService.ComputeEmbodiedCarbon(uint(id))
+	opts, unit, ok := parseOutput(ctx)
+	if !ok {
+		return
+	}
+	embodiedCarbon, err := bc.buildingService.ComputeEmbodiedCarbon(uint(id), opts)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := embodiedCarbon.Present(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	response := gin.H{"embodiedCarbon": embodiedCarbon.Total, "byElement": embodiedCarbon.ByElement, "byModule": embodiedCarbon.ByModule, "normalisation": embodiedCarbon.Normalisation, "unit": embodiedCarbon.Unit, "methodology": embodiedCarbon.Methodology}
+	if opts.Detailed {
+		response["breakdown"] = embodiedCarbon.Breakdown
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getSubstructure fetches the estimated substructure of a building by its ID,
+// optionally normalised by one of the service.Normalisation denominators and
+// presented in an output unit, see parseNormalisedOutput.
+// endpoint: GET /buildings/:id/calculation/substructure?normalisation=per-m2-gfa&unit=kgCO2e/m2
+func (bc *buildingController) getSubstructure(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	normalisation, output, ok := parseNormalisedOutput(ctx)
+	if !ok {
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	estimate, err := bc.buildingService.EstimateSubstructure(uint(id), normalisation)
 	if err != nil {
-		helpers.RespondWithError(ctx, http.StatusNotFound, "Building not found or calculation error")
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"embodiedCarbon": embodiedCarbon})
+	if err := estimate.Present(output, unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, estimate)
+}
+
+// getHotspots ranks what contributes most to the carbon of a building over a
+// module scope, the methodology's by default, and flags the Pareto
+// contributors. Carbon is calculated by the methodology, the building's own
+// by default, and presented in an absolute output unit, the carbon unit of the
+// request's unit system by default.
+// endpoint: GET /buildings/:id/calculation/hotspots?scope=A1-A5&methodology=rics-ps-2023&unit=tCO2e
+func (bc *buildingController) getHotspots(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	scope := model.ModuleScope(ctx.Query("scope"))
+	if scope != "" {
+		if _, err := scope.Modules(); err != nil {
+			helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid module scope")
+			return
+		}
+	}
+	unit := converter.OutputUnit(ctx.Query("unit"))
+	if unit == "" {
+		system, ok := requestUnit(ctx)
+		if !ok {
+			return
+		}
+		unit = converter.OutputUnit(system.Carbon)
+	}
+	if scale, err := unit.Scale(); err != nil || scale.PerArea() {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid unit")
+		return
+	}
+	methodology, ok := parseMethodology(ctx)
+	if !ok {
+		return
+	}
+	analysis, err := bc.buildingService.ComputeHotspots(uint(id), scope, methodology)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := analysis.Present(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, analysis)
 }
 
 // put endpoint: PUT /buildings/:id
@@ -119,10 +256,149 @@ func (bc *buildingController) updateBuilding(ctx *gin.Context) {
 		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
 	building, err := bc.buildingService.UpdateBuilding(uint(id), req)
 	if err != nil {
 		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
 		return
 	}
-	ctx.JSON(http.StatusOK, building)
+	respondWithBuilding(ctx, http.StatusOK, building)
+}
+
+// setAssemblyElement tags an assembly used on a building with its building
+// element, and sets its geometric role or quantity.
+// endpoint: PUT /buildings/:id/assemblies/:assemblyId
+func (bc *buildingController) setAssemblyElement(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	assemblyID, err := strconv.ParseUint(ctx.Param("assemblyId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid assembly ID format")
+		return
+	}
+	var req service.AssignAssemblyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	building, err := bc.buildingService.AssignAssembly(uint(id), uint(assemblyID), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithBuilding(ctx, http.StatusOK, building)
+}
+
+// getMethodologies lists the methodologies buildings can be calculated by.
+// endpoint: GET /methodologies
+func (bc *buildingController) getMethodologies(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, service.Methodologies())
+}
+
+// parseOutput parses the normalisation, output unit, methodology and detail
+// of a calculation, responding with an error if any is invalid, see
+// parseNormalisedOutput. Without a methodology the building's own is used.
+func parseOutput(ctx *gin.Context) (service.CalculationOptions, converter.OutputUnit, bool) {
+	methodology, ok := parseMethodology(ctx)
+	opts := service.CalculationOptions{Methodology: methodology}
+	if !ok {
+		return opts, "", false
+	}
+	detailed, ok := parseDetailed(ctx)
+	if !ok {
+		return opts, "", false
+	}
+	opts.Detailed = detailed
+	normalisation, unit, ok := parseNormalisedOutput(ctx)
+	opts.Normalisation = normalisation
+	return opts, unit, ok
+}
+
+// parseNormalisedOutput parses the normalisation and output unit of a result,
+// responding with an error if either is invalid. A per area unit on its own
+// normalises the result per m2 GFA, and without a unit results are presented
+// in the unit system of the request.
+func parseNormalisedOutput(ctx *gin.Context) (service.Normalisation, converter.OutputUnit, bool) {
+	normalisation, ok := parseNormalisation(ctx)
+	if !ok {
+		return "", "", false
+	}
+	unit := converter.OutputUnit(ctx.Query("unit"))
+	if unit == "" {
+		system, ok := requestUnit(ctx)
+		if !ok {
+			return "", "", false
+		}
+		return normalisation, service.OutputUnitFor(system, normalisation), true
+	}
+	scale, err := unit.Scale()
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid unit")
+		return "", "", false
+	}
+	if scale.PerArea() && normalisation == "" {
+		normalisation = service.NormalisePerGFA
+	}
+	return normalisation, unit, true
+}
+
+// parseMethodology parses the methodology a result is calculated by,
+// responding with an error if it is unknown. It is empty when the building's
+// own methodology is used.
+func parseMethodology(ctx *gin.Context) (string, bool) {
+	methodology := ctx.Query("methodology")
+	if methodology != "" {
+		if _, err := service.FindMethodology(methodology); err != nil {
+			helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid methodology")
+			return "", false
+		}
+	}
+	return methodology, true
+}
+
+// parseDetailed parses whether a calculation asks for its breakdown with
+// detailed=true, responding with an error if the flag is invalid.
+func parseDetailed(ctx *gin.Context) (bool, bool) {
+	param := ctx.Query("detailed")
+	if param == "" {
+		return false, true
+	}
+	detailed, err := strconv.ParseBool(param)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid detailed flag")
+		return false, false
+	}
+	return detailed, true
+}
+
+// parseNormalisation parses the normalisation of a calculation, responding
+// with an error if it is invalid. Without one results are absolute.
+func parseNormalisation(ctx *gin.Context) (service.Normalisation, bool) {
+	normalisation := service.Normalisation(ctx.Query("normalisation"))
+	if !normalisation.IsValid() {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid normalisation")
+		return "", false
+	}
+	return normalisation, true
+}
+
+// calculationErrorStatus returns the status code of a failed calculation:
+// unprocessable when the building has no value to normalise by or its
+// estimate cannot be reported under the methodology, not found when the
+// building does not exist, an internal server error otherwise.
+func calculationErrorStatus(err error) int {
+	if errors.Is(err, service.ErrNoDenominator) || errors.Is(err, service.ErrPartialScope) {
+		return http.StatusUnprocessableEntity
+	}
+	return helpers.ErrorStatus(err)
 }
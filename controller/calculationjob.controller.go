@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"carbon-service/model"
+	"carbon-service/service"
+	"carbon-service/service/converter"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type calculationJobController struct {
+	jobService service.CalculationJobService
+}
+
+// NewCalculationJobController sets up routes and handlers for calculations run in the background.
+func NewCalculationJobController(router *gin.Engine, js service.CalculationJobService) {
+	jc := &calculationJobController{
+		jobService: js,
+	}
+
+	router.POST("/calculations", jc.submitJob)
+	router.GET("/calculations/stream", jc.streamCalculation)
+	router.GET("/calculations/:id", jc.getJob)
+	router.POST("/calculations/:id/cancel", jc.cancelJob)
+}
+
+// submitJob queues a building, portfolio or scenario calculation and responds
+// with the job to poll for its result. Results are presented in the unit
+// system of the request unless the payload sets a unit.
+// endpoint: POST /calculations
+func (jc *calculationJobController) submitJob(ctx *gin.Context) {
+	var req service.CalculationJobRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Unit == "" {
+		unit, ok := requestUnit(ctx)
+		if !ok {
+			return
+		}
+		req.Unit = service.OutputUnitFor(unit, req.Normalisation)
+	}
+	job, err := jc.jobService.SubmitJob(req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// getJob fetches the status, progress and result of a calculation job.
+// endpoint: GET /calculations/:id
+func (jc *calculationJobController) getJob(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	job, err := jc.jobService.GetJob(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusNotFound, "Calculation job not found")
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// cancelJob cancels a queued or running calculation job.
+// endpoint: POST /calculations/:id/cancel
+func (jc *calculationJobController) cancelJob(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	job, err := jc.jobService.CancelJob(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusConflict, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// calculationEvent is a server-sent event of a streamed calculation.
+type calculationEvent struct {
+	name string
+	data interface{}
+}
+
+// streamCalculation runs a building, portfolio or scenario calculation and
+// streams its progress as server-sent events: a progress event after every
+// entity with the partial total and any warnings about it, a warning event
+// about the whole result, then a result event with the same result a
+// calculation job has, or an error event. A portfolio reports every building,
+// a building or scenario calculation is one entity reported once calculated.
+// Nothing more is sent once the client disconnects, and a portfolio stops
+// before its next building. The kind defaults to portfolio when buildingIds
+// are given and to building otherwise.
+// endpoint: GET /calculations/stream?kind=portfolio&buildingIds=1,2&normalisation=per-m2-gfa&methodology=rics-ps-2023&unit=kgCO2e/m2
+func (jc *calculationJobController) streamCalculation(ctx *gin.Context) {
+	req := service.CalculationJobRequest{
+		Kind:          model.JobKind(ctx.Query("kind")),
+		Normalisation: service.Normalisation(ctx.Query("normalisation")),
+		Methodology:   ctx.Query("methodology"),
+		Unit:          converter.OutputUnit(ctx.Query("unit")),
+	}
+	var err error
+	if req.BuildingIDs, err = parseIDList(ctx.Query("buildingIds")); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid building ID format")
+		return
+	}
+	if req.ScenarioIDs, err = parseIDList(ctx.Query("scenarioIds")); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid scenario ID format")
+		return
+	}
+	if param := ctx.Query("buildingId"); param != "" {
+		buildingID, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+			return
+		}
+		req.BuildingID = uint(buildingID)
+	}
+	if req.Kind == "" && len(req.BuildingIDs) > 0 {
+		req.Kind = model.JobPortfolio
+	} else if req.Kind == "" {
+		req.Kind = model.JobBuilding
+	}
+	if req.Unit == "" {
+		unit, ok := requestUnit(ctx)
+		if !ok {
+			return
+		}
+		req.Unit = service.OutputUnitFor(unit, req.Normalisation)
+	}
+	if err := req.Validate(); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	// calculate in the background and stream its events as they come, until
+	// the client disconnects
+	events := make(chan calculationEvent)
+	calculation := ctx.Request.Context()
+	send := func(event calculationEvent) {
+		select {
+		case events <- event:
+		case <-calculation.Done():
+		}
+	}
+	go func() {
+		defer close(events)
+		result, err := jc.jobService.Calculate(calculation, req, func(progress service.CalculationProgress) {
+			send(calculationEvent{name: "progress", data: progress})
+		}, func(warning string) {
+			send(calculationEvent{name: "warning", data: gin.H{"warning": warning}})
+		})
+		if err != nil {
+			send(calculationEvent{name: "error", data: gin.H{"error": err.Error()}})
+			return
+		}
+		send(calculationEvent{name: "result", data: result})
+	}()
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			ctx.SSEvent(event.name, event.data)
+			ctx.Writer.Flush()
+		case <-calculation.Done():
+			return
+		}
+	}
+}
+
+// parseIDList parses comma separated IDs, an empty list is no IDs.
+func parseIDList(param string) ([]uint, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var ids []uint
+	for _, value := range strings.Split(param, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
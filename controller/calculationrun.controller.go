@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"carbon-service/service"
+	"net/http"
+	"strconv"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type calculationRunController struct {
+	runService service.CalculationRunService
+}
+
+// NewCalculationRunController sets up routes and handlers for the persisted calculation runs of buildings.
+func NewCalculationRunController(router *gin.Engine, rs service.CalculationRunService) {
+	rc := &calculationRunController{
+		runService: rs,
+	}
+
+	router.GET("/buildings/:id/calculation/runs", rc.getRuns)
+	router.GET("/buildings/:id/calculation/runs/diff", rc.diffRuns)
+	router.GET("/buildings/:id/calculation/runs/:runId", rc.getRun)
+}
+
+// getRuns fetches the results of a building's calculation runs in the order they were calculated.
+// endpoint: GET /buildings/:id/calculation/runs
+func (rc *calculationRunController) getRuns(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	runs, err := rc.runService.GetRuns(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, runs)
+}
+
+// getRun fetches the absolute result of a calculation run of a building with its breakdown.
+// endpoint: GET /buildings/:id/calculation/runs/:runId
+func (rc *calculationRunController) getRun(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	runID, err := strconv.ParseUint(ctx.Param("runId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid run ID format")
+		return
+	}
+	run, err := rc.runService.GetRun(uint(id), uint(runID))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusNotFound, "Calculation run not found")
+		return
+	}
+	ctx.JSON(http.StatusOK, run)
+}
+
+// diffRuns compares two calculation runs of a building.
+// endpoint: GET /buildings/:id/calculation/runs/diff?from=1&to=2
+func (rc *calculationRunController) diffRuns(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	fromID, err := strconv.ParseUint(ctx.Query("from"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid from run ID format")
+		return
+	}
+	toID, err := strconv.ParseUint(ctx.Query("to"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid to run ID format")
+		return
+	}
+	diff, err := rc.runService.DiffRuns(uint(id), uint(fromID), uint(toID))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"carbon-service/service"
+	"net/http"
+	"strconv"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type floorController struct {
+	floorService service.FloorService
+}
+
+// NewFloorController sets up routes and handlers for the floors and zones of buildings.
+func NewFloorController(router *gin.Engine, fs service.FloorService) {
+	fc := &floorController{
+		floorService: fs,
+	}
+
+	router.POST("/buildings/:id/floors", fc.addFloor)
+	router.GET("/buildings/:id/floors", fc.getFloors)
+	router.PUT("/buildings/:id/floors/:floorId", fc.updateFloor)
+	router.DELETE("/buildings/:id/floors/:floorId", fc.removeFloor)
+	router.POST("/buildings/:id/floors/:floorId/zones", fc.addZone)
+	router.DELETE("/buildings/:id/floors/:floorId/zones/:zoneId", fc.removeZone)
+	router.POST("/buildings/:id/floors/:floorId/assemblies", fc.assignAssembly)
+	router.DELETE("/buildings/:id/floors/:floorId/assemblies/:linkId", fc.removeAssembly)
+	router.GET("/buildings/:id/calculation/by-floor", fc.getCarbonByFloor)
+	router.GET("/buildings/:id/calculation/by-use", fc.getCarbonByUse)
+}
+
+// addFloor adds a floor to a building.
+// endpoint: POST /buildings/:id/floors
+func (fc *floorController) addFloor(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req service.FloorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	floor, err := fc.floorService.AddFloor(uint(id), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithFloor(ctx, http.StatusCreated, floor)
+}
+
+// getFloors fetches the floors of a building with their zones and assemblies.
+// endpoint: GET /buildings/:id/floors
+func (fc *floorController) getFloors(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	floors, err := fc.floorService.GetFloors(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	for i := range floors {
+		f, err := service.FloorInUnit(&floors[i], unit)
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		floors[i] = *f
+	}
+	ctx.JSON(http.StatusOK, floors)
+}
+
+// updateFloor replaces the name, level, area, height and use of a floor.
+// endpoint: PUT /buildings/:id/floors/:floorId
+func (fc *floorController) updateFloor(ctx *gin.Context) {
+	id, floorID, ok := parseFloorIDs(ctx)
+	if !ok {
+		return
+	}
+	var req service.FloorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	floor, err := fc.floorService.UpdateFloor(id, floorID, req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithFloor(ctx, http.StatusOK, floor)
+}
+
+// removeFloor removes a floor with its zones and assemblies from a building.
+// endpoint: DELETE /buildings/:id/floors/:floorId
+func (fc *floorController) removeFloor(ctx *gin.Context) {
+	id, floorID, ok := parseFloorIDs(ctx)
+	if !ok {
+		return
+	}
+	if err := fc.floorService.RemoveFloor(id, floorID); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// addZone adds a zone with its own use to a floor.
+// endpoint: POST /buildings/:id/floors/:floorId/zones
+func (fc *floorController) addZone(ctx *gin.Context) {
+	id, floorID, ok := parseFloorIDs(ctx)
+	if !ok {
+		return
+	}
+	var req service.ZoneRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	zone, err := fc.floorService.AddZone(id, floorID, req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	z, err := service.ZoneInUnit(zone, unit)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, z)
+}
+
+// removeZone removes a zone and the assemblies assigned to it from a floor.
+// endpoint: DELETE /buildings/:id/floors/:floorId/zones/:zoneId
+func (fc *floorController) removeZone(ctx *gin.Context) {
+	id, floorID, ok := parseFloorIDs(ctx)
+	if !ok {
+		return
+	}
+	zoneID, err := strconv.ParseUint(ctx.Param("zoneId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid zone ID format")
+		return
+	}
+	if err := fc.floorService.RemoveZone(id, floorID, uint(zoneID)); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// assignAssembly assigns an assembly to a floor or to one of its zones.
+// endpoint: POST /buildings/:id/floors/:floorId/assemblies
+func (fc *floorController) assignAssembly(ctx *gin.Context) {
+	id, floorID, ok := parseFloorIDs(ctx)
+	if !ok {
+		return
+	}
+	var req service.FloorAssemblyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	link, err := fc.floorService.AssignAssembly(id, floorID, req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, link)
+}
+
+// removeAssembly removes an assembly assignment from a floor.
+// endpoint: DELETE /buildings/:id/floors/:floorId/assemblies/:linkId
+func (fc *floorController) removeAssembly(ctx *gin.Context) {
+	id, floorID, ok := parseFloorIDs(ctx)
+	if !ok {
+		return
+	}
+	linkID, err := strconv.ParseUint(ctx.Param("linkId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid assignment ID format")
+		return
+	}
+	if err := fc.floorService.RemoveAssembly(id, floorID, uint(linkID)); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// getCarbonByFloor fetches the carbon of each floor of a building by a
+// methodology, the building's own by default, optionally normalised by one of
+// the service.Normalisation denominators and presented in an output unit, see
+// parseNormalisedOutput.
+// endpoint: GET /buildings/:id/calculation/by-floor?normalisation=per-m2-gfa&unit=kgCO2e/m2&methodology=rics-ps-2023
+func (fc *floorController) getCarbonByFloor(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	normalisation, output, ok := parseNormalisedOutput(ctx)
+	if !ok {
+		return
+	}
+	methodology, ok := parseMethodology(ctx)
+	if !ok {
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	result, err := fc.floorService.ComputeCarbonByFloor(uint(id), normalisation, methodology)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := result.Present(output, unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// getCarbonByUse fetches the carbon of each use class of a building by a
+// methodology, the building's own by default, optionally normalised by one of
+// the service.Normalisation denominators and presented in an output unit, see
+// parseNormalisedOutput.
+// endpoint: GET /buildings/:id/calculation/by-use?normalisation=per-m2-gfa&unit=kgCO2e/m2&methodology=rics-ps-2023
+func (fc *floorController) getCarbonByUse(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	normalisation, output, ok := parseNormalisedOutput(ctx)
+	if !ok {
+		return
+	}
+	methodology, ok := parseMethodology(ctx)
+	if !ok {
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	result, err := fc.floorService.ComputeCarbonByUse(uint(id), normalisation, methodology)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := result.Present(output, unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// parseFloorIDs parses the building and floor IDs of a floor route,
+// responding with an error if either is malformed.
+func parseFloorIDs(ctx *gin.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return 0, 0, false
+	}
+	floorID, err := strconv.ParseUint(ctx.Param("floorId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid floor ID format")
+		return 0, 0, false
+	}
+	return uint(id), uint(floorID), true
+}
@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"carbon-service/model"
 	"carbon-service/service"
+	"carbon-service/service/converter"
 	"net/http"
 	"strconv"
 
@@ -14,6 +16,13 @@ type MaterialController interface {
 	getMaterial(ctx *gin.Context)
 	getMaterials(ctx *gin.Context)
 	getTotalCarbon(ctx *gin.Context)
+	createMaterialVersion(ctx *gin.Context)
+	getMaterialVersions(ctx *gin.Context)
+	previewUpgrade(ctx *gin.Context)
+	upgradeMaterial(ctx *gin.Context)
+	getWhereUsed(ctx *gin.Context)
+	dryRunMaterialChange(ctx *gin.Context)
+	convertToDeclaredUnit(ctx *gin.Context)
 }
 
 type materialController struct {
@@ -32,18 +41,23 @@ func NewMaterialController(router *gin.Engine, ms service.MaterialService, cs se
 	router.GET("/materials/:id", mc.getMaterial)
 	router.GET("/materials", mc.getMaterials)
 	router.GET("/materials/:id/total-carbon", mc.getTotalCarbon)
+	router.POST("/materials/:id/versions", mc.createMaterialVersion)
+	router.GET("/materials/:id/versions", mc.getMaterialVersions)
+	router.GET("/materials/:id/upgrade", mc.previewUpgrade)
+	router.POST("/materials/:id/upgrade", mc.upgradeMaterial)
+	router.GET("/materials/:id/where-used", mc.getWhereUsed)
+	router.POST("/materials/:id/dry-run", mc.dryRunMaterialChange)
+	router.GET("/materials/:id/convert", mc.convertToDeclaredUnit)
 }
 
 func (mc *materialController) createMaterial(ctx *gin.Context) {
-	var req struct {
-		Name string `json:"name" binding:"required"`
-	}
+	var req service.CreateMaterialRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		respondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
-	material, err := mc.materialService.CreateMaterial(req.Name)
+	material, err := mc.materialService.CreateMaterial(req)
 	if err != nil {
 		respondWithError(ctx, http.StatusInternalServerError, err.Error())
 		return
@@ -74,18 +88,189 @@ func (mc *materialController) getMaterials(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, materials)
 }
 
+// getTotalCarbon fetches the whole life carbon of one declared unit of a material in an absolute
+// output unit. With detailed=true the response includes the breakdown of the
+// result in kgCO2e down to the EPDs.
+// endpoint: GET /materials/:id/total-carbon?unit=tCO2e&detailed=true
 func (mc *materialController) getTotalCarbon(ctx *gin.Context) {
 	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
 	if err != nil {
 		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
+	unit := converter.OutputUnit(ctx.Query("unit"))
+	if unit == "" {
+		system, ok := requestUnit(ctx)
+		if !ok {
+			return
+		}
+		unit = converter.OutputUnit(system.Carbon)
+	}
+	scale, err := unit.Scale()
+	if err != nil || scale.PerArea() {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid unit")
+		return
+	}
+	detailed, ok := parseDetailed(ctx)
+	if !ok {
+		return
+	}
+	if detailed {
+		breakdown, err := mc.materialService.ComputeBreakdown(uint(id))
+		if err != nil {
+			respondWithError(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"total_carbon": scale.Convert(breakdown.Subtotal, false), "unit": scale.Carbon, "breakdown": breakdown})
+		return
+	}
 	carbon, err := mc.materialService.ComputeTotalCarbon(uint(id))
 	if err != nil {
 		respondWithError(ctx, http.StatusInternalServerError, err.Error())
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"total_carbon": carbon})
+	ctx.JSON(http.StatusOK, gin.H{"total_carbon": scale.Convert(carbon, false), "unit": scale.Carbon})
+}
+
+// createMaterialVersion records a renewed EPD as a new immutable version of the material.
+// endpoint: POST /materials/:id/versions
+func (mc *materialController) createMaterialVersion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req service.CreateMaterialVersionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	version, err := mc.materialService.CreateMaterialVersion(uint(id), req)
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, version)
+}
+
+// getMaterialVersions fetches the version history of a material.
+// endpoint: GET /materials/:id/versions
+func (mc *materialController) getMaterialVersions(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	versions, err := mc.materialService.GetMaterialVersions(uint(id))
+	if err != nil {
+		respondWithError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, versions)
+}
+
+// previewUpgrade shows the carbon delta of every affected building if the
+// material were upgraded to its latest version.
+// endpoint: GET /materials/:id/upgrade
+func (mc *materialController) previewUpgrade(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	upgrade, err := mc.materialService.PreviewMaterialUpgrade(uint(id))
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, upgrade)
+}
+
+// upgradeMaterial pins every building using the material to its latest version.
+// endpoint: POST /materials/:id/upgrade
+func (mc *materialController) upgradeMaterial(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	upgrade, err := mc.materialService.UpgradeMaterial(uint(id))
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, upgrade)
+}
+
+// getWhereUsed fetches every assembly and building using a material,
+// with the material's share of each building's total.
+// endpoint: GET /materials/:id/where-used
+func (mc *materialController) getWhereUsed(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	usage, err := mc.materialService.GetWhereUsed(uint(id))
+	if err != nil {
+		respondWithError(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, usage)
+}
+
+// dryRunMaterialChange recalculates every building using a material with a
+// proposed set of GWP values, without saving them.
+// endpoint: POST /materials/:id/dry-run
+func (mc *materialController) dryRunMaterialChange(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req struct {
+		Gwp model.Gwp `json:"gwp" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	dryRun, err := mc.materialService.DryRunMaterialChange(uint(id), req.Gwp)
+	if err != nil {
+		respondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, dryRun)
+}
+
+// convertToDeclaredUnit converts a quantity of a material to its declared unit.
+// Without a unit the quantity is in the unit system of the request.
+// endpoint: GET /materials/:id/convert?quantity=12.5&unit=m3
+func (mc *materialController) convertToDeclaredUnit(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	quantity, err := strconv.ParseFloat(ctx.Query("quantity"), 64)
+	if err != nil {
+		respondWithError(ctx, http.StatusBadRequest, "Invalid quantity format")
+		return
+	}
+	unit := ctx.Query("unit")
+	var system model.Unit
+	if unit == "" {
+		var ok bool
+		if system, ok = requestUnit(ctx); !ok {
+			return
+		}
+	}
+	converted, err := mc.materialService.ConvertToDeclaredUnit(uint(id), quantity, unit, system)
+	if err != nil {
+		respondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, converted)
 }
 
 func respondWithError(ctx *gin.Context, code int, message string) {
@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"carbon-service/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type scenarioController struct {
+	scenarioService service.ScenarioService
+}
+
+// NewScenarioController sets up routes and handlers for the design options of buildings.
+func NewScenarioController(router *gin.Engine, ss service.ScenarioService) {
+	sc := &scenarioController{
+		scenarioService: ss,
+	}
+
+	router.POST("/buildings/:id/scenarios", sc.createScenario)
+	router.GET("/buildings/:id/scenarios", sc.getScenarios)
+	router.PUT("/buildings/:id/scenarios/:scenarioId", sc.updateScenario)
+	router.DELETE("/buildings/:id/scenarios/:scenarioId", sc.removeScenario)
+	router.PUT("/buildings/:id/scenarios/:scenarioId/assemblies/:assemblyId", sc.overrideAssembly)
+	router.DELETE("/buildings/:id/scenarios/:scenarioId/assemblies/:assemblyId", sc.removeOverride)
+	router.GET("/buildings/:id/calculation/scenarios", sc.compareScenarios)
+}
+
+// createScenario adds a design option to a building.
+// endpoint: POST /buildings/:id/scenarios
+func (sc *scenarioController) createScenario(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req service.ScenarioRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	scenario, err := sc.scenarioService.CreateScenario(uint(id), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithScenario(ctx, http.StatusCreated, scenario)
+}
+
+// getScenarios fetches the design options of a building.
+// endpoint: GET /buildings/:id/scenarios
+func (sc *scenarioController) getScenarios(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	scenarios, err := sc.scenarioService.GetScenarios(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	for i := range scenarios {
+		s, err := service.ScenarioInUnit(&scenarios[i], unit)
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		scenarios[i] = *s
+	}
+	ctx.JSON(http.StatusOK, scenarios)
+}
+
+// updateScenario replaces the name, description and geometry parameters of a scenario.
+// endpoint: PUT /buildings/:id/scenarios/:scenarioId
+func (sc *scenarioController) updateScenario(ctx *gin.Context) {
+	id, scenarioID, ok := parseScenarioIDs(ctx)
+	if !ok {
+		return
+	}
+	var req service.ScenarioRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	if err := req.ToMetric(unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	scenario, err := sc.scenarioService.UpdateScenario(id, scenarioID, req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithScenario(ctx, http.StatusOK, scenario)
+}
+
+// removeScenario removes a design option from a building.
+// endpoint: DELETE /buildings/:id/scenarios/:scenarioId
+func (sc *scenarioController) removeScenario(ctx *gin.Context) {
+	id, scenarioID, ok := parseScenarioIDs(ctx)
+	if !ok {
+		return
+	}
+	if err := sc.scenarioService.RemoveScenario(id, scenarioID); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// overrideAssembly replaces, requantifies or removes an assembly of the building in a scenario.
+// endpoint: PUT /buildings/:id/scenarios/:scenarioId/assemblies/:assemblyId
+func (sc *scenarioController) overrideAssembly(ctx *gin.Context) {
+	id, scenarioID, ok := parseScenarioIDs(ctx)
+	if !ok {
+		return
+	}
+	assemblyID, err := strconv.ParseUint(ctx.Param("assemblyId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid assembly ID format")
+		return
+	}
+	var req service.OverrideAssemblyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	scenario, err := sc.scenarioService.OverrideAssembly(id, scenarioID, uint(assemblyID), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithScenario(ctx, http.StatusOK, scenario)
+}
+
+// removeOverride makes a scenario use the building's assembly as it is again.
+// endpoint: DELETE /buildings/:id/scenarios/:scenarioId/assemblies/:assemblyId
+func (sc *scenarioController) removeOverride(ctx *gin.Context) {
+	id, scenarioID, ok := parseScenarioIDs(ctx)
+	if !ok {
+		return
+	}
+	assemblyID, err := strconv.ParseUint(ctx.Param("assemblyId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid assembly ID format")
+		return
+	}
+	scenario, err := sc.scenarioService.RemoveOverride(id, scenarioID, uint(assemblyID))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	respondWithScenario(ctx, http.StatusOK, scenario)
+}
+
+// compareScenarios compares the design options of a building side by side,
+// optionally only those listed as comma separated IDs in scenarioIds and
+// normalised by one of the service.Normalisation denominators and presented
+// in an output unit, see parseNormalisedOutput.
+// endpoint: GET /buildings/:id/calculation/scenarios?scenarioIds=1,2&normalisation=per-m2-gfa&unit=kgCO2e/m2&methodology=rics-ps-2023
+func (sc *scenarioController) compareScenarios(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var scenarioIDs []uint
+	if param := ctx.Query("scenarioIds"); param != "" {
+		for _, value := range strings.Split(param, ",") {
+			scenarioID, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+			if err != nil {
+				helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid scenario ID format")
+				return
+			}
+			scenarioIDs = append(scenarioIDs, uint(scenarioID))
+		}
+	}
+	normalisation, output, ok := parseNormalisedOutput(ctx)
+	if !ok {
+		return
+	}
+	methodology, ok := parseMethodology(ctx)
+	if !ok {
+		return
+	}
+	comparison, err := sc.scenarioService.CompareScenarios(uint(id), scenarioIDs, normalisation, methodology)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := comparison.Present(output); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, comparison)
+}
+
+// parseScenarioIDs parses the building and scenario IDs of a scenario route,
+// responding with an error if either is malformed.
+func parseScenarioIDs(ctx *gin.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return 0, 0, false
+	}
+	scenarioID, err := strconv.ParseUint(ctx.Param("scenarioId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid scenario ID format")
+		return 0, 0, false
+	}
+	return uint(id), uint(scenarioID), true
+}
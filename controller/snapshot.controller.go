@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"carbon-service/service"
+	"net/http"
+	"strconv"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type snapshotController struct {
+	snapshotService service.SnapshotService
+}
+
+// NewSnapshotController sets up routes and handlers for the design stage snapshots of buildings.
+func NewSnapshotController(router *gin.Engine, ss service.SnapshotService) {
+	sc := &snapshotController{
+		snapshotService: ss,
+	}
+
+	router.POST("/buildings/:id/snapshots", sc.createSnapshot)
+	router.GET("/buildings/:id/snapshots", sc.getSnapshots)
+	router.GET("/buildings/:id/snapshots/trajectory", sc.getTrajectory)
+	router.GET("/buildings/:id/snapshots/diff", sc.diffSnapshots)
+	router.GET("/buildings/:id/snapshots/:snapshotId", sc.getSnapshot)
+}
+
+// createSnapshot records a building with its results by a methodology, the
+// building's own by default, at a RIBA stage.
+// endpoint: POST /buildings/:id/snapshots
+func (sc *snapshotController) createSnapshot(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	var req service.CreateSnapshotRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Methodology != "" {
+		if _, err := service.FindMethodology(req.Methodology); err != nil {
+			helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid methodology")
+			return
+		}
+	}
+	snapshot, err := sc.snapshotService.CreateSnapshot(uint(id), req)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, snapshot)
+}
+
+// getSnapshots fetches the snapshots of a building in date order.
+// endpoint: GET /buildings/:id/snapshots
+func (sc *snapshotController) getSnapshots(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	snapshots, err := sc.snapshotService.GetSnapshots(uint(id))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, snapshots)
+}
+
+// getSnapshot fetches a snapshot of a building with its full state.
+// endpoint: GET /buildings/:id/snapshots/:snapshotId
+func (sc *snapshotController) getSnapshot(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	snapshotID, err := strconv.ParseUint(ctx.Param("snapshotId"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid snapshot ID format")
+		return
+	}
+	snapshot, err := sc.snapshotService.GetSnapshot(uint(id), uint(snapshotID))
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusNotFound, "Snapshot not found")
+		return
+	}
+	ctx.JSON(http.StatusOK, snapshot)
+}
+
+// getTrajectory fetches the totals and per element results of a building across its
+// snapshots, optionally normalised by one of the service.Normalisation denominators
+// and presented in an output unit, see parseNormalisedOutput.
+// endpoint: GET /buildings/:id/snapshots/trajectory?normalisation=per-m2-gfa&unit=kgCO2e/m2
+func (sc *snapshotController) getTrajectory(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	normalisation, output, ok := parseNormalisedOutput(ctx)
+	if !ok {
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	trajectory, err := sc.snapshotService.GetTrajectory(uint(id), normalisation)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	for i := range trajectory {
+		if err := trajectory[i].Present(output, unit); err != nil {
+			helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, trajectory)
+}
+
+// diffSnapshots compares two snapshots of a building, optionally normalised by
+// one of the service.Normalisation denominators and presented in an output
+// unit, see parseNormalisedOutput.
+// endpoint: GET /buildings/:id/snapshots/diff?from=1&to=2&normalisation=per-m2-gfa&unit=kgCO2e/m2
+func (sc *snapshotController) diffSnapshots(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+	fromID, err := strconv.ParseUint(ctx.Query("from"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid from snapshot ID format")
+		return
+	}
+	toID, err := strconv.ParseUint(ctx.Query("to"), 10, 32)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid to snapshot ID format")
+		return
+	}
+	normalisation, output, ok := parseNormalisedOutput(ctx)
+	if !ok {
+		return
+	}
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	diff, err := sc.snapshotService.DiffSnapshots(uint(id), uint(fromID), uint(toID), normalisation)
+	if err != nil {
+		helpers.RespondWithError(ctx, calculationErrorStatus(err), err.Error())
+		return
+	}
+	if err := diff.Present(output, unit); err != nil {
+		helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, diff)
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"carbon-service/model"
+	"carbon-service/service"
+	"net/http"
+
+	"carbon-service/helpers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers and query parameter a request selects its unit system with. The
+// unit system of the request overrides the preference of its project, which
+// overrides the preference of its user.
+const (
+	unitSystemHeader = "X-Unit-System"
+	unitSystemQuery  = "units"
+	projectIDHeader  = "X-Project-Id"
+	userIDHeader     = "X-User-Id"
+	unitContextKey   = "unit"
+	unitServiceKey   = "unitService"
+)
+
+type unitController struct {
+	unitService service.UnitService
+}
+
+// NewUnitController sets up routes and handlers for unit system preferences,
+// and lets the handlers of every request resolve its unit system. It must be
+// set up before the other controllers, as preferences only apply to routes
+// added after it.
+func NewUnitController(router *gin.Engine, us service.UnitService) {
+	uc := &unitController{
+		unitService: us,
+	}
+
+	router.Use(uc.provideUnitService)
+	router.PUT("/users/:ownerId/unit-preference", uc.setPreference(model.UnitOwnerUser))
+	router.GET("/users/:ownerId/unit-preference", uc.getPreference(model.UnitOwnerUser))
+	router.PUT("/projects/:ownerId/unit-preference", uc.setPreference(model.UnitOwnerProject))
+	router.GET("/projects/:ownerId/unit-preference", uc.getPreference(model.UnitOwnerProject))
+}
+
+// provideUnitService stores the unit service in the context of the request,
+// so that the handlers that present values resolve the unit system with it.
+// Nothing is looked up until a handler asks for the unit system.
+func (uc *unitController) provideUnitService(ctx *gin.Context) {
+	ctx.Set(unitServiceKey, uc.unitService)
+	ctx.Next()
+}
+
+// setPreference stores the unit system of a user or project.
+// endpoint: PUT /users/:ownerId/unit-preference
+// endpoint: PUT /projects/:ownerId/unit-preference
+func (uc *unitController) setPreference(ownerType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req service.UnitPreferenceRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		unit, err := uc.unitService.SetPreference(ownerType, ctx.Param("ownerId"), req)
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusOK, unit)
+	}
+}
+
+// getPreference fetches the unit system of a user or project.
+// endpoint: GET /users/:ownerId/unit-preference
+// endpoint: GET /projects/:ownerId/unit-preference
+func (uc *unitController) getPreference(ownerType string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		unit, err := uc.unitService.GetPreference(ownerType, ctx.Param("ownerId"))
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusNotFound, "Unit preference not found")
+			return
+		}
+		ctx.JSON(http.StatusOK, unit)
+	}
+}
+
+// requestUnit returns the unit system of the request, resolving it on first
+// use: the one asked for with the units query parameter or X-Unit-System
+// header, or else the preference of the X-Project-Id project or X-User-Id
+// user, metric when there is none. It responds with an error if the unit
+// system is invalid or the preferences cannot be looked up.
+func requestUnit(ctx *gin.Context) (model.Unit, bool) {
+	if unit, ok := ctx.Get(unitContextKey); ok {
+		return unit.(model.Unit), true
+	}
+
+	unit := model.NewUnit(model.UnitSystemMetric)
+	system := ctx.Query(unitSystemQuery)
+	if system == "" {
+		system = ctx.GetHeader(unitSystemHeader)
+	}
+	if system != "" {
+		if !model.UnitSystem(system).IsValid() {
+			helpers.RespondWithError(ctx, http.StatusBadRequest, "Invalid unit system")
+			return unit, false
+		}
+		unit = model.NewUnit(model.UnitSystem(system))
+	} else if us, ok := ctx.Get(unitServiceKey); ok {
+		resolved, err := us.(service.UnitService).ResolveUnit(ctx.GetHeader(projectIDHeader), ctx.GetHeader(userIDHeader))
+		if err != nil {
+			helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+			return unit, false
+		}
+		unit = resolved
+	}
+	ctx.Set(unitContextKey, unit)
+	return unit, true
+}
+
+// respondWithBuilding responds with the building's geometry in the unit system of the request.
+func respondWithBuilding(ctx *gin.Context, code int, building *model.Building) {
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	b, err := service.BuildingInUnit(building, unit)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(code, b)
+}
+
+// respondWithFloor responds with the floor's geometry in the unit system of the request.
+func respondWithFloor(ctx *gin.Context, code int, floor *model.Floor) {
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	f, err := service.FloorInUnit(floor, unit)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(code, f)
+}
+
+// respondWithScenario responds with the geometry the scenario overrides in the
+// unit system of the request.
+func respondWithScenario(ctx *gin.Context, code int, scenario *model.Scenario) {
+	unit, ok := requestUnit(ctx)
+	if !ok {
+		return
+	}
+	s, err := service.ScenarioInUnit(scenario, unit)
+	if err != nil {
+		helpers.RespondWithError(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(code, s)
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+)
+
+// BackfillMaterialVersions gives the GWP values stored before materials were
+// versioned a first version of their material, so that buildings can be pinned
+// to it. Materials that already have versions are left as they are.
+func BackfillMaterialVersions(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var gwps []model.Gwp
+		err := tx.Where("material_id <> 0").
+			Where("material_version_id = 0 OR material_version_id IS NULL").
+			Find(&gwps).Error
+		if err != nil {
+			return err
+		}
+
+		for _, gwp := range gwps {
+			var versions int64
+			if err := tx.Model(&model.MaterialVersion{}).Where("material_id = ?", gwp.MaterialID).Count(&versions).Error; err != nil {
+				return err
+			}
+			if versions > 0 {
+				continue
+			}
+			version := &model.MaterialVersion{MaterialID: gwp.MaterialID, Version: 1}
+			if err := tx.Omit("Gwp").Create(version).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&gwp).Update("material_version_id", version.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"carbon-service/model"
 	"fmt"
 	"log"
 	"os"
@@ -38,6 +39,21 @@ func ConnectDatabase(dbConfig *DbConfig) (*gorm.DB, error) {
 	return db, err
 }
 
+// SetupJoinTables registers the join tables that carry extra columns,
+// it has to be called before the models are migrated or queried.
+func SetupJoinTables(db *gorm.DB) error {
+	if err := db.SetupJoinTable(&model.Assembly{}, "Materials", &model.AssemblyMaterial{}); err != nil {
+		return err
+	}
+	if err := db.SetupJoinTable(&model.Material{}, "Assemblies", &model.AssemblyMaterial{}); err != nil {
+		return err
+	}
+	if err := db.SetupJoinTable(&model.Building{}, "Assemblies", &model.BuildingAssembly{}); err != nil {
+		return err
+	}
+	return db.SetupJoinTable(&model.Assembly{}, "Buildings", &model.BuildingAssembly{})
+}
+
 type DbConfig struct {
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
@@ -1,10 +1,23 @@
 package helpers
 
 import (
+	"errors"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // respondWithError standardizes error responses.
 func RespondWithError(ctx *gin.Context, code int, message string) {
 	ctx.JSON(code, gin.H{"error": message})
 }
+
+// ErrorStatus returns the status code of a service error: not found when a
+// record it depends on does not exist, an internal server error otherwise.
+func ErrorStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -37,49 +44,176 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// drop all tables
-	db.Migrator().DropTable(&model.Building{}, &model.Assembly{}, &model.Material{})
+	// Register join tables with extra columns before touching the schema
+	if err := database.SetupJoinTables(db); err != nil {
+		log.Fatalf("Failed to set up join tables: %v", err)
+	}
+
+	// All persisted models
+	models := []interface{}{
+		&model.Building{},
+		&model.Assembly{},
+		&model.Material{},
+		&model.MaterialVersion{},
+		&model.Gwp{},
+		&model.MaterialPin{},
+		&model.AssemblyLayer{},
+		&model.AssemblyMaterial{},
+		&model.AssemblyComponent{},
+		&model.BuildingAssembly{},
+		&model.Floor{},
+		&model.Zone{},
+		&model.FloorAssembly{},
+		&model.Archetype{},
+		&model.ArchetypeAssembly{},
+		&model.Scenario{},
+		&model.ScenarioAssembly{},
+		&model.Snapshot{},
+		&model.SnapshotElement{},
+		&model.SnapshotAssembly{},
+		&model.BenchmarkSet{},
+		&model.Benchmark{},
+		&model.Unit{},
+		&model.CalculationRun{},
+		&model.CalculationJob{},
+	}
 
-	// Drop all tables, including join tables
-	if err := db.Migrator().DropTable(
-		"building_assemblies", // Name of the join table between assembly and material
-		"assembly_materials",  // Name of the join table between assembly and building
-	); err != nil {
-		log.Fatalf("Failed to drop tables: %v", err)
+	// Start from an empty database when developing, the data and queued
+	// calculation jobs are kept across restarts otherwise
+	if os.Getenv("DATABASE_RESET") == "true" {
+		// Drop all tables, including join tables
+		if err := db.Migrator().DropTable(models...); err != nil {
+			log.Fatalf("Failed to drop tables: %v", err)
+		}
+		if err := db.Migrator().DropTable(
+			"building_assemblies", // Name of the join table between assembly and material
+			"assembly_materials",  // Name of the join table between assembly and building
+		); err != nil {
+			log.Fatalf("Failed to drop tables: %v", err)
+		}
 	}
 
 	// Perform database migration
-	if err := db.AutoMigrate(&model.Building{}, &model.Assembly{}, &model.Material{}); err != nil {
+	if err := db.AutoMigrate(models...); err != nil {
 		log.Fatalf("Failed to auto-migrate database schemas: %v", err)
 	}
 
+	// Give the GWP values stored before materials were versioned a first version
+	if err := database.BackfillMaterialVersions(db); err != nil {
+		log.Fatalf("Failed to backfill material versions: %v", err)
+	}
+
 	// Initialize repository, service, and controller
 	br := repository.NewBuildingRepository(db)
 	ar := repository.NewAssemblyRepository(db)
 	mr := repository.NewMaterialRepository(db)
+	fr := repository.NewFloorRepository(db)
+	atr := repository.NewArchetypeRepository(db)
+	sr := repository.NewScenarioRepository(db)
+	snr := repository.NewSnapshotRepository(db)
+	bmr := repository.NewBenchmarkRepository(db)
+	unr := repository.NewUnitRepository(db)
+	rr := repository.NewCalculationRunRepository(db)
+	jr := repository.NewCalculationJobRepository(db)
+
+	// Pin the materials of buildings created before materials were pinned
+	if err := pinExistingBuildings(br); err != nil {
+		log.Fatalf("Failed to pin material versions of existing buildings: %v", err)
+	}
 
 	// Initialize services
 	cs := service.NewCalculationService() // Assuming this is correctly implemented based on previous discussions
 
 	// Inject dependencies into building service
-	bs := service.NewBuildingService(br, cs)
-	as := service.NewAssemblyService(ar, cs)
-	ms := service.NewMaterialService(mr, cs)
+	bs := service.NewBuildingService(br, ar, rr, cs)
+	as := service.NewAssemblyService(ar, mr, br, cs)
+	ms := service.NewMaterialService(mr, ar, br, cs)
+	fs := service.NewFloorService(fr, br, ar, cs)
+	ats := service.NewArchetypeService(atr, ar, bs)
+	ss := service.NewScenarioService(sr, br, ar, cs)
+	sns := service.NewSnapshotService(snr, br, cs)
+	bms := service.NewBenchmarkService(bmr, br, cs)
+	us := service.NewUnitService(unr)
+	rs := service.NewCalculationRunService(rr)
+
+	// Run calculation jobs on a bounded pool of workers, resuming those this
+	// instance was running when it last stopped
+	workers, err := strconv.Atoi(os.Getenv("CALCULATION_WORKERS"))
+	if err != nil {
+		workers = service.DefaultJobWorkers
+	}
+	js := service.NewCalculationJobService(jr, bs, ss, workers, instanceID())
+	js.Start()
+
+	// Seed the published benchmark sets
+	if err := bms.SeedBenchmarkSets(service.DefaultBenchmarkSets()); err != nil {
+		log.Fatalf("Failed to seed benchmark sets: %v", err)
+	}
 
 	// Initialize the router which will handle the requests
 	router := gin.Default()
 
-	// Inject services into controller
+	// Inject services into controller, resolving the unit system of requests first
+	controller.NewUnitController(router, us)
 	controller.NewBuildingController(router, bs, cs)
 	controller.NewAssemblyController(router, as, cs)
 	controller.NewMaterialController(router, ms, cs)
+	controller.NewFloorController(router, fs)
+	controller.NewArchetypeController(router, ats)
+	controller.NewScenarioController(router, ss)
+	controller.NewSnapshotController(router, sns)
+	controller.NewBenchmarkController(router, bms)
+	controller.NewCalculationRunController(router, rs)
+	controller.NewCalculationJobController(router, js)
 
 	// Start the server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080" // Default port if not specified
 	}
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Error starting server: %s\n", err)
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting server: %s\n", err)
+		}
+	}()
+
+	// Shut down when stopped, queueing the running calculation jobs again for the next start
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
+	shutdown, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdown); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	js.Stop()
+}
+
+// instanceID identifies this instance of the service in the leases of the
+// calculation jobs it runs: INSTANCE_ID if it is set, the host name otherwise,
+// which stays the same when a container is restarted.
+func instanceID() string {
+	if id := os.Getenv("INSTANCE_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Failed to identify the instance: %v", err)
+	}
+	return host
+}
+
+// pinExistingBuildings pins every material of every building that is not
+// pinned yet to its latest version.
+func pinExistingBuildings(br repository.BuildingRepository) error {
+	buildings, err := br.FindAll()
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, len(buildings))
+	for i, building := range buildings {
+		ids[i] = building.ID
 	}
+	return br.PinMaterials(ids)
 }
@@ -0,0 +1,29 @@
+package model
+
+import "gorm.io/gorm"
+
+// Archetype is a template for early-stage studies, e.g. "steel-frame office",
+// with default parameters and the assemblies a building of its kind uses.
+type Archetype struct {
+	gorm.Model
+	Name                  string               `gorm:"type:string;unique;not null"`
+	Description           string               `gorm:"type:string;"`
+	FTF                   float64              `gorm:"type:float;not null"`
+	WWR                   float64              `gorm:"type:float;not null"`
+	AboveGroundFloorCount int                  `gorm:"type:int;not null"`
+	UnderGroundFloorCount int                  `gorm:"type:int;not null"`
+	Typology              UseClass             `gorm:"type:string;"`
+	SubstructureFactors   SubstructureFactors  `gorm:"embedded;embeddedPrefix:substructure_"`
+	AssemblyLinks         []*ArchetypeAssembly `gorm:"foreignKey:ArchetypeID;constraint:OnDelete:CASCADE;"`
+}
+
+// ArchetypeAssembly is a default assembly of an archetype with the element,
+// geometric role and quantity it is assigned with on new buildings.
+type ArchetypeAssembly struct {
+	ArchetypeID uint          `gorm:"primaryKey"`
+	AssemblyID  uint          `gorm:"primaryKey"`
+	Assembly    *Assembly     `gorm:"foreignKey:AssemblyID;"`
+	Element     Element       `gorm:"type:string;"`
+	Role        GeometricRole `gorm:"type:string;"`
+	Quantity    float64       `gorm:"type:float;not null;default:0"`
+}
@@ -8,32 +8,251 @@ import (
 var _ CarbonCalculator = &Assembly{}
 var _ ByIndicatorCarbonCalculator = &Assembly{}
 
+// MaterialLinks are the rows of the assembly_materials join behind Materials,
+// they carry the quantity of each material and are used for calculations.
+// Layers describe a layered build-up per m2 of assembly, ordered by Position
+// from one face of the assembly to the other.
+// Components are sub-assemblies, which can themselves contain sub-assemblies.
+// Area is the m2 of layered build-up in one unit of the assembly, when it is
+// not set one unit of the assembly is one m2 of its layers.
+// TotalCarbon and CarbonPerArea cache the results of the last recalculation
+// with the latest versions of its materials, like the assembly's own results;
+// buildings calculate with the versions they are pinned to instead.
+// Library templates are standard build-ups that are cloned into project specific
+// assemblies, a clone keeps the ID of the template it was cloned from.
 type Assembly struct {
 	gorm.Model
-	Name      string      `gorm:"type:string;not null"`
-	Buildings []*Building `gorm:"many2many:building_assemblies;"`
-	Materials []*Material `gorm:"many2many:assembly_materials;"`
+	Name          string               `gorm:"type:string;not null"`
+	IsTemplate    bool                 `gorm:"type:bool;not null;default:false;index"`
+	TemplateID    *uint                `gorm:"index"`
+	Buildings     []*Building          `gorm:"many2many:building_assemblies;"`
+	Materials     []*Material          `gorm:"many2many:assembly_materials;"`
+	MaterialLinks []*AssemblyMaterial  `gorm:"foreignKey:AssemblyID;"`
+	Layers        []*AssemblyLayer     `gorm:"foreignKey:AssemblyID;constraint:OnDelete:CASCADE;"`
+	Components    []*AssemblyComponent `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE;"`
+	Area          *float64             `gorm:"type:float;"` // m2 of layers per unit
+	TotalCarbon   float64              `gorm:"type:float;"`
+	CarbonPerArea float64              `gorm:"type:float;"`
 }
 
 func (a Assembly) ComputeWholeLifeCarbon() float64 {
 	var totalImpact float64
-	for _, material := range a.Materials {
-		totalImpact += material.ComputeWholeLifeCarbon()
+	for _, link := range a.MaterialLinks {
+		totalImpact += link.ComputeWholeLifeCarbon()
 	}
-	return totalImpact
+	for _, component := range a.Components {
+		totalImpact += component.ComputeWholeLifeCarbon()
+	}
+	return totalImpact + a.LayerArea()*a.ComputeCarbonPerArea()
 }
 
 func (a Assembly) CalculateCarbonForPhase(phases ...string) float64 {
 	var total float64
-	for _, material := range a.Materials {
-		total += material.CalculateCarbonForPhase(phases...)
+	for _, link := range a.MaterialLinks {
+		total += link.CalculateCarbonForPhase(phases...)
+	}
+	for _, layer := range a.Layers {
+		total += a.LayerArea() * layer.CalculateCarbonForPhase(phases...)
+	}
+	for _, component := range a.Components {
+		total += component.CalculateCarbonForPhase(phases...)
+	}
+	return total
+}
+
+// ComputeCarbonPerArea calculates the whole life carbon of one m2 of the assembly's layers.
+func (a Assembly) ComputeCarbonPerArea() float64 {
+	var total float64
+	for _, layer := range a.Layers {
+		total += layer.ComputeWholeLifeCarbon()
+	}
+	return total
+}
+
+// LayerArea returns the m2 of layers in one unit of the assembly.
+func (a Assembly) LayerArea() float64 {
+	if a.Area == nil {
+		return 1
+	}
+	return *a.Area
+}
+
+// ComputeMaterialCarbon calculates the whole life carbon the given material
+// contributes to the assembly.
+func (a Assembly) ComputeMaterialCarbon(materialID uint) float64 {
+	var total float64
+	for _, link := range a.MaterialLinks {
+		if link.MaterialID == materialID {
+			total += link.ComputeWholeLifeCarbon()
+		}
+	}
+	for _, layer := range a.Layers {
+		if layer.MaterialID == materialID {
+			total += a.LayerArea() * layer.ComputeWholeLifeCarbon()
+		}
+	}
+	for _, component := range a.Components {
+		if component.Child != nil {
+			total += component.Quantity * component.Child.ComputeMaterialCarbon(materialID)
+		}
 	}
 	return total
 }
 
-func (a *Assembly) ConvertValues(isMetric bool, option int) Assembly {
-	for _, material := range a.Materials {
-		material.ConvertValues(isMetric, option)
+// FindMaterialLink returns the link to the given material, or nil if the
+// material is not part of the assembly.
+func (a *Assembly) FindMaterialLink(materialID uint) *AssemblyMaterial {
+	for _, link := range a.MaterialLinks {
+		if link.MaterialID == materialID {
+			return link
+		}
+	}
+	return nil
+}
+
+// Clone deep-copies the assembly with all its material links and layers into
+// a new, unsaved assembly linked to this one as its template.
+func (a *Assembly) Clone(name string) *Assembly {
+	templateID := a.ID
+	clone := &Assembly{
+		Name:          name,
+		TemplateID:    &templateID,
+		TotalCarbon:   a.TotalCarbon,
+		CarbonPerArea: a.CarbonPerArea,
+	}
+	if a.Area != nil {
+		area := *a.Area
+		clone.Area = &area
+	}
+	for _, link := range a.MaterialLinks {
+		clone.MaterialLinks = append(clone.MaterialLinks, &AssemblyMaterial{
+			MaterialID:       link.MaterialID,
+			Position:         link.Position,
+			Quantity:         link.Quantity,
+			Unit:             link.Unit,
+			DeclaredQuantity: link.DeclaredQuantity,
+		})
+	}
+	for _, layer := range a.Layers {
+		clone.Layers = append(clone.Layers, &AssemblyLayer{
+			Position:         layer.Position,
+			MaterialID:       layer.MaterialID,
+			Thickness:        layer.Thickness,
+			Rate:             layer.Rate,
+			RateUnit:         layer.RateUnit,
+			DeclaredQuantity: layer.DeclaredQuantity,
+		})
+	}
+	for _, component := range a.Components {
+		clone.Components = append(clone.Components, &AssemblyComponent{
+			ChildID:  component.ChildID,
+			Position: component.Position,
+			Quantity: component.Quantity,
+		})
+	}
+	return clone
+}
+
+// DriftedFrom reports whether the assembly's material links, layers, layer
+// area or sub-assemblies differ from those of the given template. All of them
+// are expected in position order.
+func (a *Assembly) DriftedFrom(template *Assembly) bool {
+	if len(a.MaterialLinks) != len(template.MaterialLinks) || len(a.Layers) != len(template.Layers) ||
+		len(a.Components) != len(template.Components) || a.LayerArea() != template.LayerArea() {
+		return true
+	}
+	for i, link := range a.MaterialLinks {
+		other := template.MaterialLinks[i]
+		if link.MaterialID != other.MaterialID || link.Quantity != other.Quantity ||
+			link.Unit != other.Unit || link.DeclaredQuantity != other.DeclaredQuantity {
+			return true
+		}
+	}
+	for i, layer := range a.Layers {
+		other := template.Layers[i]
+		if layer.MaterialID != other.MaterialID || layer.Thickness != other.Thickness ||
+			layer.Rate != other.Rate || layer.RateUnit != other.RateUnit ||
+			layer.DeclaredQuantity != other.DeclaredQuantity {
+			return true
+		}
+	}
+	for i, component := range a.Components {
+		other := template.Components[i]
+		if component.ChildID != other.ChildID || component.Quantity != other.Quantity {
+			return true
+		}
+	}
+	return false
+}
+
+// FindComponent returns the component for the given sub-assembly, or nil if
+// it is not a direct sub-assembly of the assembly.
+func (a *Assembly) FindComponent(childID uint) *AssemblyComponent {
+	for _, component := range a.Components {
+		if component.ChildID == childID {
+			return component
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the assembly is, or contains at any depth, the
+// assembly with the given ID. Sub-assemblies have to be loaded.
+func (a *Assembly) Contains(assemblyID uint) bool {
+	if a.ID == assemblyID {
+		return true
+	}
+	for _, component := range a.Components {
+		if component.Child != nil && component.Child.Contains(assemblyID) {
+			return true
+		}
+	}
+	return false
+}
+
+// UseLatestVersions makes every material of the assembly calculate with its latest version.
+func (a *Assembly) UseLatestVersions() {
+	for _, material := range a.materials() {
+		material.UseVersion(material.LatestVersion())
+	}
+}
+
+// materialIDs returns the IDs of the materials of the assembly, its layers
+// and sub-assemblies, whether the materials are loaded or not.
+func (a *Assembly) materialIDs() []uint {
+	var ids []uint
+	for _, link := range a.MaterialLinks {
+		ids = append(ids, link.MaterialID)
+	}
+	for _, layer := range a.Layers {
+		ids = append(ids, layer.MaterialID)
+	}
+	for _, component := range a.Components {
+		if component.Child != nil {
+			ids = append(ids, component.Child.materialIDs()...)
+		}
+	}
+	return ids
+}
+
+// materials returns every material used by the assembly, including its
+// layers and sub-assemblies.
+func (a *Assembly) materials() []*Material {
+	var materials []*Material
+	for _, link := range a.MaterialLinks {
+		if link.Material != nil {
+			materials = append(materials, link.Material)
+		}
+	}
+	for _, layer := range a.Layers {
+		if layer.Material != nil {
+			materials = append(materials, layer.Material)
+		}
+	}
+	for _, component := range a.Components {
+		if component.Child != nil {
+			materials = append(materials, component.Child.materials()...)
+		}
 	}
-	return *a
+	return materials
 }
@@ -0,0 +1,30 @@
+package model
+
+// AssemblyMaterial is the join between an assembly and one of its materials.
+// It carries the quantity of the material in the assembly, as entered in Unit
+// and converted to the material's declared unit, and its position in the assembly.
+type AssemblyMaterial struct {
+	AssemblyID       uint `gorm:"primaryKey"`
+	MaterialID       uint `gorm:"primaryKey"`
+	Material         *Material
+	Position         int     `gorm:"type:int;not null;default:0"`
+	Quantity         float64 `gorm:"type:float;not null;default:1"`
+	Unit             string  `gorm:"type:string;"`
+	DeclaredQuantity float64 `gorm:"type:float;not null;default:1"`
+}
+
+// ComputeWholeLifeCarbon calculates the whole life carbon of the material's quantity in the assembly.
+func (am AssemblyMaterial) ComputeWholeLifeCarbon() float64 {
+	if am.Material == nil {
+		return 0
+	}
+	return am.DeclaredQuantity * am.Material.ComputeWholeLifeCarbon()
+}
+
+// CalculateCarbonForPhase calculates the carbon of the material's quantity in the assembly for specified phases.
+func (am AssemblyMaterial) CalculateCarbonForPhase(phases ...string) float64 {
+	if am.Material == nil {
+		return 0
+	}
+	return am.DeclaredQuantity * am.Material.CalculateCarbonForPhase(phases...)
+}
@@ -9,6 +9,11 @@ type ByIndicatorCarbonCalculator interface {
 	CalculateCarbonForPhase(phase ...string) float64
 }
 
+// ByElementCarbonCalculator defines the interface for calculating carbon impact grouped by building element
+type ByElementCarbonCalculator interface {
+	ComputeWholeLifeCarbonByElement() map[Element]float64
+}
+
 // EmbodiedCarbonCalculator defines the interface for calculating embodied carbon
 type EmbodiedCarbonCalculator interface {
 	CalculateEmbodiedCarbon() float64
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// BenchmarkSet is a published set of carbon benchmarks, e.g. the LETI bands,
+// the RIBA 2030 Climate Challenge targets or the GLA whole life carbon benchmarks.
+type BenchmarkSet struct {
+	gorm.Model
+	Name       string       `gorm:"type:string;unique;not null"`
+	Source     string       `gorm:"type:string;"`
+	Version    string       `gorm:"type:string;"`
+	Benchmarks []*Benchmark `gorm:"foreignKey:BenchmarkSetID;constraint:OnDelete:CASCADE;"`
+}
+
+// Benchmark is a band or target of a benchmark set for one building typology
+// and module scope. A building meets it when its carbon per m2 of GIA over the
+// scope's modules is at most Limit.
+type Benchmark struct {
+	gorm.Model
+	BenchmarkSetID uint        `gorm:"index;not null"`
+	Typology       UseClass    `gorm:"type:string;not null"`
+	Scope          ModuleScope `gorm:"type:string;not null"`
+	Band           string      `gorm:"type:string;not null"` // e.g. "A++" or "2030 target"
+	Limit          float64     `gorm:"type:float;not null"`  // kgCO2e/m2
+}
+
+// BenchmarksFor returns the set's benchmarks for the typology grouped by
+// module scope, each group ordered from the strictest limit up.
+func (s *BenchmarkSet) BenchmarksFor(typology UseClass) map[ModuleScope][]*Benchmark {
+	byScope := make(map[ModuleScope][]*Benchmark)
+	for _, benchmark := range s.Benchmarks {
+		if benchmark.Typology == typology {
+			byScope[benchmark.Scope] = append(byScope[benchmark.Scope], benchmark)
+		}
+	}
+	for _, benchmarks := range byScope {
+		sort.SliceStable(benchmarks, func(i, j int) bool { return benchmarks[i].Limit < benchmarks[j].Limit })
+	}
+	return byScope
+}
+
+// Rate returns the strictest of the benchmarks the carbon per m2 meets, or
+// nil when it exceeds them all. The benchmarks have to be ordered from the
+// strictest limit up, as BenchmarksFor returns them.
+func Rate(benchmarks []*Benchmark, carbonPerArea float64) *Benchmark {
+	for _, benchmark := range benchmarks {
+		if carbonPerArea <= benchmark.Limit {
+			return benchmark
+		}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package model
+
+// Levels of a calculation breakdown, from the building down to the life
+// cycle modules of the materials.
+const (
+	LevelBuilding = "building"
+	LevelElement  = "element"
+	LevelAssembly = "assembly"
+	LevelMaterial = "material"
+	LevelModule   = "module"
+	LevelEstimate = "estimate"
+	LevelUplift   = "uplift"
+)
+
+// BreakdownNode is one figure of a whole life carbon calculation with where
+// it came from: the quantity used, its unit, the factor in kgCO2e per unit of
+// quantity and the resulting Subtotal in kgCO2e. Material nodes record the
+// EPD source and version their factor was taken from. The subtotal of a node
+// is the sum of the subtotals of its children, building and element nodes
+// only group their children and have no quantity or factor of their own.
+type BreakdownNode struct {
+	Level             string           `json:"level"`
+	ID                uint             `json:"id,omitempty"`
+	Name              string           `json:"name"`
+	Location          string           `json:"location,omitempty"`
+	Quantity          float64          `json:"quantity,omitempty"`
+	Unit              string           `json:"unit,omitempty"`
+	Factor            float64          `json:"factor,omitempty"`
+	Source            string           `json:"source,omitempty"`
+	MaterialVersionID uint             `json:"materialVersionId,omitempty"`
+	MaterialVersion   int              `json:"materialVersion,omitempty"`
+	Subtotal          float64          `json:"subtotal"`
+	Children          []*BreakdownNode `json:"children,omitempty"`
+}
+
+// add appends a child to the node and adds its subtotal to the node's.
+func (n *BreakdownNode) add(child *BreakdownNode) {
+	n.Children = append(n.Children, child)
+	n.Subtotal += child.Subtotal
+}
+
+// AddUplift uplifts the subtotal of every child of the node by the rate, with
+// the uplift recorded as a node of its own under the child so that uplifted
+// subtotals can still be traced to what they were uplifted from.
+func (n *BreakdownNode) AddUplift(rate float64, source string) {
+	if rate == 0 {
+		return
+	}
+	n.Subtotal = 0
+	for _, child := range n.Children {
+		child.add(&BreakdownNode{
+			Level:    LevelUplift,
+			Name:     "uplift",
+			Quantity: child.Subtotal,
+			Unit:     "kgCO2e",
+			Factor:   rate,
+			Source:   source,
+			Subtotal: child.Subtotal * rate,
+		})
+		n.Subtotal += child.Subtotal
+	}
+}
+
+// wholeLifeModules are the modules of the whole life carbon, D is reported
+// beyond the life cycle and not included.
+var wholeLifeModules = IndicatorModules[:len(IndicatorModules)-1]
+
+// Breakdown returns the building's whole life carbon as a tree of building,
+// elements, assemblies, materials and modules. Material versions have to be
+// pinned first, see PinMaterialVersions.
+func (b *Building) Breakdown() *BreakdownNode {
+	root := &BreakdownNode{Level: LevelBuilding, ID: b.ID, Name: b.Name}
+	elements := make(map[Element]*BreakdownNode)
+	elementNode := func(element Element) *BreakdownNode {
+		if element == "" {
+			element = ElementUnclassified
+		}
+		if elements[element] == nil {
+			elements[element] = &BreakdownNode{Level: LevelElement, Name: string(element)}
+		}
+		return elements[element]
+	}
+
+	for _, assembly := range b.Assemblies {
+		unit := "unit"
+		if link := b.assemblyLink(assembly.ID); link != nil && link.Role != "" {
+			unit = "m2"
+		}
+		node := assembly.Breakdown(b.AssemblyQuantity(assembly.ID), unit)
+		elementNode(b.AssemblyElement(assembly.ID)).add(node)
+	}
+	for _, floor := range b.Floors {
+		for _, link := range floor.AssemblyLinks {
+			if link.Assembly == nil {
+				continue
+			}
+			node := link.Assembly.Breakdown(link.Quantity, "unit")
+			node.Location = floor.Name
+			elementNode(link.Element).add(node)
+		}
+	}
+
+	for _, element := range Elements {
+		if node, ok := elements[element]; ok {
+			root.add(node)
+		}
+	}
+	return root
+}
+
+// EmbodiedBreakdown returns the building's embodied carbon estimate as a tree
+// of building, elements and the substructure parts and areas of the
+// parametric geometry each element is estimated from.
+func (b *Building) EmbodiedBreakdown() *BreakdownNode {
+	root := &BreakdownNode{Level: LevelBuilding, ID: b.ID, Name: b.Name}
+
+	estimate := b.EstimateSubstructure()
+	substructure := &BreakdownNode{Level: LevelElement, Name: string(ElementSubstructure)}
+	for _, part := range []struct {
+		name     string
+		quantity float64
+		unit     string
+		factor   float64
+	}{
+		{"retaining walls", estimate.RetainingWallArea, "m2", *estimate.Factors.RetainingWall},
+		{"basement slabs", estimate.BasementSlabArea, "m2", *estimate.Factors.BasementSlab},
+		{"foundations", estimate.FoundationArea, "m2", *estimate.Factors.Foundation},
+		{"excavation", estimate.ExcavationVolume, "m3", *estimate.Factors.Excavation},
+	} {
+		substructure.add(&BreakdownNode{
+			Level:    LevelEstimate,
+			Name:     part.name,
+			Quantity: part.quantity,
+			Unit:     part.unit,
+			Factor:   part.factor,
+			Source:   "substructure factors",
+			Subtotal: part.quantity * part.factor,
+		})
+	}
+	root.add(substructure)
+
+	for _, rate := range b.embodiedAreaRates() {
+		element := &BreakdownNode{Level: LevelElement, Name: string(rate.element)}
+		element.add(&BreakdownNode{
+			Level:    LevelEstimate,
+			Name:     string(rate.element) + " area",
+			Quantity: rate.area,
+			Unit:     "m2",
+			Factor:   rate.rate,
+			Source:   embodiedCarbonSource,
+			Subtotal: rate.area * rate.rate,
+		})
+		root.add(element)
+	}
+	return root
+}
+
+// Breakdown returns the whole life carbon of a quantity of the assembly as a
+// tree of its materials, layers and sub-assemblies down to the modules of each
+// material. Layers are per m2 of assembly and count once per m2 of layers.
+func (a *Assembly) Breakdown(quantity float64, unit string) *BreakdownNode {
+	node := &BreakdownNode{
+		Level:    LevelAssembly,
+		ID:       a.ID,
+		Name:     a.Name,
+		Quantity: quantity,
+		Unit:     unit,
+		Factor:   a.ComputeWholeLifeCarbon(),
+	}
+	for _, link := range a.MaterialLinks {
+		if link.Material != nil {
+			node.add(link.Material.Breakdown(quantity * link.DeclaredQuantity))
+		}
+	}
+	for _, layer := range a.Layers {
+		if layer.Material != nil {
+			node.add(layer.Material.Breakdown(quantity * a.LayerArea() * layer.DeclaredQuantity))
+		}
+	}
+	for _, component := range a.Components {
+		if component.Child != nil {
+			node.add(component.Child.Breakdown(quantity*component.Quantity, "unit"))
+		}
+	}
+	return node
+}
+
+// Breakdown returns the whole life carbon of a quantity of the material, in
+// its declared unit, per module with the EPD version the factors came from.
+func (m *Material) Breakdown(quantity float64) *BreakdownNode {
+	node := &BreakdownNode{
+		Level:    LevelMaterial,
+		ID:       m.ID,
+		Name:     m.Name,
+		Quantity: quantity,
+		Unit:     m.DeclaredUnit,
+		Factor:   m.ComputeWholeLifeCarbon(),
+	}
+	if version := m.CurrentVersion(); version != nil {
+		node.Source = version.EpdSource
+		node.MaterialVersionID = version.ID
+		node.MaterialVersion = version.Version
+	}
+	if m.Indicator == nil {
+		return node
+	}
+	for _, module := range wholeLifeModules {
+		factor := ModuleValue(m.Indicator, module)
+		if factor == 0 {
+			continue
+		}
+		node.add(&BreakdownNode{
+			Level:    LevelModule,
+			Name:     module,
+			Quantity: quantity,
+			Unit:     m.DeclaredUnit,
+			Factor:   factor,
+			Source:   node.Source,
+			Subtotal: quantity * factor,
+		})
+	}
+	return node
+}
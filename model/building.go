@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"math"
 
 	"gorm.io/gorm"
@@ -9,21 +10,43 @@ import (
 // Ensure Building struct conforms to the CarbonCalculator interface
 var _ CarbonCalculator = &Building{}
 var _ ByIndicatorCarbonCalculator = &Building{}
+var _ ByElementCarbonCalculator = &Building{}
 
 type Building struct {
 	gorm.Model
-	Name                  string      `gorm:"type:string;unique;not null"`
-	GFA                   float64     `gorm:"type:float;"`
-	FTF                   float64     `gorm:"type:float;not null"`
-	GroundFloorArea       float64     `gorm:"type:float;not null"`
-	FacadeArea            float64     `gorm:"type:float;"`
-	GlazingArea           float64     `gorm:"type:float;"`
-	CladdingArea          float64     `gorm:"type:float;"`
-	RoofArea              float64     `gorm:"type:float;"`
-	WWR                   float64     `gorm:"type:float;not null"`
-	AboveGroundFloorCount int         `gorm:"type:int;not null"`
-	UnderGroundFloorCount int         `gorm:"type:int;not null"`
-	Assemblies            []*Assembly `gorm:"many2many:building_assemblies;"`
+	Name                  string              `gorm:"type:string;unique;not null"`
+	GFA                   float64             `gorm:"type:float;"`
+	FTF                   float64             `gorm:"type:float;not null"`
+	GroundFloorArea       float64             `gorm:"type:float;not null"`
+	FacadeArea            float64             `gorm:"type:float;"`
+	GlazingArea           float64             `gorm:"type:float;"`
+	CladdingArea          float64             `gorm:"type:float;"`
+	RoofArea              float64             `gorm:"type:float;"`
+	WWR                   float64             `gorm:"type:float;not null"`
+	AboveGroundFloorCount int                 `gorm:"type:int;not null"`
+	UnderGroundFloorCount int                 `gorm:"type:int;not null"`
+	Typology              UseClass            `gorm:"type:string;"`
+	GIA                   float64             `gorm:"type:float;"`
+	StudyPeriod           int                 `gorm:"type:int;"` // years
+	Occupants             int                 `gorm:"type:int;"`
+	Bedrooms              int                 `gorm:"type:int;"`
+	Methodology           string              `gorm:"type:string;"` // ID of the methodology results are calculated by
+	SubstructureFactors   SubstructureFactors `gorm:"embedded;embeddedPrefix:substructure_"`
+	Assemblies            []*Assembly         `gorm:"many2many:building_assemblies;"`
+	AssemblyLinks         []*BuildingAssembly `gorm:"foreignKey:BuildingID;"`
+	MaterialPins          []*MaterialPin      `gorm:"foreignKey:BuildingID;constraint:OnDelete:CASCADE;"`
+	Floors                []*Floor            `gorm:"foreignKey:BuildingID;constraint:OnDelete:CASCADE;"`
+}
+
+// DefaultStudyPeriod is the reference study period in years of buildings that do not set one.
+const DefaultStudyPeriod = 60
+
+// ReferenceStudyPeriod returns the building's study period in years.
+func (b *Building) ReferenceStudyPeriod() int {
+	if b.StudyPeriod <= 0 {
+		return DefaultStudyPeriod
+	}
+	return b.StudyPeriod
 }
 
 // calculate gfa of the building
@@ -33,58 +56,386 @@ func (b *Building) CalculateGFA() float64 {
 
 // It calculates the embodied carbon of the building.
 func (b *Building) CalculateEmbodiedCarbon() float64 {
+	var total float64
+	for _, carbon := range b.CalculateEmbodiedCarbonByElement() {
+		total += carbon
+	}
+	return total
+}
+
+// ValidateGeometry checks that the building's parametric inputs describe a
+// building its geometry can be derived from.
+func (b *Building) ValidateGeometry() error {
+	if b.GroundFloorArea <= 0 {
+		return fmt.Errorf("ground floor area must be greater than 0")
+	}
+	if b.FTF < 0 {
+		return fmt.Errorf("floor to floor height cannot be negative")
+	}
+	if b.WWR < 0 || b.WWR > 1 {
+		return fmt.Errorf("window to wall ratio must be between 0 and 1")
+	}
+	if b.AboveGroundFloorCount < 0 || b.UnderGroundFloorCount < 0 {
+		return fmt.Errorf("floor counts cannot be negative")
+	}
+	return nil
+}
+
+// UpdateGeometry derives the building's areas from its parametric inputs:
+// ground floor area, floor to floor height, window to wall ratio and floor counts.
+func (b *Building) UpdateGeometry() {
 
 	// Calculate the perimeter of the building
 	perimeter := calculatePerimeter(2, b.GroundFloorArea)
 
 	// get all the areas
+	b.GFA = b.CalculateGFA()
 	b.FacadeArea = perimeter * b.FTF * float64(b.AboveGroundFloorCount)
 	b.GlazingArea = b.FacadeArea * b.WWR
 	b.CladdingArea = (1 - b.WWR) * b.FacadeArea
 	b.RoofArea = b.GroundFloorArea
+}
 
-	// Calculate the carbon emissions for each part of the building
-	// https://docs.cscale.io/readme/embodied-carbon
-	claddingEmission := b.CladdingArea * 8.8 // kgCO2/m2
-	glazingEmission := b.FacadeArea * 13.6   // kgCO2/m2
-	roofEmission := b.RoofArea * 7.7         // kgCO2/m2
+// AfterFind derives the building's areas once it is loaded, so the areas
+// calculations read match its parametric inputs.
+func (b *Building) AfterFind(tx *gorm.DB) error {
+	b.UpdateGeometry()
+	return nil
+}
+
+// RoleQuantity returns the quantity in m2 of the given geometric role, from
+// the areas derived by UpdateGeometry.
+func (b *Building) RoleQuantity(role GeometricRole) float64 {
+	switch role {
+	case RoleCladding:
+		return b.CladdingArea
+	case RoleGlazing:
+		return b.GlazingArea
+	case RoleRoof:
+		return b.RoofArea
+	case RoleGroundSlab:
+		return b.GroundFloorArea
+	case RoleUpperFloorSlab:
+		return b.GroundFloorArea * math.Max(float64(b.AboveGroundFloorCount-1), 0)
+	case RoleBasementWall:
+		return b.basementWallArea()
+	default:
+		return 0
+	}
+}
+
+// RefreshRoleQuantities updates the quantity of every assembly with a
+// geometric role from the building's current geometry, and returns the
+// updated links so they can be saved.
+func (b *Building) RefreshRoleQuantities() []*BuildingAssembly {
+	var updated []*BuildingAssembly
+	for _, link := range b.AssemblyLinks {
+		if link.Role != "" {
+			link.Quantity = b.RoleQuantity(link.Role)
+			updated = append(updated, link)
+		}
+	}
+	return updated
+}
+
+// AssemblyQuantity returns the number of units of the assembly used on the
+// building. Assemblies with a geometric role follow the building's geometry,
+// assemblies without a link count once.
+func (b *Building) AssemblyQuantity(assemblyID uint) float64 {
+	link := b.assemblyLink(assemblyID)
+	if link == nil {
+		return 1
+	}
+	if link.Role != "" {
+		return b.RoleQuantity(link.Role)
+	}
+	return link.Quantity
+}
+
+// embodiedCarbonSource is where the area rates of the embodied carbon
+// estimate of the parametric geometry come from.
+const embodiedCarbonSource = "https://docs.cscale.io/readme/embodied-carbon"
 
-	return claddingEmission + glazingEmission + roofEmission
+// areaRate is the upfront carbon rate in kgCO2e/m2 of an area of the
+// building's parametric geometry.
+type areaRate struct {
+	element Element
+	area    float64
+	rate    float64
+}
+
+// embodiedAreaRates returns the areas of the building's parametric geometry,
+// in reporting order, with the rates their embodied carbon is estimated at.
+// The geometry has to be updated first.
+func (b *Building) embodiedAreaRates() []areaRate {
+	return []areaRate{
+		{element: ElementRoof, area: b.RoofArea, rate: 7.7},
+		{element: ElementExternalWalls, area: b.CladdingArea, rate: 8.8},
+		{element: ElementWindows, area: b.GlazingArea, rate: 13.6},
+	}
+}
+
+// CalculateEmbodiedCarbonByElement calculates the embodied carbon of the
+// building's parametric geometry grouped by building element.
+func (b *Building) CalculateEmbodiedCarbonByElement() map[Element]float64 {
+
+	// Calculate the carbon emissions for each part of the building
+	totals := map[Element]float64{
+		ElementSubstructure: b.EstimateSubstructure().TotalCarbon,
+	}
+	for _, rate := range b.embodiedAreaRates() {
+		totals[rate.element] = rate.area * rate.rate
+	}
+	return totals
 }
 
-// ComputeWholeLifeCarbon calculates the total carbon impact of the building.
+// ComputeWholeLifeCarbon calculates the total carbon impact of the building,
+// including the assemblies used on its floors.
 func (b *Building) ComputeWholeLifeCarbon() float64 {
+	totalImpact := b.ComputeSharedCarbon()
+	for _, floor := range b.Floors {
+		totalImpact += floor.ComputeWholeLifeCarbon()
+	}
+	return totalImpact
+}
+
+// ComputeSharedCarbon calculates the carbon impact of the assemblies used on
+// the building as a whole rather than on one of its floors.
+func (b *Building) ComputeSharedCarbon() float64 {
 	var totalImpact float64
 	for _, assembly := range b.Assemblies {
-		totalImpact += assembly.ComputeWholeLifeCarbon()
+		totalImpact += b.AssemblyQuantity(assembly.ID) * assembly.ComputeWholeLifeCarbon()
 	}
 	return totalImpact
 }
 
+// CalculateSharedCarbonForPhase calculates the carbon impact of the assemblies
+// used on the building as a whole for specified phases.
+func (b *Building) CalculateSharedCarbonForPhase(phases ...string) float64 {
+	var total float64
+	for _, assembly := range b.Assemblies {
+		total += b.AssemblyQuantity(assembly.ID) * assembly.CalculateCarbonForPhase(phases...)
+	}
+	return total
+}
+
+// ComputeWholeLifeCarbonByElement calculates the total carbon impact of the
+// building grouped by the element each assembly is tagged with.
+func (b *Building) ComputeWholeLifeCarbonByElement() map[Element]float64 {
+	totals := make(map[Element]float64)
+	for _, assembly := range b.Assemblies {
+		totals[b.AssemblyElement(assembly.ID)] += b.AssemblyQuantity(assembly.ID) * assembly.ComputeWholeLifeCarbon()
+	}
+	for _, floor := range b.Floors {
+		for element, carbon := range floor.ComputeWholeLifeCarbonByElement() {
+			totals[element] += carbon
+		}
+	}
+	return totals
+}
+
+// CalculateCarbonByElementForPhase calculates the carbon impact of the
+// building for specified phases grouped by the element each assembly is tagged with.
+func (b *Building) CalculateCarbonByElementForPhase(phases ...string) map[Element]float64 {
+	totals := make(map[Element]float64)
+	for _, assembly := range b.Assemblies {
+		totals[b.AssemblyElement(assembly.ID)] += b.AssemblyQuantity(assembly.ID) * assembly.CalculateCarbonForPhase(phases...)
+	}
+	for _, floor := range b.Floors {
+		for element, carbon := range floor.CalculateCarbonByElementForPhase(phases...) {
+			totals[element] += carbon
+		}
+	}
+	return totals
+}
+
+// ComputeWholeLifeCarbonByUse calculates the carbon impact of the building's
+// floors grouped by use class. Assemblies used on the building as a whole
+// are not attributed to a use, see ComputeSharedCarbon.
+func (b *Building) ComputeWholeLifeCarbonByUse() map[UseClass]float64 {
+	totals := make(map[UseClass]float64)
+	for _, floor := range b.Floors {
+		for use, carbon := range floor.ComputeWholeLifeCarbonByUse() {
+			totals[use] += carbon
+		}
+	}
+	return totals
+}
+
+// CalculateCarbonByUseForPhase calculates the carbon impact of the building's
+// floors for specified phases grouped by use class.
+func (b *Building) CalculateCarbonByUseForPhase(phases ...string) map[UseClass]float64 {
+	totals := make(map[UseClass]float64)
+	for _, floor := range b.Floors {
+		for use, carbon := range floor.CalculateCarbonByUseForPhase(phases...) {
+			totals[use] += carbon
+		}
+	}
+	return totals
+}
+
+// AreaByUse returns the floor area of each use class across the building's floors.
+func (b *Building) AreaByUse() map[UseClass]float64 {
+	areas := make(map[UseClass]float64)
+	for _, floor := range b.Floors {
+		for use, area := range floor.AreaByUse() {
+			areas[use] += area
+		}
+	}
+	return areas
+}
+
+// AssemblyElement returns the element the assembly is tagged with on the building.
+func (b *Building) AssemblyElement(assemblyID uint) Element {
+	link := b.assemblyLink(assemblyID)
+	if link == nil || link.Element == "" {
+		return ElementUnclassified
+	}
+	return link.Element
+}
+
+// assemblyLink returns the building's link to the assembly, or nil if it has none.
+func (b *Building) assemblyLink(assemblyID uint) *BuildingAssembly {
+	for _, link := range b.AssemblyLinks {
+		if link.AssemblyID == assemblyID {
+			return link
+		}
+	}
+	return nil
+}
+
 // CalculateCarbonForPhase calculates the building's carbon impact for specified phases.
 func (b *Building) CalculateCarbonForPhase(phases ...string) float64 {
 	var total float64
 	for _, assembly := range b.Assemblies {
-		total += assembly.CalculateCarbonForPhase(phases...)
+		total += b.AssemblyQuantity(assembly.ID) * assembly.CalculateCarbonForPhase(phases...)
+	}
+	for _, floor := range b.Floors {
+		total += floor.CalculateCarbonForPhase(phases...)
+	}
+	return total
+}
+
+// PinMaterialVersions makes every material of the building calculate with the
+// version the building is pinned to. Materials that are not pinned yet are
+// pinned to their latest version, the new pins are returned so they can be saved.
+func (b *Building) PinMaterialVersions() []*MaterialPin {
+	pinned := make(map[uint]uint, len(b.MaterialPins))
+	for _, pin := range b.MaterialPins {
+		pinned[pin.MaterialID] = pin.MaterialVersionID
+	}
+
+	var newPins []*MaterialPin
+	for _, material := range b.materials() {
+		if versionID, ok := pinned[material.ID]; ok {
+			material.UseVersion(material.FindVersion(versionID))
+			continue
+		}
+		latest := material.LatestVersion()
+		material.UseVersion(latest)
+		if latest == nil {
+			continue
+		}
+		pin := &MaterialPin{BuildingID: b.ID, MaterialID: material.ID, MaterialVersionID: latest.ID}
+		pinned[material.ID] = latest.ID
+		newPins = append(newPins, pin)
+		b.MaterialPins = append(b.MaterialPins, pin)
+	}
+	return newPins
+}
+
+// MaterialIDs returns the IDs of the materials used on the building, once
+// each, whether the materials are loaded or not.
+func (b *Building) MaterialIDs() []uint {
+	var assemblies []*Assembly
+	assemblies = append(assemblies, b.Assemblies...)
+	for _, floor := range b.Floors {
+		assemblies = append(assemblies, floor.assemblies()...)
+	}
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, assembly := range assemblies {
+		for _, id := range assembly.materialIDs() {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// CalculationVersions returns the ID of the version each material of the
+// building calculates with, by material ID: the version it is pinned to, or
+// else its latest version, given by material ID. Materials without either are
+// left out. It agrees with the versions PinMaterialVersions selects without
+// needing the versions loaded.
+func (b *Building) CalculationVersions(latest map[uint]uint) map[uint]uint {
+	pinned := make(map[uint]uint, len(b.MaterialPins))
+	for _, pin := range b.MaterialPins {
+		pinned[pin.MaterialID] = pin.MaterialVersionID
+	}
+	versions := make(map[uint]uint)
+	for _, id := range b.MaterialIDs() {
+		if versionID, ok := pinned[id]; ok {
+			versions[id] = versionID
+		} else if versionID, ok := latest[id]; ok {
+			versions[id] = versionID
+		}
+	}
+	return versions
+}
+
+// UseMaterialVersion makes every occurrence of the material in the building
+// calculate with the given version, without changing the building's pins.
+func (b *Building) UseMaterialVersion(materialID uint, version *MaterialVersion) {
+	for _, material := range b.materials() {
+		if material.ID == materialID {
+			material.UseVersion(version)
+		}
+	}
+}
+
+// ComputeMaterialCarbon calculates the whole life carbon the given material
+// contributes to the building.
+func (b *Building) ComputeMaterialCarbon(materialID uint) float64 {
+	var total float64
+	for _, assembly := range b.Assemblies {
+		total += b.AssemblyQuantity(assembly.ID) * assembly.ComputeMaterialCarbon(materialID)
+	}
+	for _, floor := range b.Floors {
+		total += floor.ComputeMaterialCarbon(materialID)
 	}
 	return total
 }
 
-// TODO: #2 needs alot of work
-// convert values to metric or imperial and whether its tco2, kgco2, kgco2/m2, kgco2/m2/year
-func (b *Building) ConvertValues(isMetric bool, option int) Building {
+// materials returns every material used by the building's assemblies,
+// including those on its floors.
+func (b *Building) materials() []*Material {
+	var materials []*Material
 	for _, assembly := range b.Assemblies {
-		assembly.ConvertValues(isMetric, option)
+		materials = append(materials, assembly.materials()...)
 	}
-	return *b
+	for _, floor := range b.Floors {
+		for _, assembly := range floor.assemblies() {
+			materials = append(materials, assembly.materials()...)
+		}
+	}
+	return materials
+}
+
+// basementWallArea returns the area of the walls enclosing the underground floors.
+func (b *Building) basementWallArea() float64 {
+	return calculatePerimeter(2, b.GroundFloorArea) * b.FTF * float64(b.UnderGroundFloorCount)
 }
 
 // calculate perimeter of the building given area and aspect ratio
+// a building without a footprint has no perimeter, see ValidateGeometry
 func calculatePerimeter(aspectRatio, area float64) float64 {
 
 	// error handling
 	if aspectRatio <= 0 || area <= 0 {
-		panic("aspect ratio and area must be greater than 0")
+		return 0
 	}
 
 	// Calculate width and height
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobStatus is where a calculation job is in its life cycle.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// IsFinished reports whether the job will not change anymore.
+func (s JobStatus) IsFinished() bool {
+	return s == JobDone || s == JobFailed || s == JobCancelled
+}
+
+// JobKind is what a calculation job calculates.
+type JobKind string
+
+const (
+	// JobBuilding calculates the total carbon of a building
+	JobBuilding JobKind = "building"
+	// JobPortfolio calculates the total carbon of a set of buildings
+	JobPortfolio JobKind = "portfolio"
+	// JobScenario compares the scenarios of a building
+	JobScenario JobKind = "scenario"
+)
+
+// IsValid reports whether the kind is one of the known job kinds.
+func (k JobKind) IsValid() bool {
+	return k == JobBuilding || k == JobPortfolio || k == JobScenario
+}
+
+// CalculationJob is a calculation that is run in the background: the request
+// it was submitted with, its status, its progress from 0 to 1 and its result
+// or the error it failed with. Jobs are persisted, so that queued jobs and
+// those interrupted by a restart are run when the service starts again.
+// A running job is leased by the instance of the service running it, which
+// renews the lease until the job is finished; a job whose lease has expired
+// was interrupted and is queued again.
+type CalculationJob struct {
+	gorm.Model
+	Kind           JobKind         `gorm:"type:string;not null"`
+	Status         JobStatus       `gorm:"type:string;index;not null"`
+	Request        json.RawMessage `gorm:"type:jsonb;"`
+	Progress       float64         `gorm:"type:float;"`
+	Result         json.RawMessage `gorm:"type:jsonb;"`
+	Error          string          `gorm:"type:string;"`
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+	Owner          string `gorm:"type:string;index;"`
+	LeaseExpiresAt *time.Time
+}
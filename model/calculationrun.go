@@ -0,0 +1,80 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalculationRun is a persisted whole life carbon calculation of a building:
+// a hash of everything the result depends on, the methodology it was
+// calculated by, when, and the absolute result with its full breakdown when
+// the calculation was detailed. A calculation whose inputs hash matches a
+// previous run reuses its result, unless it is detailed and the run is not,
+// and is only recorded as a new run when the building was calculated with
+// other inputs since, so that the runs of a building trace its inputs over time.
+type CalculationRun struct {
+	gorm.Model
+	BuildingID         uint            `gorm:"index:idx_run_inputs;not null"`
+	InputsHash         string          `gorm:"type:string;index:idx_run_inputs;not null"`
+	Methodology        string          `gorm:"type:string;"`
+	MethodologyVersion string          `gorm:"type:string;"`
+	CalculatedAt       time.Time       `gorm:"not null"`
+	TotalCarbon        float64         `gorm:"type:float;"`
+	Result             json.RawMessage `gorm:"type:jsonb;"`
+	Breakdown          json.RawMessage `gorm:"type:jsonb;"`
+}
+
+// CalculationInputsHash returns a hash of what the calculation of the building
+// depends on: its parameters, floors, zones and assemblies with their contents,
+// the version each material calculates with, by material ID, see
+// CalculationVersions, and the methodology. Bookkeeping such as timestamps is
+// left out, and so are the materials, as their values come from their
+// versions, which never change, so the hash only changes when something that
+// can change the result does.
+func CalculationInputsHash(b *Building, versions map[uint]uint, methodology *Methodology) (string, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	var building any
+	if err := json.Unmarshal(data, &building); err != nil {
+		return "", err
+	}
+	inputs, err := json.Marshal(struct {
+		Building    any
+		Versions    map[uint]uint
+		Methodology *Methodology
+	}{withoutBookkeeping(building), versions, methodology})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(inputs)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// bookkeepingFields are the fields of a building and what it is made of that
+// its calculation does not depend on, or that are hashed apart.
+var bookkeepingFields = []string{"CreatedAt", "UpdatedAt", "DeletedAt", "Material", "MaterialPins"}
+
+// withoutBookkeeping removes the bookkeeping fields from a decoded JSON value
+// at every level.
+func withoutBookkeeping(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, field := range bookkeepingFields {
+			delete(v, field)
+		}
+		for key, child := range v {
+			v[key] = withoutBookkeeping(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = withoutBookkeeping(child)
+		}
+	}
+	return value
+}
@@ -0,0 +1,27 @@
+package model
+
+// AssemblyComponent is a sub-assembly used inside another assembly, e.g. a
+// window inside a wall, with the quantity of the sub-assembly per parent assembly.
+type AssemblyComponent struct {
+	ParentID uint      `gorm:"primaryKey"`
+	ChildID  uint      `gorm:"primaryKey"`
+	Child    *Assembly `gorm:"foreignKey:ChildID;constraint:OnDelete:CASCADE;"`
+	Position int       `gorm:"type:int;not null;default:0"`
+	Quantity float64   `gorm:"type:float;not null;default:1"`
+}
+
+// ComputeWholeLifeCarbon calculates the whole life carbon of the sub-assembly's quantity.
+func (c AssemblyComponent) ComputeWholeLifeCarbon() float64 {
+	if c.Child == nil {
+		return 0
+	}
+	return c.Quantity * c.Child.ComputeWholeLifeCarbon()
+}
+
+// CalculateCarbonForPhase calculates the carbon of the sub-assembly's quantity for specified phases.
+func (c AssemblyComponent) CalculateCarbonForPhase(phases ...string) float64 {
+	if c.Child == nil {
+		return 0
+	}
+	return c.Quantity * c.Child.CalculateCarbonForPhase(phases...)
+}
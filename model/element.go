@@ -0,0 +1,54 @@
+package model
+
+// Element is a building element category following the RICS NRM / RIBA
+// structure that carbon results are reported by.
+type Element string
+
+const (
+	ElementSubstructure  Element = "substructure"
+	ElementFrame         Element = "frame"
+	ElementUpperFloors   Element = "upper_floors"
+	ElementRoof          Element = "roof"
+	ElementExternalWalls Element = "external_walls"
+	ElementWindows       Element = "windows"
+	ElementInternalWalls Element = "internal_walls"
+	ElementFinishes      Element = "finishes"
+	ElementServices      Element = "services"
+	ElementUnclassified  Element = "unclassified"
+)
+
+// Elements lists all element categories in reporting order.
+var Elements = []Element{
+	ElementSubstructure,
+	ElementFrame,
+	ElementUpperFloors,
+	ElementRoof,
+	ElementExternalWalls,
+	ElementWindows,
+	ElementInternalWalls,
+	ElementFinishes,
+	ElementServices,
+	ElementUnclassified,
+}
+
+// IsValid reports whether the element is one of the known categories.
+func (e Element) IsValid() bool {
+	for _, element := range Elements {
+		if e == element {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildingAssembly is the join between a building and an assembly used on it,
+// tagged with the building element the assembly belongs to.
+// Quantity is the number of assembly units used on the building, for an
+// assembly with a geometric Role it is derived from the building's geometry.
+type BuildingAssembly struct {
+	BuildingID uint          `gorm:"primaryKey"`
+	AssemblyID uint          `gorm:"primaryKey"`
+	Element    Element       `gorm:"type:string;not null;default:'unclassified'"`
+	Role       GeometricRole `gorm:"type:string;"`
+	Quantity   float64       `gorm:"type:float;not null;default:1"`
+}
@@ -0,0 +1,230 @@
+package model
+
+import "gorm.io/gorm"
+
+// UseClass is the use of a floor or a zone of a building.
+type UseClass string
+
+const (
+	UseOffice      UseClass = "office"
+	UseResidential UseClass = "residential"
+	UseRetail      UseClass = "retail"
+	UseEducation   UseClass = "education"
+	UseHotel       UseClass = "hotel"
+	UseHealthcare  UseClass = "healthcare"
+	UseIndustrial  UseClass = "industrial"
+	UseParking     UseClass = "parking"
+	UsePlant       UseClass = "plant"
+	UseOther       UseClass = "other"
+)
+
+// UseClasses lists all use classes in reporting order.
+var UseClasses = []UseClass{
+	UseOffice,
+	UseResidential,
+	UseRetail,
+	UseEducation,
+	UseHotel,
+	UseHealthcare,
+	UseIndustrial,
+	UseParking,
+	UsePlant,
+	UseOther,
+}
+
+// IsValid reports whether the use is one of the known use classes.
+func (u UseClass) IsValid() bool {
+	for _, use := range UseClasses {
+		if u == use {
+			return true
+		}
+	}
+	return false
+}
+
+// Floor is a storey of a building with its own area, height and use.
+// Level 0 is the ground floor, basement levels are negative.
+type Floor struct {
+	gorm.Model
+	BuildingID    uint             `gorm:"index;not null"`
+	Name          string           `gorm:"type:string;not null"`
+	Level         int              `gorm:"type:int;not null"`
+	Area          float64          `gorm:"type:float;not null"` // m2
+	Height        float64          `gorm:"type:float;not null"` // m
+	Use           UseClass         `gorm:"type:string;not null;default:'other'"`
+	Zones         []*Zone          `gorm:"foreignKey:FloorID;constraint:OnDelete:CASCADE;"`
+	AssemblyLinks []*FloorAssembly `gorm:"foreignKey:FloorID;constraint:OnDelete:CASCADE;"`
+}
+
+// Zone is a part of a floor with a use of its own, e.g. the retail units on
+// the ground floor of a residential tower.
+type Zone struct {
+	gorm.Model
+	FloorID uint     `gorm:"index;not null"`
+	Name    string   `gorm:"type:string;not null"`
+	Area    float64  `gorm:"type:float;not null"` // m2
+	Use     UseClass `gorm:"type:string;not null;default:'other'"`
+}
+
+// FloorAssembly is an assembly used on a floor, or on one zone of the floor
+// when ZoneID is set, with the number of assembly units used and the building
+// element it belongs to.
+type FloorAssembly struct {
+	gorm.Model
+	FloorID    uint      `gorm:"index;not null"`
+	ZoneID     *uint     `gorm:"index;"`
+	AssemblyID uint      `gorm:"not null"`
+	Assembly   *Assembly `gorm:"foreignKey:AssemblyID;"`
+	Element    Element   `gorm:"type:string;not null;default:'unclassified'"`
+	Quantity   float64   `gorm:"type:float;not null;default:1"`
+}
+
+// ComputeWholeLifeCarbon calculates the whole life carbon of the assembly's quantity.
+func (fa *FloorAssembly) ComputeWholeLifeCarbon() float64 {
+	if fa.Assembly == nil {
+		return 0
+	}
+	return fa.Quantity * fa.Assembly.ComputeWholeLifeCarbon()
+}
+
+// CalculateCarbonForPhase calculates the carbon of the assembly's quantity for specified phases.
+func (fa *FloorAssembly) CalculateCarbonForPhase(phases ...string) float64 {
+	if fa.Assembly == nil {
+		return 0
+	}
+	return fa.Quantity * fa.Assembly.CalculateCarbonForPhase(phases...)
+}
+
+// ComputeWholeLifeCarbon calculates the total carbon impact of the assemblies on the floor.
+func (f *Floor) ComputeWholeLifeCarbon() float64 {
+	var total float64
+	for _, link := range f.AssemblyLinks {
+		total += link.ComputeWholeLifeCarbon()
+	}
+	return total
+}
+
+// CalculateCarbonForPhase calculates the floor's carbon impact for specified phases.
+func (f *Floor) CalculateCarbonForPhase(phases ...string) float64 {
+	var total float64
+	for _, link := range f.AssemblyLinks {
+		total += link.CalculateCarbonForPhase(phases...)
+	}
+	return total
+}
+
+// ComputeWholeLifeCarbonByElement calculates the total carbon impact of the
+// floor grouped by the element each assembly is tagged with.
+func (f *Floor) ComputeWholeLifeCarbonByElement() map[Element]float64 {
+	totals := make(map[Element]float64)
+	for _, link := range f.AssemblyLinks {
+		element := link.Element
+		if element == "" {
+			element = ElementUnclassified
+		}
+		totals[element] += link.ComputeWholeLifeCarbon()
+	}
+	return totals
+}
+
+// CalculateCarbonByElementForPhase calculates the floor's carbon impact for
+// specified phases grouped by the element each assembly is tagged with.
+func (f *Floor) CalculateCarbonByElementForPhase(phases ...string) map[Element]float64 {
+	totals := make(map[Element]float64)
+	for _, link := range f.AssemblyLinks {
+		element := link.Element
+		if element == "" {
+			element = ElementUnclassified
+		}
+		totals[element] += link.CalculateCarbonForPhase(phases...)
+	}
+	return totals
+}
+
+// ComputeWholeLifeCarbonByUse calculates the total carbon impact of the floor
+// grouped by use class. Assemblies on a zone count towards the zone's use,
+// the others towards the floor's use.
+func (f *Floor) ComputeWholeLifeCarbonByUse() map[UseClass]float64 {
+	totals := make(map[UseClass]float64)
+	for _, link := range f.AssemblyLinks {
+		totals[f.linkUse(link)] += link.ComputeWholeLifeCarbon()
+	}
+	return totals
+}
+
+// CalculateCarbonByUseForPhase calculates the floor's carbon impact for
+// specified phases grouped by use class.
+func (f *Floor) CalculateCarbonByUseForPhase(phases ...string) map[UseClass]float64 {
+	totals := make(map[UseClass]float64)
+	for _, link := range f.AssemblyLinks {
+		totals[f.linkUse(link)] += link.CalculateCarbonForPhase(phases...)
+	}
+	return totals
+}
+
+// AreaByUse returns the floor area of each use class on the floor. Zones
+// count towards their own use, the rest of the floor towards the floor's use.
+func (f *Floor) AreaByUse() map[UseClass]float64 {
+	areas := make(map[UseClass]float64)
+	remaining := f.Area
+	for _, zone := range f.Zones {
+		areas[zone.Use] += zone.Area
+		remaining -= zone.Area
+	}
+	if remaining > 0 {
+		areas[f.Use] += remaining
+	}
+	return areas
+}
+
+// ComputeMaterialCarbon calculates the whole life carbon the given material
+// contributes to the floor.
+func (f *Floor) ComputeMaterialCarbon(materialID uint) float64 {
+	var total float64
+	for _, link := range f.AssemblyLinks {
+		if link.Assembly != nil {
+			total += link.Quantity * link.Assembly.ComputeMaterialCarbon(materialID)
+		}
+	}
+	return total
+}
+
+// ZonedArea returns the area of the floor covered by its zones.
+func (f *Floor) ZonedArea() float64 {
+	var area float64
+	for _, zone := range f.Zones {
+		area += zone.Area
+	}
+	return area
+}
+
+// FindZone returns the zone of the floor with the given ID, or nil if there is none.
+func (f *Floor) FindZone(id uint) *Zone {
+	for _, zone := range f.Zones {
+		if zone.ID == id {
+			return zone
+		}
+	}
+	return nil
+}
+
+// linkUse returns the use class an assembly on the floor counts towards.
+func (f *Floor) linkUse(link *FloorAssembly) UseClass {
+	if link.ZoneID != nil {
+		if zone := f.FindZone(*link.ZoneID); zone != nil {
+			return zone.Use
+		}
+	}
+	return f.Use
+}
+
+// assemblies returns the assemblies used on the floor.
+func (f *Floor) assemblies() []*Assembly {
+	var assemblies []*Assembly
+	for _, link := range f.AssemblyLinks {
+		if link.Assembly != nil {
+			assemblies = append(assemblies, link.Assembly)
+		}
+	}
+	return assemblies
+}
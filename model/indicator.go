@@ -1,6 +1,11 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 type Indicator interface {
 	A1toA5() float64
@@ -10,8 +15,71 @@ type Indicator interface {
 	GetIndicators() []float64
 }
 
-type IndicatorConverter interface {
-	ConvertValues(isMetric bool, option int)
+// Life cycle modules carbon is reported by, see CalculateCarbonForPhase.
+const (
+	ModuleA1toA5 = "A1toA5" // product and construction
+	ModuleB1toB7 = "B1toB7" // use
+	ModuleC1toC4 = "C1toC4" // end of life
+)
+
+// Modules lists the life cycle modules in reporting order.
+var Modules = []string{ModuleA1toA5, ModuleB1toB7, ModuleC1toC4}
+
+// ModuleGroups maps the life cycle modules carbon is reported by to the
+// individual modules they add up.
+var ModuleGroups = map[string]ModuleScope{
+	ModuleA1toA5: "A1-A5",
+	ModuleB1toB7: "B1-B7",
+	ModuleC1toC4: "C1-C4",
+}
+
+// IndicatorModules lists the individual life cycle modules in the order of GetIndicators.
+var IndicatorModules = []string{"A1", "A2", "A3", "A4", "A5", "B1", "B2", "B3", "B4", "B5", "B6", "B7", "C1", "C2", "C3", "C4", "D"}
+
+// ModuleValue returns the value of one individual module of the indicator, e.g. "B4".
+func ModuleValue(indicator Indicator, module string) float64 {
+	if i := moduleIndex(module); i >= 0 {
+		return indicator.GetIndicators()[i]
+	}
+	return 0
+}
+
+// ModuleScope is a set of life cycle modules written as comma separated
+// modules or ranges of modules, e.g. "A1-A5" or "A1-A5,B1-B5,C1-C4".
+type ModuleScope string
+
+const (
+	ScopeUpfront         ModuleScope = "A1-A5"
+	ScopeEmbodied        ModuleScope = "A1-A5,B1-B5,C1-C4"
+	ScopeUseAndEndOfLife ModuleScope = "B1-B5,C1-C4"
+	ScopeWholeLife       ModuleScope = "A1-C4"
+)
+
+// Modules returns the individual modules in the scope.
+func (s ModuleScope) Modules() ([]string, error) {
+	var modules []string
+	for _, part := range strings.Split(string(s), ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			last = first
+		}
+		from, to := moduleIndex(first), moduleIndex(last)
+		if from < 0 || to < 0 || from > to {
+			return nil, fmt.Errorf("invalid module scope '%s'", s)
+		}
+		modules = append(modules, IndicatorModules[from:to+1]...)
+	}
+	return modules, nil
+}
+
+// moduleIndex returns the position of the module in IndicatorModules, or -1.
+func moduleIndex(module string) int {
+	for i, name := range IndicatorModules {
+		if name == module {
+			return i
+		}
+	}
+	return -1
 }
 
 // carbon footprint of a material with its carbon footprint
@@ -19,29 +87,33 @@ type IndicatorConverter interface {
 // for each phase of the LCA
 
 var _ Indicator = &Gwp{}
-var _ IndicatorConverter = &Gwp{}
 
 type Gwp struct {
 	gorm.Model
-	MaterialID uint    `gorm:"index;"`
-	IsMetric   bool    `gorm:"type:bool;"`
-	A1         float64 `gorm:"type:decimal;"`
-	A2         float64 `gorm:"type:decimal;"`
-	A3         float64 `gorm:"type:decimal;"`
-	A4         float64 `gorm:"type:decimal;"`
-	A5         float64 `gorm:"type:decimal;"`
-	B1         float64 `gorm:"type:decimal;"`
-	B2         float64 `gorm:"type:decimal;"`
-	B3         float64 `gorm:"type:decimal;"`
-	B4         float64 `gorm:"type:decimal;"`
-	B5         float64 `gorm:"type:decimal;"`
-	B6         float64 `gorm:"type:decimal;"`
-	B7         float64 `gorm:"type:decimal;"`
-	C1         float64 `gorm:"type:decimal;"`
-	C2         float64 `gorm:"type:decimal;"`
-	C3         float64 `gorm:"type:decimal;"`
-	C4         float64 `gorm:"type:decimal;"`
-	D          float64 `gorm:"type:decimal;"`
+	MaterialID        uint    `gorm:"index;"`
+	MaterialVersionID uint    `gorm:"index;"`
+	IsMetric          bool    `gorm:"type:bool;"`
+	A1                float64 `gorm:"type:decimal;"`
+	A2                float64 `gorm:"type:decimal;"`
+	A3                float64 `gorm:"type:decimal;"`
+	A4                float64 `gorm:"type:decimal;"`
+	A5                float64 `gorm:"type:decimal;"`
+	B1                float64 `gorm:"type:decimal;"`
+	B2                float64 `gorm:"type:decimal;"`
+	B3                float64 `gorm:"type:decimal;"`
+	B4                float64 `gorm:"type:decimal;"`
+	B5                float64 `gorm:"type:decimal;"`
+	B6                float64 `gorm:"type:decimal;"`
+	B7                float64 `gorm:"type:decimal;"`
+	C1                float64 `gorm:"type:decimal;"`
+	C2                float64 `gorm:"type:decimal;"`
+	C3                float64 `gorm:"type:decimal;"`
+	C4                float64 `gorm:"type:decimal;"`
+	D                 float64 `gorm:"type:decimal;"`
+
+	// Biogenic is the biogenic carbon stored in the product per declared unit,
+	// negative and already counted in A1-A3 with its release in C3-C4 (-1/+1)
+	Biogenic float64 `gorm:"type:decimal;"`
 }
 
 // Returns the sum of all phases from A1 to A5
@@ -63,70 +135,3 @@ func (g Gwp) C1toC4() float64 {
 func (g Gwp) GetIndicators() []float64 {
 	return []float64{g.A1, g.A2, g.A3, g.A4, g.A5, g.B1, g.B2, g.B3, g.B4, g.B5, g.B6, g.B7, g.C1, g.C2, g.C3, g.C4, g.D}
 }
-
-// ConvertValues converts the carbon values of the material to metric or imperial
-// and whether its tco2, kgco2, kgco2/m2, kgco2/m2/year
-func (g *Gwp) ConvertValues(isMetric bool, option int) {
-	if isMetric {
-		g.IsMetric = true
-	} else {
-		g.IsMetric = false
-	}
-
-	switch option {
-	case 1:
-		g.A1 = g.A1 / 1000
-		g.A2 = g.A2 / 1000
-		g.A3 = g.A3 / 1000
-		g.A4 = g.A4 / 1000
-		g.A5 = g.A5 / 1000
-		g.B1 = g.B1 / 1000
-		g.B2 = g.B2 / 1000
-		g.B3 = g.B3 / 1000
-		g.B4 = g.B4 / 1000
-		g.B5 = g.B5 / 1000
-		g.B6 = g.B6 / 1000
-		g.B7 = g.B7 / 1000
-		g.C1 = g.C1 / 1000
-		g.C2 = g.C2 / 1000
-		g.C3 = g.C3 / 1000
-		g.C4 = g.C4 / 1000
-		g.D = g.D / 1000
-	case 2:
-		g.A1 = g.A1 * 1000
-		g.A2 = g.A2 * 1000
-		g.A3 = g.A3 * 1000
-		g.A4 = g.A4 * 1000
-		g.A5 = g.A5 * 1000
-		g.B1 = g.B1 * 1000
-		g.B2 = g.B2 * 1000
-		g.B3 = g.B3 * 1000
-		g.B4 = g.B4 * 1000
-		g.B5 = g.B5 * 1000
-		g.B6 = g.B6 * 1000
-		g.B7 = g.B7 * 1000
-		g.C1 = g.C1 * 1000
-		g.C2 = g.C2 * 1000
-		g.C3 = g.C3 * 1000
-		g.C4 = g.C4 * 1000
-		g.D = g.D * 1000
-	case 3:
-		g.A1 = g.A1 / 1000
-		g.A2 = g.A2 / 1000
-		g.A3 = g.A3 / 1000
-		g.A4 = g.A4 / 1000
-		g.A5 = g.A5 / 1000
-		g.B1 = g.B1 / 1000
-		g.B2 = g.B2 / 1000
-		g.B3 = g.B3 / 1000
-		g.B4 = g.B4 / 1000
-		g.B5 = g.B5 / 1000
-		g.B6 = g.B6 / 1000
-		g.B7 = g.B7 / 1000
-		g.C1 = g.C1 / 1000
-		g.C2 = g.C2 / 1000
-		g.C3 = g.C3 / 1000
-		g.C4 = g.C4 / 1000
-		g.D = g.D / 1000
-	}
-}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// AssemblyLayer is one layer of a layered build-up such as a wall, floor or roof,
+// e.g. 200 mm of CLT. A layer is defined either by its Thickness in m, or by a
+// Rate in RateUnit per m2 of assembly (e.g. 4 kg/m2 of fixings).
+// DeclaredQuantity is the layer's quantity per m2 of assembly expressed in the
+// material's declared unit, it is derived from the thickness or rate on save.
+type AssemblyLayer struct {
+	gorm.Model
+	AssemblyID       uint `gorm:"index;not null"`
+	Position         int  `gorm:"type:int;not null"`
+	MaterialID       uint `gorm:"index;not null"`
+	Material         *Material
+	Thickness        float64 `gorm:"type:float;"` // m
+	Rate             float64 `gorm:"type:float;"`
+	RateUnit         string  `gorm:"type:string;"`
+	DeclaredQuantity float64 `gorm:"type:float;"`
+}
+
+// ComputeWholeLifeCarbon calculates the whole life carbon of the layer per m2 of assembly.
+func (l AssemblyLayer) ComputeWholeLifeCarbon() float64 {
+	if l.Material == nil {
+		return 0
+	}
+	return l.DeclaredQuantity * l.Material.ComputeWholeLifeCarbon()
+}
+
+// CalculateCarbonForPhase calculates the carbon of the layer per m2 of assembly for specified phases.
+func (l AssemblyLayer) CalculateCarbonForPhase(phases ...string) float64 {
+	if l.Material == nil {
+		return 0
+	}
+	return l.DeclaredQuantity * l.Material.CalculateCarbonForPhase(phases...)
+}
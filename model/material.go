@@ -1,6 +1,8 @@
 package model
 
 import (
+	"encoding/json"
+
 	"gorm.io/gorm"
 )
 
@@ -10,19 +12,87 @@ var _ ByIndicatorCarbonCalculator = &Material{}
 
 // Assuming Indicator is defined somewhere in your model package
 
+// DefaultDeclaredUnit is the declared unit of materials created without one.
+const DefaultDeclaredUnit = "kg"
+
 // Material represents a building material with its carbon footprint
 // It contains an Indicator which represents the carbon footprint of the material
 // It also has a many-to-many relationship with Assembly
 // The Material struct implements the CarbonImpactCalculator interface
+// The EPD data itself lives in immutable MaterialVersions, Indicator holds the
+// values of the version currently in use and is not persisted on the material.
+// GWP values are per DeclaredUnit (kg, m3, m2 or piece), the conversion properties
+// allow quantities in other units to be converted to the declared unit.
 type Material struct {
 	gorm.Model
-	Name       string
-	Indicator  Indicator   `gorm:"foreignKey:MaterialID;constraint:OnDelete:CASCADE;"`
-	Assemblies []*Assembly `gorm:"many2many:assembly_materials;"`
+	Name         string
+	DeclaredUnit string             `gorm:"type:string;not null;default:'kg';"`
+	Density      float64            `gorm:"type:float;"` // kg/m3
+	Thickness    float64            `gorm:"type:float;"` // m
+	MassPerArea  float64            `gorm:"type:float;"` // kg/m2
+	MassPerPiece float64            `gorm:"type:float;"` // kg
+	Indicator    Indicator          `gorm:"-"`
+	Versions     []*MaterialVersion `gorm:"foreignKey:MaterialID;constraint:OnDelete:CASCADE;"`
+	Assemblies   []*Assembly        `gorm:"many2many:assembly_materials;"`
+}
+
+// LatestVersion returns the most recent version of the material's EPD data,
+// or nil if no version has been loaded.
+func (m *Material) LatestVersion() *MaterialVersion {
+	var latest *MaterialVersion
+	for _, version := range m.Versions {
+		if latest == nil || version.Version > latest.Version {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// FindVersion returns the loaded version with the given ID, or nil if it is not loaded.
+func (m *Material) FindVersion(versionID uint) *MaterialVersion {
+	for _, version := range m.Versions {
+		if version.ID == versionID {
+			return version
+		}
+	}
+	return nil
+}
+
+// UseVersion makes the material calculate with the values of the given version.
+func (m *Material) UseVersion(version *MaterialVersion) {
+	if version == nil {
+		m.Indicator = nil
+		return
+	}
+	m.Indicator = version.Gwp
+}
+
+// CurrentVersion returns the version the material calculates with, or nil
+// if it has none or the version is not loaded.
+func (m *Material) CurrentVersion() *MaterialVersion {
+	gwp, ok := m.Indicator.(Gwp)
+	if !ok {
+		return nil
+	}
+	return m.FindVersion(gwp.MaterialVersionID)
+}
+
+// UnmarshalJSON decodes a material without its Indicator, which cannot be
+// decoded into the interface; UseVersion selects it again from the versions.
+func (m *Material) UnmarshalJSON(data []byte) error {
+	type material Material
+	decoded := struct {
+		*material
+		Indicator json.RawMessage
+	}{material: (*material)(m)}
+	return json.Unmarshal(data, &decoded)
 }
 
 // ComputeCarbonImpact calculates the carbon impact of the material
 func (m Material) ComputeWholeLifeCarbon() float64 {
+	if m.Indicator == nil {
+		return 0
+	}
 	return m.Indicator.A1toA5() + m.Indicator.B1toB7() + m.Indicator.C1toC4()
 }
 
@@ -30,46 +100,23 @@ func (m Material) ComputeWholeLifeCarbon() float64 {
 // examples:
 // material.CalculateCarbonForPhase("A1toA5") -> returns the carbon impact of the material for phases A1 to A5
 // material.CalculateCarbonForPhase("A1toA5", "B1toB7") -> returns the carbon impact of the material for phases A1 to A5 and B1 to B7
+// material.CalculateCarbonForPhase("B4", "D") -> returns the carbon impact of the material for the individual modules B4 and D
 func (m Material) CalculateCarbonForPhase(phases ...string) float64 {
 	var total float64
+	if m.Indicator == nil {
+		return total
+	}
 	for _, phase := range phases {
 		switch phase {
-		case "A1toA5":
+		case ModuleA1toA5:
 			total += m.Indicator.A1toA5()
-		case "B1toB7":
+		case ModuleB1toB7:
 			total += m.Indicator.B1toB7()
-		case "C1toC4":
+		case ModuleC1toC4:
 			total += m.Indicator.C1toC4()
-
+		default:
+			total += ModuleValue(m.Indicator, phase)
 		}
 	}
 	return total
 }
-
-// ConvertValues converts the carbon values of the material to metric or imperial
-// and whether its tco2, kgco2, kgco2/m2, kgco2/m2/year
-func (m *Material) ConvertValues(isMetric bool, option int) Material {
-	if isMetric {
-		m.Indicator.IsMetric = true
-	} else {
-		m.Indicator.IsMetric = false
-	}
-
-	switch option {
-	case 1:
-		m.Indicator.A1toA5 = m.Indicator.A1toA5() * 1000
-		m.Indicator.B1toB7() = m.Indicator.B1toB7() * 1000
-		m.Indicator.C1toC4() = m.Indicator.C1toC4() * 1000
-	case 2:
-		m.Indicator.A1toA5() = m.Indicator.A1toA5() * 1000
-		m.Indicator.B1toB7() = m.Indicator.B1toB7() * 1000
-		m.Indicator.C1toC4() = m.Indicator.C1toC4() * 1000
-	case 3:
-		m.Indicator.A1toA5() = m.Indicator.A1toA5() / 1000
-		m.Indicator.B1toB7() = m.Indicator.B1toB7() / 1000
-		m.Indicator.C1toC4() = m.Indicator.C1toC4() / 1000
-
-	}
-
-	return *m
-}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// MaterialVersion is an immutable revision of a material's EPD data.
+// A new version is created every time the EPD is renewed, so a building that
+// was calculated with an older version keeps its results until it is upgraded.
+type MaterialVersion struct {
+	gorm.Model
+	MaterialID uint   `gorm:"uniqueIndex:idx_material_version;not null"`
+	Version    int    `gorm:"type:int;uniqueIndex:idx_material_version;not null"`
+	EpdSource  string `gorm:"type:string;"`
+	Gwp        Gwp    `gorm:"foreignKey:MaterialVersionID;constraint:OnDelete:CASCADE;"`
+}
+
+// MaterialPin records the version of a material a building was calculated with.
+type MaterialPin struct {
+	gorm.Model
+	BuildingID        uint `gorm:"uniqueIndex:idx_building_material;not null"`
+	MaterialID        uint `gorm:"uniqueIndex:idx_building_material;not null"`
+	MaterialVersionID uint `gorm:"index;not null"`
+}
@@ -0,0 +1,81 @@
+package model
+
+import "math"
+
+// BiogenicAccounting is how the carbon stored in bio-based materials is counted.
+type BiogenicAccounting string
+
+const (
+	// BiogenicMinusOnePlusOne counts the uptake in A1-A3 and its release at end
+	// of life, as declared in the EPD.
+	BiogenicMinusOnePlusOne BiogenicAccounting = "-1/+1"
+	// BiogenicZeroZero leaves both the uptake and its release out.
+	BiogenicZeroZero BiogenicAccounting = "0/0"
+)
+
+// Methodology is a rule set carbon results are calculated by: the modules
+// included in the total, whether module D is added to it, how biogenic carbon
+// is counted, the uplift applied to the upfront modules and the reference
+// study period of buildings that do not set their own.
+type Methodology struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Version     string             `json:"version"`
+	Scope       ModuleScope        `json:"scope"`
+	IncludeD    bool               `json:"includeD"`
+	Biogenic    BiogenicAccounting `json:"biogenic"`
+	Uplift      float64            `json:"uplift"` // fraction added to A1-A5, e.g. 0.15
+	StudyPeriod int                `json:"studyPeriod"`
+}
+
+// Modules returns the individual modules included in the total.
+func (m *Methodology) Modules() ([]string, error) {
+	modules, err := m.Scope.Modules()
+	if err != nil {
+		return nil, err
+	}
+	if m.IncludeD {
+		modules = append(modules, "D")
+	}
+	return modules, nil
+}
+
+// Adjust returns the values of the indicator under the methodology's
+// biogenic accounting and uplift. The indicator itself is not modified.
+func (m *Methodology) Adjust(indicator Indicator) Indicator {
+	gwp, ok := indicator.(Gwp)
+	if !ok {
+		return indicator
+	}
+	if m.Biogenic == BiogenicZeroZero && gwp.Biogenic < 0 {
+		// remove the uptake from A1 and its release from C3, then C4
+		release := -gwp.Biogenic
+		gwp.A1 += release
+		fromC3 := math.Min(release, math.Max(gwp.C3, 0))
+		gwp.C3 -= fromC3
+		gwp.C4 -= release - fromC3
+	}
+	if m.Uplift != 0 {
+		factor := 1 + m.Uplift
+		gwp.A1 *= factor
+		gwp.A2 *= factor
+		gwp.A3 *= factor
+		gwp.A4 *= factor
+		gwp.A5 *= factor
+	}
+	return gwp
+}
+
+// UseMethodology makes every material of the building calculate with its
+// current values adjusted by the methodology. Materials shared by several
+// assemblies or links are adjusted once. Material versions have to be pinned
+// first, see PinMaterialVersions.
+func (b *Building) UseMethodology(methodology *Methodology) {
+	adjusted := make(map[*Material]bool)
+	for _, material := range b.materials() {
+		if material.Indicator != nil && !adjusted[material] {
+			material.Indicator = methodology.Adjust(material.Indicator)
+			adjusted[material] = true
+		}
+	}
+}
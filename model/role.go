@@ -0,0 +1,39 @@
+package model
+
+// GeometricRole is the part of a building's parametric geometry an assembly
+// covers. The quantity of an assembly with a role is derived from the
+// building's geometry instead of being entered by hand.
+type GeometricRole string
+
+const (
+	RoleCladding       GeometricRole = "cladding"
+	RoleGlazing        GeometricRole = "glazing"
+	RoleRoof           GeometricRole = "roof"
+	RoleGroundSlab     GeometricRole = "ground_slab"
+	RoleUpperFloorSlab GeometricRole = "upper_floor_slab"
+	RoleBasementWall   GeometricRole = "basement_wall"
+)
+
+// roleElements maps each role to the building element it is reported under.
+var roleElements = map[GeometricRole]Element{
+	RoleCladding:       ElementExternalWalls,
+	RoleGlazing:        ElementWindows,
+	RoleRoof:           ElementRoof,
+	RoleGroundSlab:     ElementSubstructure,
+	RoleUpperFloorSlab: ElementUpperFloors,
+	RoleBasementWall:   ElementSubstructure,
+}
+
+// IsValid reports whether the role is one of the known geometric roles.
+func (r GeometricRole) IsValid() bool {
+	_, ok := roleElements[r]
+	return ok
+}
+
+// Element returns the building element the role is reported under.
+func (r GeometricRole) Element() Element {
+	if element, ok := roleElements[r]; ok {
+		return element
+	}
+	return ElementUnclassified
+}
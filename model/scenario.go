@@ -0,0 +1,92 @@
+package model
+
+import "gorm.io/gorm"
+
+// Scenario is a design option of a building, e.g. a timber hybrid instead of
+// a concrete frame. It only stores what differs from the building: geometry
+// parameters that are set override the building's, and assembly overrides
+// replace, requantify or remove the building's assemblies.
+type Scenario struct {
+	gorm.Model
+	BuildingID            uint                `gorm:"index;not null"`
+	Name                  string              `gorm:"type:string;not null"`
+	Description           string              `gorm:"type:string;"`
+	FTF                   *float64            `gorm:"type:float;"`
+	GroundFloorArea       *float64            `gorm:"type:float;"`
+	WWR                   *float64            `gorm:"type:float;"`
+	AboveGroundFloorCount *int                `gorm:"type:int;"`
+	UnderGroundFloorCount *int                `gorm:"type:int;"`
+	Overrides             []*ScenarioAssembly `gorm:"foreignKey:ScenarioID;constraint:OnDelete:CASCADE;"`
+}
+
+// ScenarioAssembly overrides one assembly of the building in a scenario. The
+// assembly is replaced by Replacement when it is set, uses Quantity instead of
+// its quantity on the building when it is set, or is left out when Removed.
+type ScenarioAssembly struct {
+	ScenarioID    uint      `gorm:"primaryKey"`
+	AssemblyID    uint      `gorm:"primaryKey"`
+	ReplacementID *uint     `gorm:"index;"`
+	Replacement   *Assembly `gorm:"foreignKey:ReplacementID;"`
+	Quantity      *float64  `gorm:"type:float;"`
+	Removed       bool      `gorm:"type:bool;not null;default:false"`
+}
+
+// FindOverride returns the scenario's override of the assembly, or nil if it has none.
+func (s *Scenario) FindOverride(assemblyID uint) *ScenarioAssembly {
+	for _, override := range s.Overrides {
+		if override.AssemblyID == assemblyID {
+			return override
+		}
+	}
+	return nil
+}
+
+// Apply returns the building as it is in the scenario. The building itself is
+// left untouched, so the scenario can be compared against it.
+func (s *Scenario) Apply(b *Building) *Building {
+	variant := *b
+	variant.MaterialPins = append([]*MaterialPin(nil), b.MaterialPins...)
+	if s.FTF != nil {
+		variant.FTF = *s.FTF
+	}
+	if s.GroundFloorArea != nil {
+		variant.GroundFloorArea = *s.GroundFloorArea
+	}
+	if s.WWR != nil {
+		variant.WWR = *s.WWR
+	}
+	if s.AboveGroundFloorCount != nil {
+		variant.AboveGroundFloorCount = *s.AboveGroundFloorCount
+	}
+	if s.UnderGroundFloorCount != nil {
+		variant.UnderGroundFloorCount = *s.UnderGroundFloorCount
+	}
+
+	variant.Assemblies = make([]*Assembly, 0, len(b.Assemblies))
+	variant.AssemblyLinks = make([]*BuildingAssembly, 0, len(b.Assemblies))
+	for _, assembly := range b.Assemblies {
+		link := BuildingAssembly{BuildingID: b.ID, AssemblyID: assembly.ID, Element: ElementUnclassified, Quantity: 1}
+		if existing := b.assemblyLink(assembly.ID); existing != nil {
+			link = *existing
+		}
+
+		if override := s.FindOverride(assembly.ID); override != nil {
+			if override.Removed {
+				continue
+			}
+			if override.Replacement != nil {
+				assembly = override.Replacement
+				link.AssemblyID = assembly.ID
+			}
+			if override.Quantity != nil {
+				link.Role = ""
+				link.Quantity = *override.Quantity
+			}
+		}
+		variant.Assemblies = append(variant.Assemblies, assembly)
+		variant.AssemblyLinks = append(variant.AssemblyLinks, &link)
+	}
+
+	variant.UpdateGeometry()
+	return &variant
+}
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RibaStage is a stage of the RIBA Plan of Work.
+type RibaStage int
+
+const (
+	StageStrategicDefinition RibaStage = iota
+	StagePreparationAndBriefing
+	StageConceptDesign
+	StageSpatialCoordination
+	StageTechnicalDesign
+	StageManufacturingAndConstruction
+	StageHandover
+	StageUse
+)
+
+var ribaStageNames = []string{
+	"Strategic Definition",
+	"Preparation and Briefing",
+	"Concept Design",
+	"Spatial Coordination",
+	"Technical Design",
+	"Manufacturing and Construction",
+	"Handover",
+	"Use",
+}
+
+// IsValid reports whether the stage is one of stages 0 to 7.
+func (s RibaStage) IsValid() bool {
+	return s >= StageStrategicDefinition && s <= StageUse
+}
+
+// Name returns the name of the stage, e.g. "Concept Design" for stage 2.
+func (s RibaStage) Name() string {
+	if !s.IsValid() {
+		return ""
+	}
+	return ribaStageNames[s]
+}
+
+// Snapshot is an immutable record of a building at a design stage: its
+// parameters, its full eagerly loaded state and the results computed from it
+// by the recorded methodology.
+type Snapshot struct {
+	gorm.Model
+	BuildingID            uint                `gorm:"index;not null"`
+	Stage                 RibaStage           `gorm:"type:int;not null"`
+	Date                  time.Time           `gorm:"not null"`
+	Label                 string              `gorm:"type:string;"`
+	GFA                   float64             `gorm:"type:float;"`
+	FTF                   float64             `gorm:"type:float;"`
+	GroundFloorArea       float64             `gorm:"type:float;"`
+	WWR                   float64             `gorm:"type:float;"`
+	AboveGroundFloorCount int                 `gorm:"type:int;"`
+	UnderGroundFloorCount int                 `gorm:"type:int;"`
+	GIA                   float64             `gorm:"type:float;"`
+	StudyPeriod           int                 `gorm:"type:int;"`
+	Occupants             int                 `gorm:"type:int;"`
+	Bedrooms              int                 `gorm:"type:int;"`
+	State                 json.RawMessage     `gorm:"type:jsonb;"`
+	Methodology           string              `gorm:"type:string;"`
+	MethodologyVersion    string              `gorm:"type:string;"`
+	TotalCarbon           float64             `gorm:"type:float;"`
+	Elements              []*SnapshotElement  `gorm:"foreignKey:SnapshotID;constraint:OnDelete:CASCADE;"`
+	Assemblies            []*SnapshotAssembly `gorm:"foreignKey:SnapshotID;constraint:OnDelete:CASCADE;"`
+}
+
+// SnapshotElement is the carbon of one building element in a snapshot.
+type SnapshotElement struct {
+	SnapshotID uint    `gorm:"primaryKey"`
+	Element    Element `gorm:"primaryKey;type:string"`
+	Carbon     float64 `gorm:"type:float;"`
+}
+
+// SnapshotAssembly is an assembly used on the building in a snapshot, with
+// its quantity and carbon summed over the building and its floors.
+type SnapshotAssembly struct {
+	SnapshotID uint    `gorm:"primaryKey"`
+	AssemblyID uint    `gorm:"primaryKey"`
+	Name       string  `gorm:"type:string;"`
+	Element    Element `gorm:"type:string;"`
+	Quantity   float64 `gorm:"type:float;"`
+	Carbon     float64 `gorm:"type:float;"`
+}
+
+// FindAssembly returns the snapshot's record of the assembly, or nil if it was not used.
+func (s *Snapshot) FindAssembly(assemblyID uint) *SnapshotAssembly {
+	for _, assembly := range s.Assemblies {
+		if assembly.AssemblyID == assemblyID {
+			return assembly
+		}
+	}
+	return nil
+}
+
+// Parameters returns a building with the parameters recorded in the snapshot,
+// without its assemblies, e.g. to normalise the snapshot's results by.
+func (s *Snapshot) Parameters() *Building {
+	building := &Building{
+		Model:                 gorm.Model{ID: s.BuildingID},
+		FTF:                   s.FTF,
+		GroundFloorArea:       s.GroundFloorArea,
+		WWR:                   s.WWR,
+		AboveGroundFloorCount: s.AboveGroundFloorCount,
+		UnderGroundFloorCount: s.UnderGroundFloorCount,
+		GIA:                   s.GIA,
+		StudyPeriod:           s.StudyPeriod,
+		Occupants:             s.Occupants,
+		Bedrooms:              s.Bedrooms,
+	}
+	building.UpdateGeometry()
+	return building
+}
+
+// Restore returns the building as it was recorded, calculating with the
+// material versions it was pinned to at the time.
+func (s *Snapshot) Restore() (*Building, error) {
+	var building Building
+	if err := json.Unmarshal(s.State, &building); err != nil {
+		return nil, err
+	}
+	building.PinMaterialVersions()
+	return &building, nil
+}
+
+// NewSnapshot records the building as it is now with the carbon of each of
+// its assemblies by the methodology. It has to be eagerly loaded, so its areas
+// are derived, with its material versions pinned and adjusted to the
+// methodology, see Building.UseMethodology. The total and per element results
+// are left to the caller to record.
+func NewSnapshot(b *Building, methodology *Methodology, stage RibaStage, date time.Time, label string) (*Snapshot, error) {
+	modules, err := methodology.Modules()
+	if err != nil {
+		return nil, err
+	}
+	state, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		BuildingID:            b.ID,
+		Stage:                 stage,
+		Date:                  date,
+		Label:                 label,
+		GFA:                   b.GFA,
+		FTF:                   b.FTF,
+		GroundFloorArea:       b.GroundFloorArea,
+		WWR:                   b.WWR,
+		AboveGroundFloorCount: b.AboveGroundFloorCount,
+		UnderGroundFloorCount: b.UnderGroundFloorCount,
+		GIA:                   b.GIA,
+		StudyPeriod:           b.StudyPeriod,
+		Occupants:             b.Occupants,
+		Bedrooms:              b.Bedrooms,
+		State:                 state,
+		Methodology:           methodology.ID,
+		MethodologyVersion:    methodology.Version,
+	}
+
+	add := func(assembly *Assembly, element Element, quantity float64) {
+		if record := snapshot.FindAssembly(assembly.ID); record != nil {
+			record.Quantity += quantity
+			record.Carbon += quantity * assembly.CalculateCarbonForPhase(modules...)
+			return
+		}
+		snapshot.Assemblies = append(snapshot.Assemblies, &SnapshotAssembly{
+			AssemblyID: assembly.ID,
+			Name:       assembly.Name,
+			Element:    element,
+			Quantity:   quantity,
+			Carbon:     quantity * assembly.CalculateCarbonForPhase(modules...),
+		})
+	}
+	for _, assembly := range b.Assemblies {
+		add(assembly, b.AssemblyElement(assembly.ID), b.AssemblyQuantity(assembly.ID))
+	}
+	for _, floor := range b.Floors {
+		for _, link := range floor.AssemblyLinks {
+			if link.Assembly != nil {
+				add(link.Assembly, link.Element, link.Quantity)
+			}
+		}
+	}
+	return snapshot, nil
+}
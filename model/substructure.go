@@ -0,0 +1,82 @@
+package model
+
+// SubstructureFactors are the carbon factors used to estimate a building's
+// substructure from its footprint and number of underground floors.
+// Factors that are not set fall back to DefaultSubstructureFactors, a factor
+// set to zero is kept, e.g. for a building reusing existing foundations.
+type SubstructureFactors struct {
+	RetainingWall *float64 `json:"retainingWall" gorm:"type:float;"` // kgCO2e/m2 of basement wall
+	BasementSlab  *float64 `json:"basementSlab" gorm:"type:float;"`  // kgCO2e/m2 of basement slab
+	Foundation    *float64 `json:"foundation" gorm:"type:float;"`    // kgCO2e/m2 of footprint
+	Excavation    *float64 `json:"excavation" gorm:"type:float;"`    // kgCO2e/m3 of excavated spoil
+}
+
+// DefaultSubstructureFactors are indicative factors for a reinforced concrete
+// substructure: 300 mm retaining walls, 250 mm basement slabs, pad and strip
+// foundations, and excavation including removal of the spoil off site.
+var DefaultSubstructureFactors = SubstructureFactors{
+	RetainingWall: factor(150),
+	BasementSlab:  factor(100),
+	Foundation:    factor(60),
+	Excavation:    factor(5),
+}
+
+// factor returns a pointer to a substructure factor.
+func factor(value float64) *float64 {
+	return &value
+}
+
+// withDefaults returns the factors with every unset factor replaced by its default.
+func (f SubstructureFactors) withDefaults() SubstructureFactors {
+	if f.RetainingWall == nil {
+		f.RetainingWall = factor(*DefaultSubstructureFactors.RetainingWall)
+	}
+	if f.BasementSlab == nil {
+		f.BasementSlab = factor(*DefaultSubstructureFactors.BasementSlab)
+	}
+	if f.Foundation == nil {
+		f.Foundation = factor(*DefaultSubstructureFactors.Foundation)
+	}
+	if f.Excavation == nil {
+		f.Excavation = factor(*DefaultSubstructureFactors.Excavation)
+	}
+	return f
+}
+
+// SubstructureEstimate is the estimated substructure of a building,
+// its quantities and the carbon of each part in kgCO2e.
+type SubstructureEstimate struct {
+	Factors             SubstructureFactors `json:"factors"`
+	RetainingWallArea   float64             `json:"retainingWallArea"` // m2
+	BasementSlabArea    float64             `json:"basementSlabArea"`  // m2
+	FoundationArea      float64             `json:"foundationArea"`    // m2
+	ExcavationVolume    float64             `json:"excavationVolume"`  // m3
+	RetainingWallCarbon float64             `json:"retainingWallCarbon"`
+	BasementSlabCarbon  float64             `json:"basementSlabCarbon"`
+	FoundationCarbon    float64             `json:"foundationCarbon"`
+	ExcavationCarbon    float64             `json:"excavationCarbon"`
+	TotalCarbon         float64             `json:"totalCarbon"`
+}
+
+// EstimateSubstructure estimates the retaining walls, basement slabs,
+// foundations and excavated spoil of the building from its footprint and
+// number of underground floors.
+func (b *Building) EstimateSubstructure() SubstructureEstimate {
+	factors := b.SubstructureFactors.withDefaults()
+	depth := b.FTF * float64(b.UnderGroundFloorCount)
+
+	estimate := SubstructureEstimate{
+		Factors:           factors,
+		RetainingWallArea: b.basementWallArea(),
+		BasementSlabArea:  b.GroundFloorArea * float64(b.UnderGroundFloorCount),
+		FoundationArea:    b.GroundFloorArea,
+		ExcavationVolume:  b.GroundFloorArea * depth,
+	}
+	estimate.RetainingWallCarbon = estimate.RetainingWallArea * *factors.RetainingWall
+	estimate.BasementSlabCarbon = estimate.BasementSlabArea * *factors.BasementSlab
+	estimate.FoundationCarbon = estimate.FoundationArea * *factors.Foundation
+	estimate.ExcavationCarbon = estimate.ExcavationVolume * *factors.Excavation
+	estimate.TotalCarbon = estimate.RetainingWallCarbon + estimate.BasementSlabCarbon +
+		estimate.FoundationCarbon + estimate.ExcavationCarbon
+	return estimate
+}
@@ -2,38 +2,67 @@ package model
 
 import "gorm.io/gorm"
 
-type UnitConverter interface {
-	Convert(b bool) Unit
+// UnitSystem is the system of units geometry and results are presented in.
+type UnitSystem string
+
+const (
+	UnitSystemMetric   UnitSystem = "metric"
+	UnitSystemImperial UnitSystem = "imperial"
+)
+
+// IsValid reports whether s is a known unit system.
+func (s UnitSystem) IsValid() bool {
+	return s == UnitSystemMetric || s == UnitSystemImperial
 }
 
+// Owners a unit preference can be stored against.
+const (
+	UnitOwnerUser    = "user"
+	UnitOwnerProject = "project"
+)
+
+// Unit is the unit system preference of a user or project, with the unit
+// symbols of each quantity in that system. Values are stored in metric, the
+// preference only applies to what is received and returned.
 type Unit struct {
 	gorm.Model
-	Metric  bool   `gorm:"default:true;"`
-	Area    string `gorm:"default:'m2';"`
-	Volume  string `gorm:"default:'m3';"`
-	Energy  string `gorm:"default:'kWh';"`
-	Mass    string `gorm:"default:'kg';"`
-	Density string `gorm:"default:'kg/m3';"`
-	Carbon  string `gorm:"default:'kgco2';"` // tco2, kgco2/m2, kgco2/m2/year
+	OwnerType     string     `gorm:"type:string;uniqueIndex:idx_unit_owner;not null"`
+	OwnerID       string     `gorm:"type:string;uniqueIndex:idx_unit_owner;not null"`
+	System        UnitSystem `gorm:"type:string;default:'metric';"`
+	Length        string     `gorm:"default:'m';"`
+	Area          string     `gorm:"default:'m2';"`
+	Volume        string     `gorm:"default:'m3';"`
+	Energy        string     `gorm:"default:'kWh';"`
+	Mass          string     `gorm:"default:'kg';"`
+	Density       string     `gorm:"default:'kg/m3';"`
+	Carbon        string     `gorm:"default:'kgCO2e';"`
+	CarbonPerArea string     `gorm:"default:'kgCO2e/m2';"`
 }
 
-func (u *Unit) Convert(isMetric bool) {
-	if isMetric {
-		u.Metric = false
-		u.Area = "ft2"
-		u.Volume = "ft3"
-		u.Energy = "kBtu"
-		u.Mass = "lb"
-		u.Density = "lb/ft3"
-		u.Carbon = "lbco2"
-
-	} else {
-		u.Metric = true
-		u.Area = "m2"
-		u.Volume = "m3"
-		u.Energy = "kWh"
-		u.Mass = "kg"
-		u.Density = "kg/m3"
-		u.Carbon = "kgco2"
+// NewUnit returns the unit symbols of the unit system, metric unless imperial.
+func NewUnit(system UnitSystem) Unit {
+	if system == UnitSystemImperial {
+		return Unit{
+			System:        UnitSystemImperial,
+			Length:        "ft",
+			Area:          "ft2",
+			Volume:        "ft3",
+			Energy:        "kBtu",
+			Mass:          "lb",
+			Density:       "lb/ft3",
+			Carbon:        "lbCO2e",
+			CarbonPerArea: "lbCO2e/ft2",
+		}
+	}
+	return Unit{
+		System:        UnitSystemMetric,
+		Length:        "m",
+		Area:          "m2",
+		Volume:        "m3",
+		Energy:        "kWh",
+		Mass:          "kg",
+		Density:       "kg/m3",
+		Carbon:        "kgCO2e",
+		CarbonPerArea: "kgCO2e/m2",
 	}
 }
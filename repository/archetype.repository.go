@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+)
+
+// ArchetypeRepository is an interface for interacting with the archetypes table.
+type ArchetypeRepository interface {
+	Save(archetype *model.Archetype) error
+	FindByID(id uint) (*model.Archetype, error)
+	FindAll() ([]model.Archetype, error)
+	ExistsByArchetypeName(name string) bool
+}
+
+// archetypeRepository is a concrete implementation of ArchetypeRepository.
+type archetypeRepository struct {
+	db *gorm.DB
+}
+
+// Save persists an archetype with its default assemblies.
+func (r *archetypeRepository) Save(archetype *model.Archetype) error {
+	return r.db.Omit("AssemblyLinks.Assembly").Save(archetype).Error
+}
+
+// FindByID fetches an archetype by ID, preloading its default assemblies.
+func (r *archetypeRepository) FindByID(id uint) (*model.Archetype, error) {
+	var archetype model.Archetype
+	err := r.db.Preload("AssemblyLinks.Assembly").First(&archetype, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &archetype, nil
+}
+
+// FindAll fetches all archetypes.
+func (r *archetypeRepository) FindAll() ([]model.Archetype, error) {
+	var archetypes []model.Archetype
+	err := r.db.Find(&archetypes).Error
+	if err != nil {
+		return nil, err
+	}
+	return archetypes, nil
+}
+
+func (r *archetypeRepository) ExistsByArchetypeName(name string) bool {
+	var count int64
+	r.db.Model(&model.Archetype{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// NewArchetypeRepository creates a new archetype repository.
+// This function should be called only once per application lifetime.
+func NewArchetypeRepository(db *gorm.DB) ArchetypeRepository {
+	return &archetypeRepository{db: db}
+}
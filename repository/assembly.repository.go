@@ -2,18 +2,39 @@ package repository
 
 import (
 	"carbon-service/model"
+	"fmt"
+	"slices"
 
 	"gorm.io/gorm"
 )
 
+// maxAssemblyDepth is the deepest sub-assemblies are loaded. Cycles are
+// rejected when a sub-assembly is added, so this only guards against
+// corrupt data.
+const maxAssemblyDepth = 32
+
 // AssemblyRepository is an interface for interacting with the assemblies table.
 type AssemblyRepository interface {
 	Save(assembly *model.Assembly) error
 	FindByID(id uint) (*model.Assembly, error)
+	FindByIDs(ids []uint) ([]model.Assembly, error)
 	EagerFindByID(id uint) (*model.Assembly, error)
 	FindAll() ([]model.Assembly, error)
 	EagerFindAll() ([]model.Assembly, error)
 	ExistsByAssemblyName(name string) bool
+	ReplaceLayers(assemblyID uint, layers []*model.AssemblyLayer) error
+	SaveMaterialLink(link *model.AssemblyMaterial) error
+	DeleteMaterialLink(assemblyID uint, materialID uint) error
+	ReorderMaterialLinks(assemblyID uint, materialIDs []uint) error
+	UpdateTotals(assembly *model.Assembly) error
+	FindTemplates() ([]model.Assembly, error)
+	EagerFindByTemplateID(templateID uint) ([]model.Assembly, error)
+	SetTemplate(id uint, isTemplate bool) error
+	SaveComponent(component *model.AssemblyComponent) error
+	DeleteComponent(parentID uint, childID uint) error
+	FindParentIDs(childID uint) ([]uint, error)
+	FindParentIDsOf(childIDs []uint) ([]uint, error)
+	FindIDsByMaterial(materialID uint) ([]uint, error)
 }
 
 // assemblyRepository is a concrete implementation of AssemblyRepository.
@@ -37,17 +58,33 @@ func (r *assemblyRepository) FindByID(id uint) (*model.Assembly, error) {
 	return &assembly, nil
 }
 
+// FindByIDs retrieves the assemblies with the provided IDs, without their
+// materials, layers or components.
+func (r *assemblyRepository) FindByIDs(ids []uint) ([]model.Assembly, error) {
+	var assemblies []model.Assembly
+	if len(ids) == 0 {
+		return assemblies, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&assemblies).Error
+	if err != nil {
+		return nil, err
+	}
+	return assemblies, nil
+}
+
 // EagerFindByID retrieves an assembly from the database based on the provided ID,
-// preloading its materials.
+// preloading its materials and layers.
 // It returns a pointer to the found assembly and an error, if any.
 func (r *assemblyRepository) EagerFindByID(id uint) (*model.Assembly, error) {
 	var assembly model.Assembly
-	// pre load materials and all buildings that use this assembly
-	err := r.db.Preload("Materials").Preload("Buildings").First(&assembly, id).Error
-	// err := r.db.Preload("Materials").First(&assembly, id).Error
+	// pre load materials, layers and all buildings that use this assembly
+	err := r.eager().Preload("Buildings").First(&assembly, id).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := loadComponents(r.db, []*model.Assembly{&assembly}); err != nil {
+		return nil, err
+	}
 	return &assembly, nil
 }
 
@@ -62,14 +99,17 @@ func (r *assemblyRepository) FindAll() ([]model.Assembly, error) {
 	return assemblies, nil
 }
 
-// EagerFindAll retrieves all assemblies from the database, preloading their materials.
+// EagerFindAll retrieves all assemblies from the database, preloading their materials and layers.
 // It returns a slice of assemblies and an error, if any.
 func (r *assemblyRepository) EagerFindAll() ([]model.Assembly, error) {
 	var assemblies []model.Assembly
-	err := r.db.Preload("Materials").Find(&assemblies).Error
+	err := r.eager().Find(&assemblies).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := loadComponents(r.db, assemblyPointers(assemblies)); err != nil {
+		return nil, err
+	}
 	return assemblies, nil
 }
 
@@ -81,6 +121,217 @@ func (r *assemblyRepository) ExistsByAssemblyName(name string) bool {
 	return err == nil
 }
 
+// ReplaceLayers replaces all layers of an assembly with the given ordered layers.
+func (r *assemblyRepository) ReplaceLayers(assemblyID uint, layers []*model.AssemblyLayer) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("assembly_id = ?", assemblyID).Delete(&model.AssemblyLayer{}).Error; err != nil {
+			return err
+		}
+		if len(layers) == 0 {
+			return nil
+		}
+		return tx.Omit("Material").Create(layers).Error
+	})
+}
+
+// SaveMaterialLink creates or updates the link between an assembly and a material.
+func (r *assemblyRepository) SaveMaterialLink(link *model.AssemblyMaterial) error {
+	return r.db.Omit("Material").Save(link).Error
+}
+
+// DeleteMaterialLink removes a material from an assembly.
+func (r *assemblyRepository) DeleteMaterialLink(assemblyID uint, materialID uint) error {
+	return r.db.Where("assembly_id = ? AND material_id = ?", assemblyID, materialID).
+		Delete(&model.AssemblyMaterial{}).Error
+}
+
+// ReorderMaterialLinks sets the position of each material of an assembly to
+// its index in the given list.
+func (r *assemblyRepository) ReorderMaterialLinks(assemblyID uint, materialIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for position, materialID := range materialIDs {
+			err := tx.Model(&model.AssemblyMaterial{}).
+				Where("assembly_id = ? AND material_id = ?", assemblyID, materialID).
+				Update("position", position).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateTotals persists the cached carbon totals of an assembly.
+func (r *assemblyRepository) UpdateTotals(assembly *model.Assembly) error {
+	return r.db.Model(assembly).Select("TotalCarbon", "CarbonPerArea").Updates(assembly).Error
+}
+
+// FindTemplates retrieves all assemblies marked as library templates.
+func (r *assemblyRepository) FindTemplates() ([]model.Assembly, error) {
+	var assemblies []model.Assembly
+	err := r.db.Where("is_template = ?", true).Find(&assemblies).Error
+	if err != nil {
+		return nil, err
+	}
+	return assemblies, nil
+}
+
+// EagerFindByTemplateID retrieves all assemblies cloned from the given template,
+// preloading their materials and layers.
+func (r *assemblyRepository) EagerFindByTemplateID(templateID uint) ([]model.Assembly, error) {
+	var assemblies []model.Assembly
+	err := r.eager().Where("template_id = ?", templateID).Find(&assemblies).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := loadComponents(r.db, assemblyPointers(assemblies)); err != nil {
+		return nil, err
+	}
+	return assemblies, nil
+}
+
+// SetTemplate marks or unmarks an assembly as a library template.
+func (r *assemblyRepository) SetTemplate(id uint, isTemplate bool) error {
+	return r.db.Model(&model.Assembly{}).Where("id = ?", id).Update("is_template", isTemplate).Error
+}
+
+// SaveComponent creates or updates a sub-assembly of an assembly.
+func (r *assemblyRepository) SaveComponent(component *model.AssemblyComponent) error {
+	return r.db.Omit("Child").Save(component).Error
+}
+
+// DeleteComponent removes a sub-assembly from an assembly.
+func (r *assemblyRepository) DeleteComponent(parentID uint, childID uint) error {
+	return r.db.Where("parent_id = ? AND child_id = ?", parentID, childID).
+		Delete(&model.AssemblyComponent{}).Error
+}
+
+// FindParentIDs retrieves the IDs of the assemblies directly containing the given one.
+func (r *assemblyRepository) FindParentIDs(childID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.AssemblyComponent{}).Where("child_id = ?", childID).Pluck("parent_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FindParentIDsOf retrieves the IDs of the assemblies directly containing any
+// of the given ones, each ID once.
+func (r *assemblyRepository) FindParentIDsOf(childIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(childIDs) == 0 {
+		return ids, nil
+	}
+	err := r.db.Model(&model.AssemblyComponent{}).Where("child_id IN ?", childIDs).Distinct().Pluck("parent_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FindIDsByMaterial retrieves the IDs of the assemblies containing the material
+// directly, as a material quantity or in a layer.
+func (r *assemblyRepository) FindIDsByMaterial(materialID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.AssemblyMaterial{}).Where("material_id = ?", materialID).Pluck("assembly_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	var layered []uint
+	err = r.db.Model(&model.AssemblyLayer{}).Where("material_id = ?", materialID).Distinct().Pluck("assembly_id", &layered).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range layered {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// eager preloads everything needed to calculate an assembly:
+// its materials and its layers in order, with all material versions.
+// Sub-assemblies are loaded afterwards with loadComponents.
+func (r *assemblyRepository) eager() *gorm.DB {
+	return preloadAssemblyContents(r.db, "")
+}
+
+// preloadAssemblyContents preloads the material links and layers of the
+// assemblies found at the given association path prefix, e.g. "Assemblies.".
+func preloadAssemblyContents(db *gorm.DB, prefix string) *gorm.DB {
+	byPosition := func(db *gorm.DB) *gorm.DB { return db.Order("position") }
+	return db.Preload(prefix+"MaterialLinks", byPosition).
+		Preload(prefix+"MaterialLinks.Material.Versions.Gwp").
+		Preload(prefix+"Layers", byPosition).
+		Preload(prefix + "Layers.Material.Versions.Gwp")
+}
+
+// preloadAssemblyStructure preloads the material links and layers of the
+// assemblies found at the given association path prefix, without their materials.
+func preloadAssemblyStructure(db *gorm.DB, prefix string) *gorm.DB {
+	byPosition := func(db *gorm.DB) *gorm.DB { return db.Order("position") }
+	return db.Preload(prefix+"MaterialLinks", byPosition).
+		Preload(prefix+"Layers", byPosition)
+}
+
+// loadComponents loads the sub-assemblies of the given assemblies level by
+// level, as deep as they are nested, including their contents.
+func loadComponents(db *gorm.DB, assemblies []*model.Assembly) error {
+	return loadComponentsWith(db, assemblies, preloadAssemblyContents)
+}
+
+// loadComponentsWith loads the sub-assemblies of the given assemblies level
+// by level, as deep as they are nested, with what preload preloads of them.
+func loadComponentsWith(db *gorm.DB, assemblies []*model.Assembly, preload func(*gorm.DB, string) *gorm.DB) error {
+	for depth := 0; len(assemblies) > 0; depth++ {
+		if depth >= maxAssemblyDepth {
+			return fmt.Errorf("assemblies are nested deeper than %d levels", maxAssemblyDepth)
+		}
+
+		byID := make(map[uint][]*model.Assembly, len(assemblies))
+		ids := make([]uint, 0, len(assemblies))
+		for _, assembly := range assemblies {
+			if _, ok := byID[assembly.ID]; !ok {
+				ids = append(ids, assembly.ID)
+			}
+			byID[assembly.ID] = append(byID[assembly.ID], assembly)
+		}
+
+		var components []*model.AssemblyComponent
+		err := preload(db, "Child.").
+			Preload("Child").
+			Where("parent_id IN ?", ids).
+			Order("position").
+			Find(&components).Error
+		if err != nil {
+			return err
+		}
+
+		var children []*model.Assembly
+		for _, component := range components {
+			for _, parent := range byID[component.ParentID] {
+				parent.Components = append(parent.Components, component)
+			}
+			if component.Child != nil {
+				children = append(children, component.Child)
+			}
+		}
+		assemblies = children
+	}
+	return nil
+}
+
+// assemblyPointers returns pointers to the elements of the slice.
+func assemblyPointers(assemblies []model.Assembly) []*model.Assembly {
+	pointers := make([]*model.Assembly, len(assemblies))
+	for i := range assemblies {
+		pointers[i] = &assemblies[i]
+	}
+	return pointers
+}
+
 // NewAssemblyRepository creates a new AssemblyRepository with the provided database connection.
 func NewAssemblyRepository(db *gorm.DB) AssemblyRepository {
 	return &assemblyRepository{db}
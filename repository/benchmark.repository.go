@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+)
+
+// BenchmarkRepository is an interface for interacting with the benchmark sets table.
+type BenchmarkRepository interface {
+	Save(set *model.BenchmarkSet) error
+	FindAll() ([]model.BenchmarkSet, error)
+	FindByName(name string) (*model.BenchmarkSet, error)
+	ExistsByBenchmarkSetName(name string) bool
+}
+
+// benchmarkRepository is a concrete implementation of BenchmarkRepository.
+type benchmarkRepository struct {
+	db *gorm.DB
+}
+
+// Save persists a benchmark set with its benchmarks.
+func (r *benchmarkRepository) Save(set *model.BenchmarkSet) error {
+	return r.db.Save(set).Error
+}
+
+// FindAll fetches all benchmark sets with their benchmarks.
+func (r *benchmarkRepository) FindAll() ([]model.BenchmarkSet, error) {
+	var sets []model.BenchmarkSet
+	err := r.db.Preload("Benchmarks").Order("name").Find(&sets).Error
+	if err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// FindByName fetches a benchmark set with its benchmarks by its name.
+func (r *benchmarkRepository) FindByName(name string) (*model.BenchmarkSet, error) {
+	var set model.BenchmarkSet
+	err := r.db.Preload("Benchmarks").Where("name = ?", name).First(&set).Error
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+func (r *benchmarkRepository) ExistsByBenchmarkSetName(name string) bool {
+	var count int64
+	r.db.Model(&model.BenchmarkSet{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+// NewBenchmarkRepository creates a new benchmark repository.
+// This function should be called only once per application lifetime.
+func NewBenchmarkRepository(db *gorm.DB) BenchmarkRepository {
+	return &benchmarkRepository{db: db}
+}
@@ -4,15 +4,27 @@ import (
 	"carbon-service/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BuildingRepository interface {
 	Save(building *model.Building) error
+	Create(building *model.Building, links []*model.BuildingAssembly) error
 	ExistsByBuildingName(buildingName string) bool
 	FindByID(id uint) (*model.Building, error)
 	EagerFindByID(id uint) (*model.Building, error)
+	FindCalculationInputs(id uint) (*model.Building, map[uint]uint, error)
 	FindAll() ([]model.Building, error)
 	EagerFindAll() ([]model.Building, error)
+	EagerFindByIDs(ids []uint) ([]model.Building, error)
+	FindIDsByAssembly(assemblyID uint) ([]uint, error)
+	FindIDsByAssemblies(assemblyIDs []uint) (map[uint][]uint, error)
+	PinMaterials(buildingIDs []uint) error
+	RepinMaterial(materialID uint, versionID uint, buildingIDs []uint) error
+	SaveAssemblyLink(link *model.BuildingAssembly) error
+	SaveAssemblyLinks(links []*model.BuildingAssembly) error
+	ReplaceAssemblies(building *model.Building, assemblies []*model.Assembly) error
+	Update(building *model.Building) error
 }
 
 type buildingRepository struct {
@@ -24,6 +36,25 @@ func (r *buildingRepository) Save(building *model.Building) error {
 	return r.db.Save(building).Error
 }
 
+// Create persists a new building with the links to the assemblies used on it
+// and pins the materials of those assemblies, all in one transaction.
+func (r *buildingRepository) Create(building *model.Building, links []*model.BuildingAssembly) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(building).Error; err != nil {
+			return err
+		}
+		for _, link := range links {
+			link.BuildingID = building.ID
+		}
+		if len(links) > 0 {
+			if err := tx.Save(links).Error; err != nil {
+				return err
+			}
+		}
+		return (&buildingRepository{db: tx}).PinMaterials([]uint{building.ID})
+	})
+}
+
 func (r *buildingRepository) ExistsByBuildingName(buildingName string) bool {
 	var count int64
 	r.db.Model(&model.Building{}).Where("name = ?", buildingName).Count(&count)
@@ -43,13 +74,69 @@ func (r *buildingRepository) FindByID(id uint) (*model.Building, error) {
 // EagerFindByID fetches a building by ID, preloading its assemblies and materials.
 func (r *buildingRepository) EagerFindByID(id uint) (*model.Building, error) {
 	var building model.Building
-	err := r.db.Preload("Assemblies.Materials").First(&building, id).Error
+	err := r.eager().First(&building, id).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := r.loadComponents(&building); err != nil {
+		return nil, err
+	}
 	return &building, nil
 }
 
+// FindCalculationInputs fetches a building by ID with everything its
+// calculation depends on except its materials, and the ID of the latest
+// version of each material it uses by material ID. It is much cheaper than
+// EagerFindByID, which loads every version of every material.
+func (r *buildingRepository) FindCalculationInputs(id uint) (*model.Building, map[uint]uint, error) {
+	var building model.Building
+	byLevel := func(db *gorm.DB) *gorm.DB { return db.Order("level") }
+	db := preloadAssemblyStructure(r.db, "Assemblies.")
+	err := preloadAssemblyStructure(db, "Floors.AssemblyLinks.Assembly.").
+		Preload("Floors", byLevel).
+		Preload("Floors.Zones").
+		Preload("AssemblyLinks").
+		Preload("MaterialPins").
+		First(&building, id).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	var assemblies []*model.Assembly
+	assemblies = append(assemblies, building.Assemblies...)
+	for _, floor := range building.Floors {
+		for _, link := range floor.AssemblyLinks {
+			if link.Assembly != nil {
+				assemblies = append(assemblies, link.Assembly)
+			}
+		}
+	}
+	if err := loadComponentsWith(r.db, assemblies, preloadAssemblyStructure); err != nil {
+		return nil, nil, err
+	}
+
+	latest := make(map[uint]uint)
+	materialIDs := building.MaterialIDs()
+	if len(materialIDs) == 0 {
+		return &building, latest, nil
+	}
+	var versions []struct {
+		MaterialID uint
+		ID         uint
+	}
+	err = r.db.Model(&model.MaterialVersion{}).
+		Select("DISTINCT ON (material_id) material_id, id").
+		Where("material_id IN ?", materialIDs).
+		Order("material_id, version desc").
+		Scan(&versions).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, version := range versions {
+		latest[version.MaterialID] = version.ID
+	}
+	return &building, latest, nil
+}
+
 func (r *buildingRepository) FindAll() ([]model.Building, error) {
 	var buildings []model.Building
 	err := r.db.Find(&buildings).Error
@@ -61,13 +148,198 @@ func (r *buildingRepository) FindAll() ([]model.Building, error) {
 
 func (r *buildingRepository) EagerFindAll() ([]model.Building, error) {
 	var buildings []model.Building
-	err := r.db.Preload("Assemblies.Materials").Find(&buildings).Error
+	err := r.eager().Find(&buildings).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadComponents(buildingPointers(buildings)...); err != nil {
+		return nil, err
+	}
+	return buildings, nil
+}
+
+// EagerFindByIDs fetches the buildings with the given IDs, preloading their assemblies and materials.
+func (r *buildingRepository) EagerFindByIDs(ids []uint) ([]model.Building, error) {
+	var buildings []model.Building
+	if len(ids) == 0 {
+		return buildings, nil
+	}
+	err := r.eager().Find(&buildings, ids).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := r.loadComponents(buildingPointers(buildings)...); err != nil {
+		return nil, err
+	}
 	return buildings, nil
 }
 
+// FindIDsByAssembly retrieves the IDs of the buildings using the assembly
+// directly, on the building as a whole or on one of its floors.
+func (r *buildingRepository) FindIDsByAssembly(assemblyID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.BuildingAssembly{}).Where("assembly_id = ?", assemblyID).Pluck("building_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	var floorIDs []uint
+	err = r.db.Model(&model.FloorAssembly{}).Where("assembly_id = ?", assemblyID).Pluck("floor_id", &floorIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(floorIDs) > 0 {
+		var onFloors []uint
+		err = r.db.Model(&model.Floor{}).Where("id IN ?", floorIDs).Distinct().Pluck("building_id", &onFloors).Error
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, onFloors...)
+	}
+	return ids, nil
+}
+
+// FindIDsByAssemblies retrieves the IDs of the buildings using each of the
+// assemblies directly, on the building as a whole or on one of its floors,
+// keyed by assembly ID. Assemblies used by no building are left out.
+func (r *buildingRepository) FindIDsByAssemblies(assemblyIDs []uint) (map[uint][]uint, error) {
+	ids := make(map[uint][]uint)
+	if len(assemblyIDs) == 0 {
+		return ids, nil
+	}
+	type assemblyUse struct {
+		AssemblyID uint
+		BuildingID uint
+	}
+	var uses []assemblyUse
+	err := r.db.Model(&model.BuildingAssembly{}).
+		Select("assembly_id, building_id").
+		Where("assembly_id IN ?", assemblyIDs).
+		Scan(&uses).Error
+	if err != nil {
+		return nil, err
+	}
+	var onFloors []assemblyUse
+	err = r.db.Model(&model.FloorAssembly{}).
+		Distinct("floor_assemblies.assembly_id", "floors.building_id").
+		Joins("JOIN floors ON floors.id = floor_assemblies.floor_id AND floors.deleted_at IS NULL").
+		Where("floor_assemblies.assembly_id IN ?", assemblyIDs).
+		Scan(&onFloors).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, use := range append(uses, onFloors...) {
+		ids[use.AssemblyID] = append(ids[use.AssemblyID], use.BuildingID)
+	}
+	return ids, nil
+}
+
+// PinMaterials pins every material used by the buildings that is not pinned
+// yet to its latest version. Existing pins are left as they are.
+func (r *buildingRepository) PinMaterials(buildingIDs []uint) error {
+	if len(buildingIDs) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		buildings, err := (&buildingRepository{db: tx}).EagerFindByIDs(buildingIDs)
+		if err != nil {
+			return err
+		}
+		var pins []*model.MaterialPin
+		for i := range buildings {
+			pins = append(pins, buildings[i].PinMaterialVersions()...)
+		}
+		if len(pins) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(pins).Error
+	})
+}
+
+// RepinMaterial pins the buildings to the given version of the material,
+// whether or not they were pinned to another version of it before.
+func (r *buildingRepository) RepinMaterial(materialID uint, versionID uint, buildingIDs []uint) error {
+	if len(buildingIDs) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, buildingID := range buildingIDs {
+			pin := &model.MaterialPin{BuildingID: buildingID, MaterialID: materialID, MaterialVersionID: versionID}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "building_id"}, {Name: "material_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"material_version_id", "updated_at"}),
+			}).Create(pin).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveAssemblyLinks saves several assembly links of buildings at once.
+func (r *buildingRepository) SaveAssemblyLinks(links []*model.BuildingAssembly) error {
+	if len(links) == 0 {
+		return nil
+	}
+	return r.db.Save(links).Error
+}
+
+// ReplaceAssemblies replaces the assemblies used on the building as a whole.
+// Links to assemblies that stay on the building keep their element, role and quantity.
+func (r *buildingRepository) ReplaceAssemblies(building *model.Building, assemblies []*model.Assembly) error {
+	return r.db.Model(building).Association("Assemblies").Replace(assemblies)
+}
+
+// Update persists the building's own fields without touching its associations.
+func (r *buildingRepository) Update(building *model.Building) error {
+	return r.db.Omit(clause.Associations).Save(building).Error
+}
+
+// SaveAssemblyLink adds an assembly to a building or updates its element.
+func (r *buildingRepository) SaveAssemblyLink(link *model.BuildingAssembly) error {
+	return r.db.Save(link).Error
+}
+
+// eager preloads everything needed to calculate a building:
+// its assemblies with their elements, their materials and layers with all
+// versions, its floors with their zones and assemblies, and its material pins.
+// Sub-assemblies are loaded afterwards with loadComponents.
+func (r *buildingRepository) eager() *gorm.DB {
+	byLevel := func(db *gorm.DB) *gorm.DB { return db.Order("level") }
+	db := preloadAssemblyContents(r.db, "Assemblies.")
+	return preloadAssemblyContents(db, "Floors.AssemblyLinks.Assembly.").
+		Preload("Floors", byLevel).
+		Preload("Floors.Zones").
+		Preload("AssemblyLinks").
+		Preload("MaterialPins")
+}
+
+// loadComponents loads the sub-assemblies of the buildings' assemblies,
+// including those used on their floors.
+func (r *buildingRepository) loadComponents(buildings ...*model.Building) error {
+	var assemblies []*model.Assembly
+	for _, building := range buildings {
+		assemblies = append(assemblies, building.Assemblies...)
+		for _, floor := range building.Floors {
+			for _, link := range floor.AssemblyLinks {
+				if link.Assembly != nil {
+					assemblies = append(assemblies, link.Assembly)
+				}
+			}
+		}
+	}
+	return loadComponents(r.db, assemblies)
+}
+
+// buildingPointers returns pointers to the elements of the slice.
+func buildingPointers(buildings []model.Building) []*model.Building {
+	pointers := make([]*model.Building, len(buildings))
+	for i := range buildings {
+		pointers[i] = &buildings[i]
+	}
+	return pointers
+}
+
 // NewBuildingRepository creates a new building repository.
 // This function should be called only once per application lifetime.
 func NewBuildingRepository(db *gorm.DB) BuildingRepository {
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"carbon-service/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalculationJobRepository is an interface for interacting with the calculation jobs run in the background.
+type CalculationJobRepository interface {
+	Create(job *model.CalculationJob) error
+	Update(job *model.CalculationJob) error
+	FindByID(id uint) (*model.CalculationJob, error)
+	ClaimNext(owner string, lease time.Duration) (*model.CalculationJob, error)
+	UpdateStatus(id uint, from model.JobStatus, to model.JobStatus) (bool, error)
+	UpdateProgress(id uint, progress float64) error
+	RenewLease(id uint, owner string, lease time.Duration) (bool, error)
+	RequeueRunning(owner string) error
+	RequeueExpired() error
+}
+
+// calculationJobRepository is a concrete implementation of CalculationJobRepository.
+type calculationJobRepository struct {
+	db *gorm.DB
+}
+
+// Create persists a new calculation job.
+func (r *calculationJobRepository) Create(job *model.CalculationJob) error {
+	return r.db.Create(job).Error
+}
+
+// Update saves the status, progress and result of a calculation job.
+func (r *calculationJobRepository) Update(job *model.CalculationJob) error {
+	return r.db.Save(job).Error
+}
+
+// FindByID fetches a calculation job with its request and result.
+func (r *calculationJobRepository) FindByID(id uint) (*model.CalculationJob, error) {
+	var job model.CalculationJob
+	if err := r.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimNext marks the oldest queued job as running, leased by owner for the
+// duration of lease, and returns it, or returns gorm.ErrRecordNotFound when no
+// job is queued. A job claimed by someone else in the meantime is skipped.
+func (r *calculationJobRepository) ClaimNext(owner string, lease time.Duration) (*model.CalculationJob, error) {
+	for {
+		var job model.CalculationJob
+		err := r.db.Where("status = ?", model.JobQueued).Order("id").First(&job).Error
+		if err != nil {
+			return nil, err
+		}
+		expires := time.Now().Add(lease)
+		result := r.db.Model(&model.CalculationJob{}).
+			Where("id = ? AND status = ?", job.ID, model.JobQueued).
+			Updates(map[string]interface{}{"status": model.JobRunning, "owner": owner, "lease_expires_at": expires})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = model.JobRunning
+			job.Owner = owner
+			job.LeaseExpiresAt = &expires
+			return &job, nil
+		}
+	}
+}
+
+// UpdateStatus changes the status of a job only if it still has the status
+// from, and reports whether it did.
+func (r *calculationJobRepository) UpdateStatus(id uint, from model.JobStatus, to model.JobStatus) (bool, error) {
+	result := r.db.Model(&model.CalculationJob{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UpdateProgress saves the progress of a running job.
+func (r *calculationJobRepository) UpdateProgress(id uint, progress float64) error {
+	return r.db.Model(&model.CalculationJob{}).Where("id = ?", id).Update("progress", progress).Error
+}
+
+// RenewLease extends the lease of a job that is still running for owner, and
+// reports whether it was. A job whose lease was lost may be run by someone else.
+func (r *calculationJobRepository) RenewLease(id uint, owner string, lease time.Duration) (bool, error) {
+	result := r.db.Model(&model.CalculationJob{}).
+		Where("id = ? AND status = ? AND owner = ?", id, model.JobRunning, owner).
+		Update("lease_expires_at", time.Now().Add(lease))
+	return result.RowsAffected == 1, result.Error
+}
+
+// RequeueRunning puts the running jobs of owner back in the queue. It is
+// called by owner when it starts, when it cannot be running any job yet.
+func (r *calculationJobRepository) RequeueRunning(owner string) error {
+	return r.requeue(r.db.Where("status = ? AND owner = ?", model.JobRunning, owner))
+}
+
+// RequeueExpired puts the running jobs whose lease has expired back in the
+// queue, whoever their owner was: it stopped before finishing them.
+func (r *calculationJobRepository) RequeueExpired() error {
+	return r.requeue(r.db.Where("status = ?", model.JobRunning).
+		Where(r.db.Where("lease_expires_at IS NULL").Or("lease_expires_at < ?", time.Now())))
+}
+
+// requeue puts the jobs matching the conditions back in the queue.
+func (r *calculationJobRepository) requeue(conditions *gorm.DB) error {
+	return r.db.Model(&model.CalculationJob{}).
+		Where(conditions).
+		Updates(map[string]interface{}{"status": model.JobQueued, "progress": 0, "owner": "", "lease_expires_at": nil}).Error
+}
+
+// NewCalculationJobRepository creates a new calculation job repository.
+// This function should be called only once per application lifetime.
+func NewCalculationJobRepository(db *gorm.DB) CalculationJobRepository {
+	return &calculationJobRepository{db: db}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+)
+
+// CalculationRunRepository is an interface for interacting with the persisted
+// calculation runs of buildings.
+type CalculationRunRepository interface {
+	Create(run *model.CalculationRun) error
+	FindByID(id uint) (*model.CalculationRun, error)
+	FindByInputsHash(buildingID uint, inputsHash string) (*model.CalculationRun, error)
+	FindLatestByBuildingID(buildingID uint) (*model.CalculationRun, error)
+	FindByBuildingID(buildingID uint) ([]model.CalculationRun, error)
+}
+
+// calculationRunRepository is a concrete implementation of CalculationRunRepository.
+type calculationRunRepository struct {
+	db *gorm.DB
+}
+
+// Create persists a calculation run with its result and breakdown.
+func (r *calculationRunRepository) Create(run *model.CalculationRun) error {
+	return r.db.Create(run).Error
+}
+
+// FindByID fetches a calculation run with its result and breakdown.
+func (r *calculationRunRepository) FindByID(id uint) (*model.CalculationRun, error) {
+	var run model.CalculationRun
+	if err := r.db.First(&run, id).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindByInputsHash fetches the latest run of the building with the inputs hash.
+func (r *calculationRunRepository) FindByInputsHash(buildingID uint, inputsHash string) (*model.CalculationRun, error) {
+	var run model.CalculationRun
+	err := r.db.Where("building_id = ? AND inputs_hash = ?", buildingID, inputsHash).
+		Order("calculated_at desc, id desc").
+		First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindLatestByBuildingID fetches the latest run of the building, leaving out
+// its result and breakdown.
+func (r *calculationRunRepository) FindLatestByBuildingID(buildingID uint) (*model.CalculationRun, error) {
+	var run model.CalculationRun
+	err := r.db.Omit("Result", "Breakdown").
+		Where("building_id = ?", buildingID).
+		Order("calculated_at desc, id desc").
+		First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindByBuildingID fetches the runs of a building in the order they were
+// calculated with their results, leaving out their breakdown.
+func (r *calculationRunRepository) FindByBuildingID(buildingID uint) ([]model.CalculationRun, error) {
+	var runs []model.CalculationRun
+	err := r.db.Omit("Breakdown").
+		Where("building_id = ?", buildingID).
+		Order("calculated_at, id").
+		Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// NewCalculationRunRepository creates a new calculation run repository.
+// This function should be called only once per application lifetime.
+func NewCalculationRunRepository(db *gorm.DB) CalculationRunRepository {
+	return &calculationRunRepository{db: db}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FloorRepository is an interface for interacting with the floors and zones of buildings.
+type FloorRepository interface {
+	Save(floor *model.Floor) error
+	FindByID(id uint) (*model.Floor, error)
+	FindByBuildingID(buildingID uint) ([]model.Floor, error)
+	Delete(floor *model.Floor) error
+	SaveZone(zone *model.Zone) error
+	DeleteZone(zone *model.Zone) error
+	SaveAssemblyLink(link *model.FloorAssembly) error
+	DeleteAssemblyLink(link *model.FloorAssembly) error
+}
+
+// floorRepository is a concrete implementation of FloorRepository.
+type floorRepository struct {
+	db *gorm.DB
+}
+
+// Save persists a floor's own fields without touching its zones and assemblies.
+func (r *floorRepository) Save(floor *model.Floor) error {
+	return r.db.Omit(clause.Associations).Save(floor).Error
+}
+
+// FindByID fetches a floor by ID, preloading its zones and assembly links.
+func (r *floorRepository) FindByID(id uint) (*model.Floor, error) {
+	var floor model.Floor
+	err := r.db.Preload("Zones").Preload("AssemblyLinks").First(&floor, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &floor, nil
+}
+
+// FindByBuildingID fetches the floors of a building from the lowest level up,
+// preloading their zones and assembly links.
+func (r *floorRepository) FindByBuildingID(buildingID uint) ([]model.Floor, error) {
+	var floors []model.Floor
+	err := r.db.Preload("Zones").Preload("AssemblyLinks").
+		Where("building_id = ?", buildingID).
+		Order("level").
+		Find(&floors).Error
+	if err != nil {
+		return nil, err
+	}
+	return floors, nil
+}
+
+// Delete removes a floor together with its zones and assembly links.
+func (r *floorRepository) Delete(floor *model.Floor) error {
+	return r.db.Select("Zones", "AssemblyLinks").Delete(floor).Error
+}
+
+// SaveZone persists a zone of a floor.
+func (r *floorRepository) SaveZone(zone *model.Zone) error {
+	return r.db.Save(zone).Error
+}
+
+// DeleteZone removes a zone together with the assemblies assigned to it.
+func (r *floorRepository) DeleteZone(zone *model.Zone) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("zone_id = ?", zone.ID).Delete(&model.FloorAssembly{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(zone).Error
+	})
+}
+
+// SaveAssemblyLink adds an assembly to a floor or updates its quantity and element.
+func (r *floorRepository) SaveAssemblyLink(link *model.FloorAssembly) error {
+	return r.db.Omit("Assembly").Save(link).Error
+}
+
+// DeleteAssemblyLink removes an assembly from a floor.
+func (r *floorRepository) DeleteAssemblyLink(link *model.FloorAssembly) error {
+	return r.db.Delete(link).Error
+}
+
+// NewFloorRepository creates a new floor repository.
+// This function should be called only once per application lifetime.
+func NewFloorRepository(db *gorm.DB) FloorRepository {
+	return &floorRepository{db: db}
+}
@@ -13,6 +13,8 @@ type MaterialRepository interface {
 	FindByID(id uint) (*model.Material, error)
 	EagerFindByID(id uint) (*model.Material, error)
 	FindAll() ([]model.Material, error)
+	SaveVersion(version *model.MaterialVersion) error
+	FindVersions(materialID uint) ([]model.MaterialVersion, error)
 }
 
 // materialRepository is a concrete implementation of MaterialRepository.
@@ -21,8 +23,25 @@ type materialRepository struct {
 }
 
 // Save persists a material to the database.
+// Versions are immutable and are never written through Save, use SaveVersion instead.
 func (r *materialRepository) Save(material *model.Material) error {
-	return r.db.Save(material).Error
+	return r.db.Omit("Versions").Save(material).Error
+}
+
+// SaveVersion inserts a new version of a material's EPD data.
+// Existing versions are never updated.
+func (r *materialRepository) SaveVersion(version *model.MaterialVersion) error {
+	return r.db.Create(version).Error
+}
+
+// FindVersions retrieves all versions of a material, oldest first.
+func (r *materialRepository) FindVersions(materialID uint) ([]model.MaterialVersion, error) {
+	var versions []model.MaterialVersion
+	err := r.db.Preload("Gwp").Where("material_id = ?", materialID).Order("version").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
 }
 
 // ExistsByMaterialName checks if a material with the provided name exists in the database.
@@ -44,11 +63,11 @@ func (r *materialRepository) FindByID(id uint) (*model.Material, error) {
 }
 
 // EagerFindByID retrieves a material from the database based on the provided ID,
-// preloading its assemblies.
+// preloading its versions, its assemblies and the buildings using them.
 // It returns a pointer to the found material and an error, if any.
 func (r *materialRepository) EagerFindByID(id uint) (*model.Material, error) {
 	var material model.Material
-	err := r.db.Preload("Assemblies").First(&material, id).Error
+	err := r.db.Preload("Assemblies.Buildings").Preload("Versions.Gwp").First(&material, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScenarioRepository is an interface for interacting with the scenarios of buildings.
+type ScenarioRepository interface {
+	Save(scenario *model.Scenario) error
+	FindByID(id uint) (*model.Scenario, error)
+	FindByBuildingID(buildingID uint) ([]model.Scenario, error)
+	EagerFindByBuildingID(buildingID uint, ids []uint) ([]model.Scenario, error)
+	Delete(scenario *model.Scenario) error
+	SaveOverride(override *model.ScenarioAssembly) error
+	DeleteOverride(scenarioID uint, assemblyID uint) error
+}
+
+// scenarioRepository is a concrete implementation of ScenarioRepository.
+type scenarioRepository struct {
+	db *gorm.DB
+}
+
+// Save persists a scenario's own fields without touching its overrides.
+func (r *scenarioRepository) Save(scenario *model.Scenario) error {
+	return r.db.Omit(clause.Associations).Save(scenario).Error
+}
+
+// FindByID fetches a scenario by ID, preloading its overrides.
+func (r *scenarioRepository) FindByID(id uint) (*model.Scenario, error) {
+	var scenario model.Scenario
+	err := r.db.Preload("Overrides").First(&scenario, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+// FindByBuildingID fetches the scenarios of a building, preloading their overrides.
+func (r *scenarioRepository) FindByBuildingID(buildingID uint) ([]model.Scenario, error) {
+	var scenarios []model.Scenario
+	err := r.db.Preload("Overrides").Where("building_id = ?", buildingID).Order("id").Find(&scenarios).Error
+	if err != nil {
+		return nil, err
+	}
+	return scenarios, nil
+}
+
+// EagerFindByBuildingID fetches the scenarios of a building with everything
+// needed to calculate them: their overrides with the replacement assemblies'
+// contents. All scenarios of the building are fetched when ids is empty.
+func (r *scenarioRepository) EagerFindByBuildingID(buildingID uint, ids []uint) ([]model.Scenario, error) {
+	var scenarios []model.Scenario
+	query := preloadAssemblyContents(r.db, "Overrides.Replacement.").
+		Preload("Overrides.Replacement").
+		Where("building_id = ?", buildingID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	if err := query.Order("id").Find(&scenarios).Error; err != nil {
+		return nil, err
+	}
+
+	var replacements []*model.Assembly
+	for _, scenario := range scenarios {
+		for _, override := range scenario.Overrides {
+			if override.Replacement != nil {
+				replacements = append(replacements, override.Replacement)
+			}
+		}
+	}
+	if err := loadComponents(r.db, replacements); err != nil {
+		return nil, err
+	}
+	return scenarios, nil
+}
+
+// Delete removes a scenario together with its overrides.
+func (r *scenarioRepository) Delete(scenario *model.Scenario) error {
+	return r.db.Select("Overrides").Delete(scenario).Error
+}
+
+// SaveOverride adds or updates the override of an assembly in a scenario.
+func (r *scenarioRepository) SaveOverride(override *model.ScenarioAssembly) error {
+	return r.db.Omit("Replacement").Save(override).Error
+}
+
+// DeleteOverride removes the override of an assembly from a scenario.
+func (r *scenarioRepository) DeleteOverride(scenarioID uint, assemblyID uint) error {
+	return r.db.Where("scenario_id = ? AND assembly_id = ?", scenarioID, assemblyID).
+		Delete(&model.ScenarioAssembly{}).Error
+}
+
+// NewScenarioRepository creates a new scenario repository.
+// This function should be called only once per application lifetime.
+func NewScenarioRepository(db *gorm.DB) ScenarioRepository {
+	return &scenarioRepository{db: db}
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+)
+
+// SnapshotRepository is an interface for interacting with the snapshots of buildings.
+// Snapshots are immutable, so they can only be created.
+type SnapshotRepository interface {
+	Create(snapshot *model.Snapshot) error
+	FindByID(id uint) (*model.Snapshot, error)
+	FindByBuildingID(buildingID uint) ([]model.Snapshot, error)
+}
+
+// snapshotRepository is a concrete implementation of SnapshotRepository.
+type snapshotRepository struct {
+	db *gorm.DB
+}
+
+// Create persists a new snapshot with its results.
+func (r *snapshotRepository) Create(snapshot *model.Snapshot) error {
+	return r.db.Create(snapshot).Error
+}
+
+// FindByID fetches a snapshot with its state and results.
+func (r *snapshotRepository) FindByID(id uint) (*model.Snapshot, error) {
+	var snapshot model.Snapshot
+	err := r.db.Preload("Elements").Preload("Assemblies").First(&snapshot, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// FindByBuildingID fetches the snapshots of a building in date order with
+// their per element results, leaving out their full state.
+func (r *snapshotRepository) FindByBuildingID(buildingID uint) ([]model.Snapshot, error) {
+	var snapshots []model.Snapshot
+	err := r.db.Omit("State").Preload("Elements").
+		Where("building_id = ?", buildingID).
+		Order("date, id").
+		Find(&snapshots).Error
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// NewSnapshotRepository creates a new snapshot repository.
+// This function should be called only once per application lifetime.
+func NewSnapshotRepository(db *gorm.DB) SnapshotRepository {
+	return &snapshotRepository{db: db}
+}
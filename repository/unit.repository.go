@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"carbon-service/model"
+
+	"gorm.io/gorm"
+)
+
+// UnitRepository is an interface for interacting with the unit preferences table.
+type UnitRepository interface {
+	Save(unit *model.Unit) error
+	FindByOwner(ownerType string, ownerID string) (*model.Unit, error)
+	FindByProjectOrUser(projectID string, userID string) ([]model.Unit, error)
+}
+
+// unitRepository is a concrete implementation of UnitRepository.
+type unitRepository struct {
+	db *gorm.DB
+}
+
+// Save persists a unit preference.
+func (r *unitRepository) Save(unit *model.Unit) error {
+	return r.db.Save(unit).Error
+}
+
+// FindByOwner fetches the unit preference of a user or project.
+func (r *unitRepository) FindByOwner(ownerType string, ownerID string) (*model.Unit, error) {
+	var unit model.Unit
+	err := r.db.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).First(&unit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+// FindByProjectOrUser fetches the unit preferences of a project and of a user
+// in one query. Either may have none.
+func (r *unitRepository) FindByProjectOrUser(projectID string, userID string) ([]model.Unit, error) {
+	var units []model.Unit
+	err := r.db.Where("(owner_type = ? AND owner_id = ?) OR (owner_type = ? AND owner_id = ?)",
+		model.UnitOwnerProject, projectID, model.UnitOwnerUser, userID).Find(&units).Error
+	if err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// NewUnitRepository creates a new unit preference repository.
+// This function should be called only once per application lifetime.
+func NewUnitRepository(db *gorm.DB) UnitRepository {
+	return &unitRepository{db: db}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"fmt"
+)
+
+// ArchetypeService defines the operations available for managing building
+// archetypes and instantiating buildings from them.
+type ArchetypeService interface {
+	CreateArchetype(req CreateArchetypeRequest) (*model.Archetype, error)
+	GetArchetype(id uint) (*model.Archetype, error)
+	GetAllArchetypes() ([]model.Archetype, error)
+	CreateBuildingFromArchetype(req BuildingFromArchetypeRequest) (*model.Building, error)
+}
+
+// archetypeService provides a concrete implementation of the ArchetypeService.
+type archetypeService struct {
+	repo            repository.ArchetypeRepository
+	assemblyRepo    repository.AssemblyRepository // Default assemblies of archetypes
+	buildingService BuildingService               // Creates the buildings instantiated from archetypes
+}
+
+// NewArchetypeService initializes a new archetype service with necessary dependencies.
+func NewArchetypeService(r repository.ArchetypeRepository, ar repository.AssemblyRepository, bs BuildingService) ArchetypeService {
+	return &archetypeService{
+		repo:            r,
+		assemblyRepo:    ar,
+		buildingService: bs,
+	}
+}
+
+type CreateArchetypeRequest struct {
+	Name                  string                    `json:"name" binding:"required"`
+	Description           string                    `json:"description"`
+	FTF                   float64                   `json:"ftf" binding:"required,gt=0"`
+	WWR                   float64                   `json:"wwr" binding:"gte=0,lte=1"`
+	AboveGroundFloorCount int                       `json:"aboveGroundFloorCount" binding:"required,gt=0"`
+	UnderGroundFloorCount int                       `json:"underGroundFloorCount" binding:"gte=0"`
+	Typology              model.UseClass            `json:"typology"`
+	SubstructureFactors   model.SubstructureFactors `json:"substructureFactors"`
+	AssemblyAssignments   []AssemblyAssignment      `json:"assemblyAssignments"`
+}
+
+// BuildingFromArchetypeRequest holds the few inputs a building is instantiated
+// with from an archetype. Parameters that are left out take the archetype's default.
+type BuildingFromArchetypeRequest struct {
+	ArchetypeID           uint     `json:"archetypeId" binding:"required"`
+	Name                  string   `json:"name" binding:"required"`
+	GFA                   float64  `json:"gfa" binding:"required,gt=0"`
+	FTF                   *float64 `json:"ftf" binding:"omitempty,gt=0"`
+	WWR                   *float64 `json:"wwr" binding:"omitempty,gte=0,lte=1"`
+	AboveGroundFloorCount *int     `json:"aboveGroundFloorCount" binding:"omitempty,gt=0"`
+	UnderGroundFloorCount *int     `json:"underGroundFloorCount" binding:"omitempty,gte=0"`
+}
+
+// CreateArchetype stores a new archetype with its default parameters and assemblies.
+func (as *archetypeService) CreateArchetype(req CreateArchetypeRequest) (*model.Archetype, error) {
+	if as.repo.ExistsByArchetypeName(req.Name) {
+		return nil, fmt.Errorf("archetype name '%s' already exists", req.Name)
+	}
+	if req.Typology != "" && !req.Typology.IsValid() {
+		return nil, fmt.Errorf("unknown typology '%s'", req.Typology)
+	}
+
+	archetype := &model.Archetype{
+		Name:                  req.Name,
+		Description:           req.Description,
+		FTF:                   req.FTF,
+		WWR:                   req.WWR,
+		AboveGroundFloorCount: req.AboveGroundFloorCount,
+		UnderGroundFloorCount: req.UnderGroundFloorCount,
+		Typology:              req.Typology,
+		SubstructureFactors:   req.SubstructureFactors,
+	}
+	for _, assignment := range req.AssemblyAssignments {
+		if err := validateAssignment(as.assemblyRepo, assignment.AssemblyID, assignment.AssignAssemblyRequest); err != nil {
+			return nil, err
+		}
+		archetype.AssemblyLinks = append(archetype.AssemblyLinks, &model.ArchetypeAssembly{
+			AssemblyID: assignment.AssemblyID,
+			Element:    assignment.Element,
+			Role:       assignment.Role,
+			Quantity:   assignment.quantity(),
+		})
+	}
+	if err := as.repo.Save(archetype); err != nil {
+		return nil, fmt.Errorf("failed to create archetype: %w", err)
+	}
+	return archetype, nil
+}
+
+// GetArchetype fetches an archetype with its default assemblies.
+func (as *archetypeService) GetArchetype(id uint) (*model.Archetype, error) {
+	archetype, err := as.repo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archetype with ID %d: %w", id, err)
+	}
+	return archetype, nil
+}
+
+// GetAllArchetypes retrieves all archetypes.
+func (as *archetypeService) GetAllArchetypes() ([]model.Archetype, error) {
+	archetypes, err := as.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archetypes: %w", err)
+	}
+	return archetypes, nil
+}
+
+// CreateBuildingFromArchetype creates a building from an archetype and a few
+// inputs. The gross floor area is spread evenly over the floors, and the
+// archetype's assemblies are assigned with their elements and geometric roles.
+func (as *archetypeService) CreateBuildingFromArchetype(req BuildingFromArchetypeRequest) (*model.Building, error) {
+	archetype, err := as.repo.FindByID(req.ArchetypeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archetype with ID %d: %w", req.ArchetypeID, err)
+	}
+
+	building := CreateBuildingRequest{
+		Name:                  req.Name,
+		FTF:                   archetype.FTF,
+		WWR:                   archetype.WWR,
+		AboveGroundFloorCount: archetype.AboveGroundFloorCount,
+		UnderGroundFloorCount: archetype.UnderGroundFloorCount,
+		Typology:              archetype.Typology,
+		SubstructureFactors:   archetype.SubstructureFactors,
+	}
+	if req.FTF != nil {
+		building.FTF = *req.FTF
+	}
+	if req.WWR != nil {
+		building.WWR = *req.WWR
+	}
+	if req.AboveGroundFloorCount != nil {
+		building.AboveGroundFloorCount = *req.AboveGroundFloorCount
+	}
+	if req.UnderGroundFloorCount != nil {
+		building.UnderGroundFloorCount = *req.UnderGroundFloorCount
+	}
+	floors := building.AboveGroundFloorCount + building.UnderGroundFloorCount
+	if floors <= 0 {
+		return nil, fmt.Errorf("a building needs at least one floor")
+	}
+	building.GroundFloorArea = req.GFA / float64(floors)
+
+	for _, link := range archetype.AssemblyLinks {
+		quantity := link.Quantity
+		building.AssemblyAssignments = append(building.AssemblyAssignments, AssemblyAssignment{
+			AssemblyID: link.AssemblyID,
+			AssignAssemblyRequest: AssignAssemblyRequest{
+				Element:  link.Element,
+				Role:     link.Role,
+				Quantity: &quantity,
+			},
+		})
+	}
+	return as.buildingService.CreateBuilding(building)
+}
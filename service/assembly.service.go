@@ -9,17 +9,74 @@ import (
 // AssemblyService defines the operations available for managing assemblies,
 // including creation, retrieval, and material addition.
 type AssemblyService interface {
-	CreateAssembly(name string) (*model.Assembly, error)
+	CreateAssembly(req CreateAssemblyRequest) (*model.Assembly, error)
 	GetAssembly(id uint) (*model.Assembly, error)
 	GetAllAssemblies() ([]model.Assembly, error)
 	ComputeTotalCarbon(assemblyID uint) (float64, error)
+	ComputeBreakdown(assemblyID uint) (*model.BreakdownNode, error)
+	ComputeCarbonPerArea(assemblyID uint) (float64, error)
+	SetLayers(assemblyID uint, layers []LayerRequest) (*model.Assembly, error)
+	AddMaterial(assemblyID uint, req AddMaterialRequest) (*model.Assembly, error)
+	UpdateMaterialQuantity(assemblyID uint, materialID uint, req MaterialQuantityRequest) (*model.Assembly, error)
+	ReorderMaterials(assemblyID uint, materialIDs []uint) (*model.Assembly, error)
+	RemoveMaterial(assemblyID uint, materialID uint) (*model.Assembly, error)
+	GetTemplates() ([]model.Assembly, error)
+	SetTemplate(assemblyID uint, isTemplate bool) (*model.Assembly, error)
+	CloneAssembly(templateID uint, name string) (*model.Assembly, error)
+	GetClones(templateID uint) ([]AssemblyClone, error)
+	AddComponent(assemblyID uint, req AddComponentRequest) (*model.Assembly, error)
+	UpdateComponentQuantity(assemblyID uint, childID uint, quantity float64) (*model.Assembly, error)
+	RemoveComponent(assemblyID uint, childID uint) (*model.Assembly, error)
 }
 
 // assemblyService provides a concrete implementation of the AssemblyService,
 // interacting with assembly data and carbon calculations.
 type assemblyService struct {
 	repo              repository.AssemblyRepository
-	carbonCalcService CalculationService // Dependency for carbon calculations
+	materialRepo      repository.MaterialRepository // Materials referenced by assemblies
+	buildingRepo      repository.BuildingRepository // Buildings using assemblies
+	carbonCalcService CalculationService            // Dependency for carbon calculations
+}
+
+// LayerRequest describes one layer of an assembly, either by its thickness in m
+// or by a rate in rateUnit per m2 of assembly.
+type LayerRequest struct {
+	MaterialID uint    `json:"materialId" binding:"required"`
+	Thickness  float64 `json:"thickness"`
+	Rate       float64 `json:"rate"`
+	RateUnit   string  `json:"rateUnit"`
+}
+
+// CreateAssemblyRequest describes a new assembly. Area is the m2 of layers in
+// one unit of the assembly, one unit is one m2 of layers when it is not given.
+type CreateAssemblyRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	IsTemplate bool     `json:"isTemplate"`
+	Area       *float64 `json:"area" binding:"omitempty,gt=0"`
+}
+
+// AssemblyClone is a project assembly cloned from a template, and whether it
+// has drifted from the template since.
+type AssemblyClone struct {
+	AssemblyID   uint   `json:"assemblyId"`
+	AssemblyName string `json:"assemblyName"`
+	Drifted      bool   `json:"drifted"`
+}
+
+type AddComponentRequest struct {
+	AssemblyID uint    `json:"assemblyId" binding:"required"`
+	Quantity   float64 `json:"quantity" binding:"required,gt=0"`
+}
+
+type AddMaterialRequest struct {
+	MaterialID uint    `json:"materialId" binding:"required"`
+	Quantity   float64 `json:"quantity" binding:"required,gt=0"`
+	Unit       string  `json:"unit"`
+}
+
+type MaterialQuantityRequest struct {
+	Quantity float64 `json:"quantity" binding:"required,gt=0"`
+	Unit     string  `json:"unit"`
 }
 
 // ComputeTotalCarbon implements AssemblyService.
@@ -29,16 +86,351 @@ func (as *assemblyService) ComputeTotalCarbon(assemblyID uint) (float64, error)
 	if err != nil {
 		return 0, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
 	}
+	assembly.UseLatestVersions()
 	return assembly.ComputeWholeLifeCarbon(), nil
 }
 
+// ComputeBreakdown implements AssemblyService.
+// It traces the whole life carbon of one unit of the assembly back to the
+// latest versions of its materials.
+func (as *assemblyService) ComputeBreakdown(assemblyID uint) (*model.BreakdownNode, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	assembly.UseLatestVersions()
+	return assembly.Breakdown(1, "unit"), nil
+}
+
+// ComputeCarbonPerArea implements AssemblyService.
+// It calculates the whole life carbon of one m2 of the assembly's layers.
+func (as *assemblyService) ComputeCarbonPerArea(assemblyID uint) (float64, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	assembly.UseLatestVersions()
+	return assembly.ComputeCarbonPerArea(), nil
+}
+
+// SetLayers implements AssemblyService.
+// It replaces the assembly's layers with the given ones, keeping their order,
+// and converts each layer's thickness or rate to its material's declared unit.
+func (as *assemblyService) SetLayers(assemblyID uint, layers []LayerRequest) (*model.Assembly, error) {
+	if _, err := as.repo.FindByID(assemblyID); err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+
+	assemblyLayers := make([]*model.AssemblyLayer, len(layers))
+	for i, req := range layers {
+		layer, err := as.newLayer(assemblyID, i, req)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer %d: %w", i, err)
+		}
+		assemblyLayers[i] = layer
+	}
+
+	if err := as.repo.ReplaceLayers(assemblyID, assemblyLayers); err != nil {
+		return nil, fmt.Errorf("failed to save layers of assembly %d: %w", assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// AddMaterial implements AssemblyService.
+// The material is appended after the assembly's existing materials.
+func (as *assemblyService) AddMaterial(assemblyID uint, req AddMaterialRequest) (*model.Assembly, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	if assembly.FindMaterialLink(req.MaterialID) != nil {
+		return nil, fmt.Errorf("material %d is already part of assembly %d", req.MaterialID, assemblyID)
+	}
+
+	link := &model.AssemblyMaterial{
+		AssemblyID: assemblyID,
+		MaterialID: req.MaterialID,
+		Position:   len(assembly.MaterialLinks),
+	}
+	if err := as.setQuantity(link, req.Quantity, req.Unit); err != nil {
+		return nil, err
+	}
+	if err := as.repo.SaveMaterialLink(link); err != nil {
+		return nil, fmt.Errorf("failed to add material %d to assembly %d: %w", req.MaterialID, assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// UpdateMaterialQuantity implements AssemblyService.
+func (as *assemblyService) UpdateMaterialQuantity(assemblyID uint, materialID uint, req MaterialQuantityRequest) (*model.Assembly, error) {
+	link, err := as.findMaterialLink(assemblyID, materialID)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.setQuantity(link, req.Quantity, req.Unit); err != nil {
+		return nil, err
+	}
+	if err := as.repo.SaveMaterialLink(link); err != nil {
+		return nil, fmt.Errorf("failed to update material %d of assembly %d: %w", materialID, assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// ReorderMaterials implements AssemblyService.
+// The given list has to contain every material of the assembly exactly once.
+func (as *assemblyService) ReorderMaterials(assemblyID uint, materialIDs []uint) (*model.Assembly, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	if len(materialIDs) != len(assembly.MaterialLinks) {
+		return nil, fmt.Errorf("expected %d materials, got %d", len(assembly.MaterialLinks), len(materialIDs))
+	}
+	seen := make(map[uint]bool, len(materialIDs))
+	for _, materialID := range materialIDs {
+		if assembly.FindMaterialLink(materialID) == nil {
+			return nil, fmt.Errorf("material %d is not part of assembly %d", materialID, assemblyID)
+		}
+		if seen[materialID] {
+			return nil, fmt.Errorf("material %d is listed more than once", materialID)
+		}
+		seen[materialID] = true
+	}
+
+	if err := as.repo.ReorderMaterialLinks(assemblyID, materialIDs); err != nil {
+		return nil, fmt.Errorf("failed to reorder materials of assembly %d: %w", assemblyID, err)
+	}
+	return as.GetAssembly(assemblyID)
+}
+
+// RemoveMaterial implements AssemblyService.
+func (as *assemblyService) RemoveMaterial(assemblyID uint, materialID uint) (*model.Assembly, error) {
+	if _, err := as.findMaterialLink(assemblyID, materialID); err != nil {
+		return nil, err
+	}
+	if err := as.repo.DeleteMaterialLink(assemblyID, materialID); err != nil {
+		return nil, fmt.Errorf("failed to remove material %d from assembly %d: %w", materialID, assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// AddComponent implements AssemblyService.
+// The sub-assembly is appended after the assembly's existing sub-assemblies,
+// adding an assembly that already contains the parent is rejected as a cycle.
+func (as *assemblyService) AddComponent(assemblyID uint, req AddComponentRequest) (*model.Assembly, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	if assembly.FindComponent(req.AssemblyID) != nil {
+		return nil, fmt.Errorf("assembly %d is already part of assembly %d", req.AssemblyID, assemblyID)
+	}
+	child, err := as.repo.EagerFindByID(req.AssemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", req.AssemblyID, err)
+	}
+	if child.Contains(assemblyID) {
+		return nil, fmt.Errorf("adding assembly %d to assembly %d would create a cycle", req.AssemblyID, assemblyID)
+	}
+
+	component := &model.AssemblyComponent{
+		ParentID: assemblyID,
+		ChildID:  child.ID,
+		Position: len(assembly.Components),
+		Quantity: req.Quantity,
+	}
+	if err := as.repo.SaveComponent(component); err != nil {
+		return nil, fmt.Errorf("failed to add assembly %d to assembly %d: %w", req.AssemblyID, assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// UpdateComponentQuantity implements AssemblyService.
+func (as *assemblyService) UpdateComponentQuantity(assemblyID uint, childID uint, quantity float64) (*model.Assembly, error) {
+	component, err := as.findComponent(assemblyID, childID)
+	if err != nil {
+		return nil, err
+	}
+	component.Quantity = quantity
+	if err := as.repo.SaveComponent(component); err != nil {
+		return nil, fmt.Errorf("failed to update assembly %d of assembly %d: %w", childID, assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// RemoveComponent implements AssemblyService.
+func (as *assemblyService) RemoveComponent(assemblyID uint, childID uint) (*model.Assembly, error) {
+	if _, err := as.findComponent(assemblyID, childID); err != nil {
+		return nil, err
+	}
+	if err := as.repo.DeleteComponent(assemblyID, childID); err != nil {
+		return nil, fmt.Errorf("failed to remove assembly %d from assembly %d: %w", childID, assemblyID, err)
+	}
+	return as.recomputeTotals(assemblyID)
+}
+
+// findComponent returns the component linking the assembly to the sub-assembly.
+func (as *assemblyService) findComponent(assemblyID uint, childID uint) (*model.AssemblyComponent, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	component := assembly.FindComponent(childID)
+	if component == nil {
+		return nil, fmt.Errorf("assembly %d is not part of assembly %d", childID, assemblyID)
+	}
+	return component, nil
+}
+
+// findMaterialLink returns the link between the assembly and the material.
+func (as *assemblyService) findMaterialLink(assemblyID uint, materialID uint) (*model.AssemblyMaterial, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	link := assembly.FindMaterialLink(materialID)
+	if link == nil {
+		return nil, fmt.Errorf("material %d is not part of assembly %d", materialID, assemblyID)
+	}
+	return link, nil
+}
+
+// setQuantity validates the material of the link and sets its quantity,
+// converted to the material's declared unit. An empty unit means the
+// quantity is already in the declared unit.
+func (as *assemblyService) setQuantity(link *model.AssemblyMaterial, quantity float64, unit string) error {
+	material, err := as.materialRepo.FindByID(link.MaterialID)
+	if err != nil {
+		return fmt.Errorf("failed to find material with ID %d: %w", link.MaterialID, err)
+	}
+	if unit == "" {
+		unit = material.DeclaredUnit
+	}
+	declared, err := toDeclaredUnit(material, quantity, unit)
+	if err != nil {
+		return err
+	}
+	link.Quantity = quantity
+	link.Unit = unit
+	link.DeclaredQuantity = declared
+	return nil
+}
+
+// recomputeTotals recalculates and persists the cached totals of the assembly
+// with the latest versions of its materials and returns the assembly.
+// Materials new to the buildings using the assembly are pinned first, so the
+// buildings keep calculating with the versions they have now.
+// The totals of every assembly containing it are recalculated as well.
+func (as *assemblyService) recomputeTotals(assemblyID uint) (*model.Assembly, error) {
+	assembly, err := as.repo.EagerFindByID(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	if err := as.refreshLayers(assembly); err != nil {
+		return nil, err
+	}
+
+	buildingIDs, err := as.buildingRepo.FindIDsByAssembly(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find buildings using assembly %d: %w", assemblyID, err)
+	}
+	if err := as.buildingRepo.PinMaterials(buildingIDs); err != nil {
+		return nil, fmt.Errorf("failed to pin material versions of assembly %d: %w", assemblyID, err)
+	}
+
+	assembly.UseLatestVersions()
+	assembly.TotalCarbon = as.carbonCalcService.ComputeWholeLifeCarbonSync(assembly)
+	assembly.CarbonPerArea = assembly.ComputeCarbonPerArea()
+	if err := as.repo.UpdateTotals(assembly); err != nil {
+		return nil, fmt.Errorf("failed to update totals of assembly %d: %w", assemblyID, err)
+	}
+
+	parentIDs, err := as.repo.FindParentIDs(assemblyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assemblies containing assembly %d: %w", assemblyID, err)
+	}
+	for _, parentID := range parentIDs {
+		if _, err := as.recomputeTotals(parentID); err != nil {
+			return nil, err
+		}
+	}
+	return assembly, nil
+}
+
+// newLayer validates a layer request and derives the layer's quantity per m2
+// of assembly in its material's declared unit.
+func (as *assemblyService) newLayer(assemblyID uint, position int, req LayerRequest) (*model.AssemblyLayer, error) {
+	material, err := as.materialRepo.FindByID(req.MaterialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", req.MaterialID, err)
+	}
+
+	declared, err := layerDeclaredQuantity(material, req.Thickness, req.Rate, req.RateUnit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AssemblyLayer{
+		AssemblyID:       assemblyID,
+		Position:         position,
+		MaterialID:       material.ID,
+		Thickness:        req.Thickness,
+		Rate:             req.Rate,
+		RateUnit:         req.RateUnit,
+		DeclaredQuantity: declared,
+	}, nil
+}
+
+// layerDeclaredQuantity converts the thickness or the rate of a layer to the
+// quantity of its material per m2 of assembly in the material's declared unit.
+func layerDeclaredQuantity(material *model.Material, thickness float64, rate float64, rateUnit string) (float64, error) {
+	switch {
+	case thickness > 0 && rate > 0:
+		return 0, fmt.Errorf("a layer has either a thickness or a rate, not both")
+	case thickness > 0:
+		// one m2 of a layer of thickness t is t m3 of material
+		return toDeclaredUnit(material, thickness, "m3")
+	case rate > 0:
+		return toDeclaredUnit(material, rate, rateUnit)
+	default:
+		return 0, fmt.Errorf("a layer needs a thickness or a rate")
+	}
+}
+
+// refreshLayers derives the declared quantity of every layer of the assembly
+// again from its thickness or rate and its material's current properties,
+// and saves the layers if any of them changed.
+func (as *assemblyService) refreshLayers(assembly *model.Assembly) error {
+	changed := false
+	for _, layer := range assembly.Layers {
+		if layer.Material == nil {
+			continue
+		}
+		declared, err := layerDeclaredQuantity(layer.Material, layer.Thickness, layer.Rate, layer.RateUnit)
+		if err != nil {
+			return fmt.Errorf("invalid layer %d of assembly %d: %w", layer.Position, assembly.ID, err)
+		}
+		if declared != layer.DeclaredQuantity {
+			layer.DeclaredQuantity = declared
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := as.repo.ReplaceLayers(assembly.ID, assembly.Layers); err != nil {
+		return fmt.Errorf("failed to save layers of assembly %d: %w", assembly.ID, err)
+	}
+	return nil
+}
+
 // CreateAssembly implements AssemblyService.
-func (as *assemblyService) CreateAssembly(name string) (*model.Assembly, error) {
-	if as.repo.ExistsByAssemblyName(name) {
-		return nil, fmt.Errorf("assembly name '%s' already exists", name)
+func (as *assemblyService) CreateAssembly(req CreateAssemblyRequest) (*model.Assembly, error) {
+	if as.repo.ExistsByAssemblyName(req.Name) {
+		return nil, fmt.Errorf("assembly name '%s' already exists", req.Name)
 	}
 
-	assembly := &model.Assembly{Name: name}
+	assembly := &model.Assembly{Name: req.Name, IsTemplate: req.IsTemplate, Area: req.Area}
 	if err := as.repo.Save(assembly); err != nil {
 		return nil, fmt.Errorf("failed to create assembly: %w", err)
 	}
@@ -46,6 +438,72 @@ func (as *assemblyService) CreateAssembly(name string) (*model.Assembly, error)
 
 }
 
+// GetTemplates implements AssemblyService.
+func (as *assemblyService) GetTemplates() ([]model.Assembly, error) {
+	templates, err := as.repo.FindTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly templates: %w", err)
+	}
+	return templates, nil
+}
+
+// SetTemplate implements AssemblyService.
+func (as *assemblyService) SetTemplate(assemblyID uint, isTemplate bool) (*model.Assembly, error) {
+	if _, err := as.repo.FindByID(assemblyID); err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	if err := as.repo.SetTemplate(assemblyID, isTemplate); err != nil {
+		return nil, fmt.Errorf("failed to update assembly %d: %w", assemblyID, err)
+	}
+	return as.GetAssembly(assemblyID)
+}
+
+// CloneAssembly implements AssemblyService.
+// It deep-copies a library template into a new project assembly that can be
+// edited independently of the template.
+func (as *assemblyService) CloneAssembly(templateID uint, name string) (*model.Assembly, error) {
+	template, err := as.repo.EagerFindByID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", templateID, err)
+	}
+	if !template.IsTemplate {
+		return nil, fmt.Errorf("assembly %d is not a template", templateID)
+	}
+	if as.repo.ExistsByAssemblyName(name) {
+		return nil, fmt.Errorf("assembly name '%s' already exists", name)
+	}
+
+	clone := template.Clone(name)
+	if err := as.repo.Save(clone); err != nil {
+		return nil, fmt.Errorf("failed to clone assembly %d: %w", templateID, err)
+	}
+	return as.GetAssembly(clone.ID)
+}
+
+// GetClones implements AssemblyService.
+// It lists the assemblies cloned from a template and flags those that have
+// drifted from it.
+func (as *assemblyService) GetClones(templateID uint) ([]AssemblyClone, error) {
+	template, err := as.repo.EagerFindByID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", templateID, err)
+	}
+	assemblies, err := as.repo.EagerFindByTemplateID(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find clones of assembly %d: %w", templateID, err)
+	}
+
+	clones := make([]AssemblyClone, len(assemblies))
+	for i := range assemblies {
+		clones[i] = AssemblyClone{
+			AssemblyID:   assemblies[i].ID,
+			AssemblyName: assemblies[i].Name,
+			Drifted:      assemblies[i].DriftedFrom(template),
+		}
+	}
+	return clones, nil
+}
+
 // GetAllAssemblies implements AssemblyService.
 func (a *assemblyService) GetAllAssemblies() ([]model.Assembly, error) {
 	assemblies, err := a.repo.FindAll()
@@ -65,9 +523,11 @@ func (a *assemblyService) GetAssembly(id uint) (*model.Assembly, error) {
 }
 
 // NewAssemblyService initializes a new assembly service with necessary dependencies.
-func NewAssemblyService(r repository.AssemblyRepository, cs CalculationService) AssemblyService {
+func NewAssemblyService(r repository.AssemblyRepository, mr repository.MaterialRepository, br repository.BuildingRepository, cs CalculationService) AssemblyService {
 	return &assemblyService{
 		repo:              r,
+		materialRepo:      mr,
+		buildingRepo:      br,
 		carbonCalcService: cs,
 	}
 }
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"fmt"
+	"sort"
+)
+
+// BenchmarkService defines the operations available for managing benchmark
+// sets and rating buildings against them.
+type BenchmarkService interface {
+	CreateBenchmarkSet(req CreateBenchmarkSetRequest) (*model.BenchmarkSet, error)
+	GetBenchmarkSets() ([]model.BenchmarkSet, error)
+	SeedBenchmarkSets(sets []*model.BenchmarkSet) error
+	RateBuilding(buildingID uint, setName string, methodology string) (*BenchmarkComparison, error)
+}
+
+// benchmarkService provides a concrete implementation of the BenchmarkService.
+type benchmarkService struct {
+	repo              repository.BenchmarkRepository
+	buildingRepo      repository.BuildingRepository // Buildings that are rated
+	carbonCalcService CalculationService            // Dependency for carbon calculations
+}
+
+// NewBenchmarkService initializes a new benchmark service with necessary dependencies.
+func NewBenchmarkService(r repository.BenchmarkRepository, br repository.BuildingRepository, cs CalculationService) BenchmarkService {
+	return &benchmarkService{
+		repo:              r,
+		buildingRepo:      br,
+		carbonCalcService: cs,
+	}
+}
+
+type CreateBenchmarkSetRequest struct {
+	Name       string             `json:"name" binding:"required"`
+	Source     string             `json:"source"`
+	Version    string             `json:"version"`
+	Benchmarks []BenchmarkRequest `json:"benchmarks" binding:"required,dive"`
+}
+
+// BenchmarkRequest is a band or target of a benchmark set, met by buildings of
+// the typology whose carbon per m2 over the module scope is at most Limit.
+type BenchmarkRequest struct {
+	Typology model.UseClass    `json:"typology" binding:"required"`
+	Scope    model.ModuleScope `json:"scope" binding:"required"`
+	Band     string            `json:"band" binding:"required"`
+	Limit    float64           `json:"limit" binding:"gte=0"`
+}
+
+// BenchmarkComparison is how a building rates against the benchmark sets for
+// its typology, with the materials' values under the recorded methodology.
+// Carbon is rated per m2 of Area, the GIA the benchmarks are published per or
+// the GFA when the building has no GIA, as AreaBasis records. Unit is the unit
+// of the carbon per area, limits and distances.
+type BenchmarkComparison struct {
+	BuildingID  uint               `json:"buildingId"`
+	Typology    model.UseClass     `json:"typology"`
+	Area        float64            `json:"area"`
+	AreaBasis   Normalisation      `json:"areaBasis"`
+	Ratings     []BenchmarkRating  `json:"ratings"`
+	Unit        string             `json:"unit"`
+	Methodology *model.Methodology `json:"methodology"`
+	Warnings    []string           `json:"warnings,omitempty"`
+}
+
+// BenchmarkRating is the building's carbon per m2 over the module scope of a
+// benchmark set, the band it achieves and its distance to each target. Band
+// is empty when the building exceeds every target.
+type BenchmarkRating struct {
+	Set           string            `json:"set"`
+	Version       string            `json:"version"`
+	Scope         model.ModuleScope `json:"scope"`
+	Carbon        float64           `json:"carbon"`
+	CarbonPerArea float64           `json:"carbonPerArea"`
+	Band          string            `json:"band"`
+	Targets       []TargetDistance  `json:"targets"`
+}
+
+// TargetDistance is the building's distance to a target in kgCO2e/m2,
+// negative when the building is below it.
+type TargetDistance struct {
+	Band     string  `json:"band"`
+	Limit    float64 `json:"limit"`
+	Distance float64 `json:"distance"`
+	Met      bool    `json:"met"`
+}
+
+// CreateBenchmarkSet stores a new benchmark set.
+func (bms *benchmarkService) CreateBenchmarkSet(req CreateBenchmarkSetRequest) (*model.BenchmarkSet, error) {
+	if bms.repo.ExistsByBenchmarkSetName(req.Name) {
+		return nil, fmt.Errorf("benchmark set name '%s' already exists", req.Name)
+	}
+
+	set := &model.BenchmarkSet{Name: req.Name, Source: req.Source, Version: req.Version}
+	for _, benchmark := range req.Benchmarks {
+		if !benchmark.Typology.IsValid() {
+			return nil, fmt.Errorf("unknown typology '%s'", benchmark.Typology)
+		}
+		if _, err := benchmark.Scope.Modules(); err != nil {
+			return nil, err
+		}
+		set.Benchmarks = append(set.Benchmarks, &model.Benchmark{
+			Typology: benchmark.Typology,
+			Scope:    benchmark.Scope,
+			Band:     benchmark.Band,
+			Limit:    benchmark.Limit,
+		})
+	}
+	if err := bms.repo.Save(set); err != nil {
+		return nil, fmt.Errorf("failed to create benchmark set: %w", err)
+	}
+	return set, nil
+}
+
+// GetBenchmarkSets retrieves all benchmark sets with their benchmarks.
+func (bms *benchmarkService) GetBenchmarkSets() ([]model.BenchmarkSet, error) {
+	sets, err := bms.repo.FindAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find benchmark sets: %w", err)
+	}
+	return sets, nil
+}
+
+// SeedBenchmarkSets stores the given benchmark sets that are not stored yet.
+func (bms *benchmarkService) SeedBenchmarkSets(sets []*model.BenchmarkSet) error {
+	for _, set := range sets {
+		if bms.repo.ExistsByBenchmarkSetName(set.Name) {
+			continue
+		}
+		if err := bms.repo.Save(set); err != nil {
+			return fmt.Errorf("failed to seed benchmark set '%s': %w", set.Name, err)
+		}
+	}
+	return nil
+}
+
+// RateBuilding rates the building against every benchmark set, or only the
+// named one, that has benchmarks for the building's typology. The building is
+// calculated by the methodology, the building's own when none is given, over
+// the module scope of each benchmark set rather than the methodology's own,
+// per m2 of GIA, or of GFA with a warning when the building has no GIA.
+func (bms *benchmarkService) RateBuilding(buildingID uint, setName string, methodologyID string) (*BenchmarkComparison, error) {
+	building, err := bms.buildingRepo.EagerFindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	if building.Typology == "" {
+		return nil, fmt.Errorf("building %d has no typology", buildingID)
+	}
+	area, basis := building.GIA, NormalisePerGIA
+	var warnings []string
+	if area <= 0 {
+		area, basis = building.GFA, NormalisePerGFA
+		warnings = append(warnings, fmt.Sprintf("building %d has no GIA, it is rated per m2 of GFA while the benchmarks are per m2 of GIA", buildingID))
+	}
+	if area <= 0 {
+		return nil, fmt.Errorf("building %d has no floor area", buildingID)
+	}
+	methodology, err := resolveMethodology(methodologyID, building)
+	if err != nil {
+		return nil, err
+	}
+	building.PinMaterialVersions()
+	buildingCarbon, err := bms.carbonCalcService.ComputeBuildingCarbon(building, methodology)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []model.BenchmarkSet
+	if setName != "" {
+		set, err := bms.repo.FindByName(setName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find benchmark set '%s': %w", setName, err)
+		}
+		sets = append(sets, *set)
+	} else if sets, err = bms.repo.FindAll(); err != nil {
+		return nil, fmt.Errorf("failed to find benchmark sets: %w", err)
+	}
+
+	comparison := &BenchmarkComparison{
+		BuildingID:  building.ID,
+		Typology:    building.Typology,
+		Area:        area,
+		AreaBasis:   basis,
+		Ratings:     []BenchmarkRating{},
+		Methodology: buildingCarbon.Methodology,
+		Warnings:    warnings,
+	}
+	for i := range sets {
+		byScope := sets[i].BenchmarksFor(building.Typology)
+		scopes := make([]model.ModuleScope, 0, len(byScope))
+		for scope := range byScope {
+			scopes = append(scopes, scope)
+		}
+		sort.Slice(scopes, func(a, b int) bool { return scopes[a] < scopes[b] })
+
+		for _, scope := range scopes {
+			modules, err := scope.Modules()
+			if err != nil {
+				return nil, err
+			}
+			carbon := building.CalculateCarbonForPhase(modules...)
+			comparison.Ratings = append(comparison.Ratings, newBenchmarkRating(&sets[i], scope, byScope[scope], carbon, area))
+		}
+	}
+	return comparison, nil
+}
+
+// newBenchmarkRating rates the carbon of a building against the benchmarks of
+// one set and module scope, ordered from the strictest limit up.
+func newBenchmarkRating(set *model.BenchmarkSet, scope model.ModuleScope, benchmarks []*model.Benchmark, carbon float64, area float64) BenchmarkRating {
+	carbonPerArea := perArea(carbon, area)
+	rating := BenchmarkRating{
+		Set:           set.Name,
+		Version:       set.Version,
+		Scope:         scope,
+		Carbon:        carbon,
+		CarbonPerArea: carbonPerArea,
+		Targets:       make([]TargetDistance, len(benchmarks)),
+	}
+	if band := model.Rate(benchmarks, carbonPerArea); band != nil {
+		rating.Band = band.Band
+	}
+	for i, benchmark := range benchmarks {
+		rating.Targets[i] = TargetDistance{
+			Band:     benchmark.Band,
+			Limit:    benchmark.Limit,
+			Distance: carbonPerArea - benchmark.Limit,
+			Met:      carbonPerArea <= benchmark.Limit,
+		}
+	}
+	return rating
+}
@@ -0,0 +1,74 @@
+package service
+
+import "carbon-service/model"
+
+// bands builds the benchmarks of one typology and module scope from pairs of
+// band names and limits in kgCO2e/m2.
+func bands(typology model.UseClass, scope model.ModuleScope, limits map[string]float64) []*model.Benchmark {
+	benchmarks := make([]*model.Benchmark, 0, len(limits))
+	for band, limit := range limits {
+		benchmarks = append(benchmarks, &model.Benchmark{Typology: typology, Scope: scope, Band: band, Limit: limit})
+	}
+	return benchmarks
+}
+
+// DefaultBenchmarkSets returns the published benchmark sets the service is
+// seeded with: the LETI upfront carbon bands, the RIBA 2030 Climate Challenge
+// embodied carbon targets and the GLA whole life carbon benchmarks.
+func DefaultBenchmarkSets() []*model.BenchmarkSet {
+	leti := &model.BenchmarkSet{
+		Name:    "LETI",
+		Source:  "LETI Embodied Carbon Target Alignment",
+		Version: "2021",
+	}
+	for typology, limits := range map[model.UseClass]map[string]float64{
+		model.UseOffice:      {"A++": 100, "A+": 225, "A": 350, "B": 475, "C": 600, "D": 775, "E": 950, "F": 1100, "G": 1300},
+		model.UseResidential: {"A++": 100, "A+": 200, "A": 300, "B": 400, "C": 500, "D": 675, "E": 850, "F": 1000, "G": 1200},
+		model.UseEducation:   {"A++": 100, "A+": 200, "A": 300, "B": 400, "C": 500, "D": 625, "E": 750, "F": 875, "G": 1000},
+		model.UseRetail:      {"A++": 100, "A+": 200, "A": 275, "B": 350, "C": 425, "D": 575, "E": 725, "F": 850, "G": 1000},
+	} {
+		leti.Benchmarks = append(leti.Benchmarks, bands(typology, model.ScopeUpfront, limits)...)
+	}
+
+	riba := &model.BenchmarkSet{
+		Name:    "RIBA 2030",
+		Source:  "RIBA 2030 Climate Challenge",
+		Version: "2",
+	}
+	for typology, limits := range map[model.UseClass]map[string]float64{
+		model.UseOffice:      {"2030 target": 750, "2025 target": 970, "Business as usual": 1400},
+		model.UseResidential: {"2030 target": 625, "2025 target": 800, "Business as usual": 1200},
+		model.UseEducation:   {"2030 target": 540, "2025 target": 675, "Business as usual": 1000},
+	} {
+		riba.Benchmarks = append(riba.Benchmarks, bands(typology, model.ScopeEmbodied, limits)...)
+	}
+
+	gla := &model.BenchmarkSet{
+		Name:    "GLA",
+		Source:  "GLA Whole Life-Cycle Carbon Assessments guidance",
+		Version: "2022",
+	}
+	for typology, limits := range map[model.UseClass][2]map[string]float64{
+		model.UseOffice: {
+			{"Aspirational benchmark": 600, "WLC benchmark": 950},
+			{"Aspirational benchmark": 370, "WLC benchmark": 450},
+		},
+		model.UseResidential: {
+			{"Aspirational benchmark": 500, "WLC benchmark": 850},
+			{"Aspirational benchmark": 300, "WLC benchmark": 350},
+		},
+		model.UseEducation: {
+			{"Aspirational benchmark": 600, "WLC benchmark": 1000},
+			{"Aspirational benchmark": 230, "WLC benchmark": 250},
+		},
+		model.UseRetail: {
+			{"Aspirational benchmark": 550, "WLC benchmark": 850},
+			{"Aspirational benchmark": 140, "WLC benchmark": 200},
+		},
+	} {
+		gla.Benchmarks = append(gla.Benchmarks, bands(typology, model.ScopeUpfront, limits[0])...)
+		gla.Benchmarks = append(gla.Benchmarks, bands(typology, model.ScopeUseAndEndOfLife, limits[1])...)
+	}
+
+	return []*model.BenchmarkSet{leti, riba, gla}
+}
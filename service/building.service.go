@@ -3,7 +3,12 @@ package service
 import (
 	"carbon-service/model"
 	"carbon-service/repository"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 // BuildingService defines the operations available for managing buildings,
@@ -12,39 +17,178 @@ type BuildingService interface {
 	CreateBuilding(req CreateBuildingRequest) (*model.Building, error)
 	GetBuilding(id uint) (*model.Building, error)
 	GetAllBuildings() ([]model.Building, error)
-	ComputeTotalCarbon(buildingID uint) (float64, error)
-	ComputeEmbodiedCarbon(buildingID uint) (float64, error)
+	ComputeTotalCarbon(buildingID uint, opts CalculationOptions) (*CarbonResult, error)
+	ComputeEmbodiedCarbon(buildingID uint, opts CalculationOptions) (*CarbonResult, error)
 	UpdateBuilding(id uint, req UpdateBuildingRequest) (*model.Building, error)
+	AssignAssembly(buildingID uint, assemblyID uint, req AssignAssemblyRequest) (*model.Building, error)
+	EstimateSubstructure(buildingID uint, normalisation Normalisation) (*SubstructureResult, error)
+	ComputeHotspots(buildingID uint, scope model.ModuleScope, methodology string) (*HotspotAnalysis, error)
 }
 
 // buildingService provides a concrete implementation of the BuildingService,
 // interacting with building data and carbon calculations.
 type buildingService struct {
 	repo              repository.BuildingRepository
-	carbonCalcService CalculationService // Dependency for carbon calculations
+	assemblyRepo      repository.AssemblyRepository       // Assemblies used on buildings
+	runRepo           repository.CalculationRunRepository // Runs total carbon results are cached in
+	carbonCalcService CalculationService                  // Dependency for carbon calculations
 }
 
 // NewBuildingService initializes a new building service with necessary dependencies.
-func NewBuildingService(r repository.BuildingRepository, cs CalculationService) BuildingService {
+func NewBuildingService(r repository.BuildingRepository, ar repository.AssemblyRepository, rr repository.CalculationRunRepository, cs CalculationService) BuildingService {
 	return &buildingService{
 		repo:              r,
+		assemblyRepo:      ar,
+		runRepo:           rr,
 		carbonCalcService: cs,
 	}
 }
 
+// CalculationOptions select how a building calculation is reported: the
+// denominator its results are normalised by, the ID of the methodology they
+// are calculated by, the building's own methodology when empty, and whether
+// the result is traced back to its sources in a breakdown.
+type CalculationOptions struct {
+	Normalisation Normalisation
+	Methodology   string
+	Detailed      bool
+}
+
+// CarbonResult is the result of a building calculation: its total and the
+// total of each building element and life cycle module, in reporting order,
+// normalised by the recorded denominator and expressed in Unit, calculated by
+// the recorded methodology. Total carbon results record the calculation run
+// they were stored in, and whether that run was cached from an earlier request.
+type CarbonResult struct {
+	Total         float64            `json:"total"`
+	ByElement     []ElementCarbon    `json:"byElement"`
+	ByModule      []ModuleCarbon     `json:"byModule,omitempty"`
+	Normalisation *Denominator       `json:"normalisation"`
+	Unit          string             `json:"unit"`
+	Methodology   *model.Methodology `json:"methodology"`
+	RunID         uint               `json:"runId,omitempty"`
+	CalculatedAt  time.Time          `json:"calculatedAt"`
+	Cached        bool               `json:"cached"`
+
+	// Breakdown traces the absolute result in kgCO2e back to the EPDs it came
+	// from, only for detailed calculations
+	Breakdown *model.BreakdownNode `json:"breakdown,omitempty"`
+}
+
+// SubstructureResult is the estimated substructure of a building with the
+// carbon of each part normalised by the recorded denominator and expressed in Unit.
+type SubstructureResult struct {
+	model.SubstructureEstimate
+	Normalisation *Denominator `json:"normalisation"`
+	Unit          string       `json:"unit"`
+}
+
+// ElementCarbon is the carbon of one building element.
+type ElementCarbon struct {
+	Element model.Element `json:"element"`
+	Carbon  float64       `json:"carbon"`
+}
+
+// newCarbonResult orders per element totals for reporting.
+func newCarbonResult(total float64, byElement map[model.Element]float64) *CarbonResult {
+	result := &CarbonResult{Total: total, ByElement: []ElementCarbon{}}
+	for _, element := range model.Elements {
+		if carbon, ok := byElement[element]; ok {
+			result.ByElement = append(result.ByElement, ElementCarbon{Element: element, Carbon: carbon})
+		}
+	}
+	return result
+}
+
+// elementDeltas subtracts the per element results of from from those of to,
+// for every element present in either.
+func elementDeltas(from []ElementCarbon, to []ElementCarbon) []ElementCarbon {
+	fromByElement := make(map[model.Element]float64, len(from))
+	for _, element := range from {
+		fromByElement[element.Element] = element.Carbon
+	}
+	toByElement := make(map[model.Element]float64, len(to))
+	for _, element := range to {
+		toByElement[element.Element] = element.Carbon
+	}
+
+	deltas := []ElementCarbon{}
+	for _, element := range model.Elements {
+		fromCarbon, inFrom := fromByElement[element]
+		toCarbon, inTo := toByElement[element]
+		if inFrom || inTo {
+			deltas = append(deltas, ElementCarbon{Element: element, Carbon: toCarbon - fromCarbon})
+		}
+	}
+	return deltas
+}
+
 type CreateBuildingRequest struct {
 	Name                  string           `json:"name" binding:"required"`
 	FTF                   float64          `json:"ftf" binding:"required"`
-	GroundFloorArea       float64          `json:"groundFloorArea" binding:"required"`
+	GroundFloorArea       float64          `json:"groundFloorArea" binding:"required,gt=0"`
 	WWR                   float64          `json:"wwr" binding:"required"`
 	AboveGroundFloorCount int              `json:"aboveGroundFloorCount" binding:"required"`
 	UnderGroundFloorCount int              `json:"underGroundFloorCount" binding:"required"`
 	Assemblies            []model.Assembly `json:"assemblies"`
+	Typology              model.UseClass   `json:"typology"`
+	GIA                   float64          `json:"gia" binding:"gte=0"`
+	StudyPeriod           int              `json:"studyPeriod" binding:"gte=0"`
+	Occupants             int              `json:"occupants" binding:"gte=0"`
+	Bedrooms              int              `json:"bedrooms" binding:"gte=0"`
+	Methodology           string           `json:"methodology"`
+
+	// SubstructureFactors override the default substructure carbon factors
+	SubstructureFactors model.SubstructureFactors `json:"substructureFactors"`
+
+	// AssemblyAssignments add assemblies to the building with their element,
+	// geometric role or quantity
+	AssemblyAssignments []AssemblyAssignment `json:"assemblyAssignments"`
 }
 
+// UpdateBuildingRequest holds the fields of a building to change,
+// fields that are left out keep their current value. Assemblies, when given,
+// replace the assemblies used on the building as a whole.
 type UpdateBuildingRequest struct {
-	Name       string            `json:"name"`
-	Assemblies []*model.Assembly `json:"assemblies"`
+	Name                  string            `json:"name"`
+	Assemblies            []*model.Assembly `json:"assemblies"`
+	FTF                   *float64          `json:"ftf"`
+	GroundFloorArea       *float64          `json:"groundFloorArea" binding:"omitempty,gt=0"`
+	WWR                   *float64          `json:"wwr"`
+	AboveGroundFloorCount *int              `json:"aboveGroundFloorCount"`
+	UnderGroundFloorCount *int              `json:"underGroundFloorCount"`
+	Typology              *model.UseClass   `json:"typology"`
+	GIA                   *float64          `json:"gia" binding:"omitempty,gte=0"`
+	StudyPeriod           *int              `json:"studyPeriod" binding:"omitempty,gte=0"`
+	Occupants             *int              `json:"occupants" binding:"omitempty,gte=0"`
+	Bedrooms              *int              `json:"bedrooms" binding:"omitempty,gte=0"`
+	Methodology           *string           `json:"methodology"`
+
+	SubstructureFactors *model.SubstructureFactors `json:"substructureFactors"`
+}
+
+// AssignAssemblyRequest describes how an assembly is used on a building.
+// An assembly with a geometric role takes its quantity from the building's
+// geometry and defaults to the role's element, otherwise Quantity is used,
+// which defaults to 1 when it is left out.
+type AssignAssemblyRequest struct {
+	Element  model.Element       `json:"element"`
+	Role     model.GeometricRole `json:"role"`
+	Quantity *float64            `json:"quantity" binding:"omitempty,gte=0"`
+}
+
+// quantity returns the requested quantity, 1 when it is left out.
+func (r AssignAssemblyRequest) quantity() float64 {
+	if r.Quantity == nil {
+		return 1
+	}
+	return *r.Quantity
+}
+
+// AssemblyAssignment is an assembly to add to a building and how it is used on it.
+type AssemblyAssignment struct {
+	AssemblyID uint `json:"assemblyId" binding:"required"`
+	AssignAssemblyRequest
 }
 
 // CreateBuilding attempts to add a new building with the given name,
@@ -53,6 +197,19 @@ func (bs *buildingService) CreateBuilding(req CreateBuildingRequest) (*model.Bui
 	if bs.repo.ExistsByBuildingName(req.Name) {
 		return nil, fmt.Errorf("building name '%s' already exists", req.Name)
 	}
+	if req.Typology != "" && !req.Typology.IsValid() {
+		return nil, fmt.Errorf("unknown typology '%s'", req.Typology)
+	}
+	if req.Methodology != "" {
+		if _, err := FindMethodology(req.Methodology); err != nil {
+			return nil, err
+		}
+	}
+	for _, assignment := range req.AssemblyAssignments {
+		if err := validateAssignment(bs.assemblyRepo, assignment.AssemblyID, assignment.AssignAssemblyRequest); err != nil {
+			return nil, err
+		}
+	}
 
 	assemblies := make([]*model.Assembly, len(req.Assemblies))
 	for i, assembly := range req.Assemblies {
@@ -66,25 +223,114 @@ func (bs *buildingService) CreateBuilding(req CreateBuildingRequest) (*model.Bui
 		WWR:                   req.WWR,
 		AboveGroundFloorCount: req.AboveGroundFloorCount,
 		UnderGroundFloorCount: req.UnderGroundFloorCount,
+		Typology:              req.Typology,
+		GIA:                   req.GIA,
+		StudyPeriod:           req.StudyPeriod,
+		Occupants:             req.Occupants,
+		Bedrooms:              req.Bedrooms,
+		Methodology:           req.Methodology,
+		SubstructureFactors:   req.SubstructureFactors,
 		Assemblies:            assemblies, // This can be an empty slice if no assemblies are provided
 	}
-	if err := bs.repo.Save(building); err != nil {
+	if err := building.ValidateGeometry(); err != nil {
+		return nil, err
+	}
+	building.UpdateGeometry()
+
+	links := make([]*model.BuildingAssembly, len(req.AssemblyAssignments))
+	for i, assignment := range req.AssemblyAssignments {
+		links[i] = newAssemblyLink(building, assignment.AssemblyID, assignment.AssignAssemblyRequest)
+	}
+	if err := bs.repo.Create(building, links); err != nil {
 		return nil, fmt.Errorf("failed to create building: %w", err)
 	}
-	return building, nil
+	if len(req.AssemblyAssignments) == 0 {
+		return building, nil
+	}
+	return bs.GetBuilding(building.ID)
 }
 
 // updateBuilding updates the building with the given ID using the provided data.
+// Changes to the geometry update the quantities of assemblies with a geometric role.
 func (bs *buildingService) UpdateBuilding(id uint, req UpdateBuildingRequest) (*model.Building, error) {
-	building, err := bs.repo.FindByID(id)
+	building, err := bs.repo.EagerFindByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find building with ID %d: %w", id, err)
 	}
+	if req.Name != "" && req.Name != building.Name && bs.repo.ExistsByBuildingName(req.Name) {
+		return nil, fmt.Errorf("building name '%s' already exists", req.Name)
+	}
 
 	// map the new values to the building
-	building.Name = req.Name
-	building.Assemblies = req.Assemblies
+	if req.Name != "" {
+		building.Name = req.Name
+	}
+	if req.FTF != nil {
+		building.FTF = *req.FTF
+	}
+	if req.GroundFloorArea != nil {
+		building.GroundFloorArea = *req.GroundFloorArea
+	}
+	if req.WWR != nil {
+		building.WWR = *req.WWR
+	}
+	if req.AboveGroundFloorCount != nil {
+		building.AboveGroundFloorCount = *req.AboveGroundFloorCount
+	}
+	if req.UnderGroundFloorCount != nil {
+		building.UnderGroundFloorCount = *req.UnderGroundFloorCount
+	}
+	if req.Typology != nil {
+		if *req.Typology != "" && !req.Typology.IsValid() {
+			return nil, fmt.Errorf("unknown typology '%s'", *req.Typology)
+		}
+		building.Typology = *req.Typology
+	}
+	if req.GIA != nil {
+		building.GIA = *req.GIA
+	}
+	if req.StudyPeriod != nil {
+		building.StudyPeriod = *req.StudyPeriod
+	}
+	if req.Occupants != nil {
+		building.Occupants = *req.Occupants
+	}
+	if req.Bedrooms != nil {
+		building.Bedrooms = *req.Bedrooms
+	}
+	if req.Methodology != nil {
+		if *req.Methodology != "" {
+			if _, err := FindMethodology(*req.Methodology); err != nil {
+				return nil, err
+			}
+		}
+		building.Methodology = *req.Methodology
+	}
+	if req.SubstructureFactors != nil {
+		building.SubstructureFactors = *req.SubstructureFactors
+	}
 
+	if err := building.ValidateGeometry(); err != nil {
+		return nil, err
+	}
+	building.UpdateGeometry()
+	if err := bs.repo.Update(building); err != nil {
+		return nil, fmt.Errorf("failed to update building %d: %w", id, err)
+	}
+	if req.Assemblies != nil {
+		if err := bs.repo.ReplaceAssemblies(building, req.Assemblies); err != nil {
+			return nil, fmt.Errorf("failed to replace assemblies of building %d: %w", id, err)
+		}
+		if err := bs.repo.PinMaterials([]uint{id}); err != nil {
+			return nil, fmt.Errorf("failed to pin material versions for building %d: %w", id, err)
+		}
+		if building, err = bs.repo.EagerFindByID(id); err != nil {
+			return nil, fmt.Errorf("failed to find building with ID %d: %w", id, err)
+		}
+	}
+	if err := bs.repo.SaveAssemblyLinks(building.RefreshRoleQuantities()); err != nil {
+		return nil, fmt.Errorf("failed to update assembly quantities of building %d: %w", id, err)
+	}
 	return building, nil
 }
 
@@ -108,28 +354,313 @@ func (bs *buildingService) GetAllBuildings() ([]model.Building, error) {
 	return buildings, nil
 }
 
-// Example of a method in the buildingService that preloads necessary data before calculation
-func (bs *buildingService) ComputeTotalCarbon(buildingID uint) (float64, error) {
-	var building *model.Building
+// ComputeTotalCarbon implements BuildingService. The inputs of the building are
+// loaded and hashed first, and only if no run of the same inputs is cached is
+// the building loaded with every material version and calculated.
+func (bs *buildingService) ComputeTotalCarbon(buildingID uint, opts CalculationOptions) (*CarbonResult, error) {
+	inputs, latest, err := bs.repo.FindCalculationInputs(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	methodology, err := resolveMethodology(opts.Methodology, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if inputs.StudyPeriod == 0 {
+		inputs.StudyPeriod = methodology.StudyPeriod
+	}
+	denominator, err := NewDenominator(inputs, opts.Normalisation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return the result of an earlier calculation with the same inputs, if any,
+	// that is detailed enough
+	inputsHash, err := model.CalculationInputsHash(inputs, inputs.CalculationVersions(latest), methodology)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the inputs of building %d: %w", buildingID, err)
+	}
+	run, err := bs.runRepo.FindByInputsHash(buildingID, inputsHash)
+	if err == nil && (!opts.Detailed || len(run.Breakdown) > 0) {
+		if run, err = bs.recordReturnTo(run); err != nil {
+			return nil, err
+		}
+		result, err := newRunResult(run)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.Detailed {
+			result.Breakdown = nil
+		}
+		result.Cached = true
+		result.Normalise(denominator)
+		return result, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to find calculation runs of building %d: %w", buildingID, err)
+	}
+
 	// Preload Assemblies and Materials for the building
-	building, err := bs.repo.EagerFindByID(buildingID) // Assign the value to building pointer
+	building, err := bs.repo.EagerFindByID(buildingID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
 	}
+	building.StudyPeriod = inputs.StudyPeriod
+
+	// Calculate with the material versions the building is pinned to so that
+	// renewed EPDs do not silently change the result
+	building.PinMaterialVersions()
 
 	// Now that we have a fully loaded building, calculate the total carbon impact
-	return bs.carbonCalcService.ComputeWholeLifeCarbonSync(building), nil
+	// by the methodology's rules
+	result, err := bs.carbonCalcService.ComputeBuildingCarbon(building, methodology)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Detailed {
+		result.Breakdown = building.Breakdown()
+	}
+	result.CalculatedAt = time.Now()
+
+	run, err = newCalculationRun(buildingID, inputsHash, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := bs.runRepo.Create(run); err != nil {
+		return nil, fmt.Errorf("failed to save calculation run of building %d: %w", buildingID, err)
+	}
+	result.RunID = run.ID
+	result.Normalise(denominator)
+	return result, nil
+}
+
+// recordReturnTo records a new run with the result of an earlier run if the
+// building was calculated with other inputs since, and returns the run that
+// is now the latest.
+func (bs *buildingService) recordReturnTo(run *model.CalculationRun) (*model.CalculationRun, error) {
+	previous, err := bs.runRepo.FindLatestByBuildingID(run.BuildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find calculation runs of building %d: %w", run.BuildingID, err)
+	}
+	if previous.ID == run.ID {
+		return run, nil
+	}
+	again := *run
+	again.Model = gorm.Model{}
+	again.CalculatedAt = time.Now()
+	if err := bs.runRepo.Create(&again); err != nil {
+		return nil, fmt.Errorf("failed to save calculation run of building %d: %w", run.BuildingID, err)
+	}
+	return &again, nil
+}
+
+// newCalculationRun records the absolute result of a calculation, with its
+// breakdown, if it was detailed, stored apart so that runs can be listed without it.
+func newCalculationRun(buildingID uint, inputsHash string, result *CarbonResult) (*model.CalculationRun, error) {
+	var breakdown []byte
+	if result.Breakdown != nil {
+		var err error
+		if breakdown, err = json.Marshal(result.Breakdown); err != nil {
+			return nil, fmt.Errorf("failed to record breakdown of building %d: %w", buildingID, err)
+		}
+	}
+	summary := *result
+	summary.Breakdown = nil
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record result of building %d: %w", buildingID, err)
+	}
+	return &model.CalculationRun{
+		BuildingID:         buildingID,
+		InputsHash:         inputsHash,
+		Methodology:        result.Methodology.ID,
+		MethodologyVersion: result.Methodology.Version,
+		CalculatedAt:       result.CalculatedAt,
+		TotalCarbon:        result.Total,
+		Result:             data,
+		Breakdown:          breakdown,
+	}, nil
+}
+
+// newRunResult returns the absolute result stored in the run, with its
+// breakdown if the run was loaded with it.
+func newRunResult(run *model.CalculationRun) (*CarbonResult, error) {
+	var result CarbonResult
+	if err := json.Unmarshal(run.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to read result of calculation run %d: %w", run.ID, err)
+	}
+	if len(run.Breakdown) > 0 {
+		if err := json.Unmarshal(run.Breakdown, &result.Breakdown); err != nil {
+			return nil, fmt.Errorf("failed to read breakdown of calculation run %d: %w", run.ID, err)
+		}
+	}
+	result.RunID = run.ID
+	result.CalculatedAt = run.CalculatedAt
+	return &result, nil
+}
+
+// ComputeHotspots ranks the elements, assemblies and materials of the building
+// by the carbon they contribute to the module scope, calculated by the
+// methodology, the building's own when none is given. The scope defaults to
+// the modules the methodology includes, so the total matches its result.
+func (bs *buildingService) ComputeHotspots(buildingID uint, scope model.ModuleScope, methodologyID string) (*HotspotAnalysis, error) {
+	var modules []string
+	var err error
+	if scope != "" {
+		if modules, err = scope.Modules(); err != nil {
+			return nil, err
+		}
+	}
+	building, err := bs.repo.EagerFindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	methodology, err := resolveMethodology(methodologyID, building)
+	if err != nil {
+		return nil, err
+	}
+	if scope == "" {
+		scope = methodology.Scope
+		if modules, err = methodology.Modules(); err != nil {
+			return nil, err
+		}
+	}
+	building.PinMaterialVersions()
+	carbon, err := bs.carbonCalcService.ComputeBuildingCarbon(building, methodology)
+	if err != nil {
+		return nil, err
+	}
+	analysis := NewHotspotAnalysis(building, scope, modules)
+	analysis.Methodology = carbon.Methodology
+	return analysis, nil
 }
 
 // method computes embodied carbon of building
-func (bs *buildingService) ComputeEmbodiedCarbon(buildingID uint) (float64, error) {
+func (bs *buildingService) ComputeEmbodiedCarbon(buildingID uint, opts CalculationOptions) (*CarbonResult, error) {
 	var building *model.Building
 	// Preload Assemblies and Materials for the building
 	building, err := bs.repo.EagerFindByID(buildingID) // Assign the value to building pointer
 	if err != nil {
-		return 0, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	methodology, err := resolveMethodology(opts.Methodology, building)
+	if err != nil {
+		return nil, err
+	}
+	if building.StudyPeriod == 0 {
+		building.StudyPeriod = methodology.StudyPeriod
+	}
+	denominator, err := NewDenominator(building, opts.Normalisation)
+	if err != nil {
+		return nil, err
 	}
 
-	// Now that we have a fully loaded building, calculate the total carbon impact
-	return bs.carbonCalcService.ComputeEmbodiedCarbonSync(building), nil
+	// Now that we have a fully loaded building, estimate the embodied carbon
+	// by the methodology's rules
+	result, err := bs.carbonCalcService.ComputeEmbodiedEstimate(building, methodology)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Detailed {
+		result.Breakdown = &model.BreakdownNode{Level: model.LevelBuilding, ID: building.ID, Name: building.Name}
+		if result.Total != 0 {
+			result.Breakdown = building.EmbodiedBreakdown()
+			result.Breakdown.AddUplift(methodology.Uplift, methodology.ID)
+		}
+	}
+	result.Normalise(denominator)
+	return result, nil
+}
+
+// newEmbodiedModuleCarbon reports the embodied carbon estimate by life cycle
+// module. Its area rates are upfront rates, so it is all product and construction.
+func newEmbodiedModuleCarbon(total float64) []ModuleCarbon {
+	byModule := make([]ModuleCarbon, len(model.Modules))
+	for i, module := range model.Modules {
+		byModule[i] = ModuleCarbon{Module: module}
+		if module == model.ModuleA1toA5 {
+			byModule[i].Carbon = total
+		}
+	}
+	return byModule
+}
+
+// EstimateSubstructure estimates the building's retaining walls, basement
+// slabs, foundations and excavation from its footprint and underground floors,
+// with their carbon normalised by one of the Normalisation denominators.
+func (bs *buildingService) EstimateSubstructure(buildingID uint, normalisation Normalisation) (*SubstructureResult, error) {
+	building, err := bs.repo.FindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	denominator, err := NewDenominator(building, normalisation)
+	if err != nil {
+		return nil, err
+	}
+	result := &SubstructureResult{SubstructureEstimate: building.EstimateSubstructure()}
+	result.Normalise(denominator)
+	return result, nil
+}
+
+// AssignAssembly sets how an assembly is used on the building: its building
+// element, its geometric role or its quantity. The assembly is added to the
+// building if it is not used on it yet.
+func (bs *buildingService) AssignAssembly(buildingID uint, assemblyID uint, req AssignAssemblyRequest) (*model.Building, error) {
+	if err := validateAssignment(bs.assemblyRepo, assemblyID, req); err != nil {
+		return nil, err
+	}
+	building, err := bs.repo.FindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+
+	link := newAssemblyLink(building, assemblyID, req)
+	if err := bs.repo.SaveAssemblyLink(link); err != nil {
+		return nil, fmt.Errorf("failed to tag assembly %d on building %d: %w", assemblyID, buildingID, err)
+	}
+	if err := bs.repo.PinMaterials([]uint{buildingID}); err != nil {
+		return nil, fmt.Errorf("failed to pin material versions for building %d: %w", buildingID, err)
+	}
+	return bs.GetBuilding(buildingID)
+}
+
+// validateAssignment checks the element and role of an assembly assignment
+// and that the assembly exists.
+func validateAssignment(ar repository.AssemblyRepository, assemblyID uint, req AssignAssemblyRequest) error {
+	if req.Role != "" && !req.Role.IsValid() {
+		return fmt.Errorf("unknown geometric role '%s'", req.Role)
+	}
+	if element := assignedElement(req); !element.IsValid() {
+		return fmt.Errorf("unknown building element '%s'", element)
+	}
+	if _, err := ar.FindByID(assemblyID); err != nil {
+		return fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, err)
+	}
+	return nil
+}
+
+// assignedElement returns the element of an assembly assignment, which
+// defaults to the element of its geometric role.
+func assignedElement(req AssignAssemblyRequest) model.Element {
+	if req.Element == "" {
+		return req.Role.Element()
+	}
+	return req.Element
+}
+
+// newAssemblyLink links an assembly to the building. An assembly with a
+// geometric role takes its quantity from the building's geometry, otherwise
+// the requested quantity is used, 1 when it is left out.
+func newAssemblyLink(building *model.Building, assemblyID uint, req AssignAssemblyRequest) *model.BuildingAssembly {
+	link := &model.BuildingAssembly{
+		BuildingID: building.ID,
+		AssemblyID: assemblyID,
+		Element:    assignedElement(req),
+		Role:       req.Role,
+		Quantity:   req.quantity(),
+	}
+	if link.Role != "" {
+		link.Quantity = building.RoleQuantity(link.Role)
+	}
+	return link
 }
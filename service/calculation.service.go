@@ -4,6 +4,7 @@ package service
 
 import (
 	"carbon-service/model"
+	"fmt"
 	"sync"
 )
 
@@ -11,6 +12,9 @@ type CalculationService interface {
 	ComputeWholeLifeCarbonSync(entities ...model.CarbonCalculator) float64
 	ComputeTotalCarbonConcurrent(entities ...model.CarbonCalculator) float64
 	ComputeEmbodiedCarbonSync(entities ...model.EmbodiedCarbonCalculator) float64
+	ComputeWholeLifeCarbonByElementSync(entities ...model.ByElementCarbonCalculator) map[model.Element]float64
+	ComputeBuildingCarbon(building *model.Building, methodology *model.Methodology) (*CarbonResult, error)
+	ComputeEmbodiedEstimate(building *model.Building, methodology *model.Methodology) (*CarbonResult, error)
 }
 
 type calculationService struct{}
@@ -35,6 +39,16 @@ func (s *calculationService) ComputeEmbodiedCarbonSync(entities ...model.Embodie
 	return total
 }
 
+func (s *calculationService) ComputeWholeLifeCarbonByElementSync(entities ...model.ByElementCarbonCalculator) map[model.Element]float64 {
+	totals := make(map[model.Element]float64)
+	for _, entity := range entities {
+		for element, carbon := range entity.ComputeWholeLifeCarbonByElement() {
+			totals[element] += carbon
+		}
+	}
+	return totals
+}
+
 func (s *calculationService) ComputeTotalCarbonConcurrent(entities ...model.CarbonCalculator) float64 {
 	var wg sync.WaitGroup
 	totalCarbon := make(chan float64)
@@ -58,3 +72,95 @@ func (s *calculationService) ComputeTotalCarbonConcurrent(entities ...model.Carb
 	}
 	return total
 }
+
+// ComputeBuildingCarbon calculates the absolute carbon of the building under
+// the methodology: the modules its scope includes, with D if it adds it, and
+// the materials' values under its biogenic accounting and uplift. Material
+// versions have to be pinned first, see model.Building.PinMaterialVersions.
+func (s *calculationService) ComputeBuildingCarbon(building *model.Building, methodology *model.Methodology) (*CarbonResult, error) {
+	modules, err := methodology.Modules()
+	if err != nil {
+		return nil, err
+	}
+	building.UseMethodology(methodology)
+	result := newCarbonResult(building.CalculateCarbonForPhase(modules...), building.CalculateCarbonByElementForPhase(modules...))
+	if result.ByModule, err = newScopedModuleCarbon(building, modules); err != nil {
+		return nil, err
+	}
+	result.Methodology = methodology
+	return result, nil
+}
+
+// newScopedModuleCarbon calculates the carbon of each life cycle module in
+// reporting order from its individual modules that are in modules, leaving
+// out the life cycle modules none of which are, followed by D if it is in
+// modules. The life cycle modules add up to the carbon of modules.
+func newScopedModuleCarbon(entity model.ByIndicatorCarbonCalculator, modules []string) ([]ModuleCarbon, error) {
+	in := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		in[module] = true
+	}
+	byModule := []ModuleCarbon{}
+	for _, group := range model.Modules {
+		members, err := model.ModuleGroups[group].Modules()
+		if err != nil {
+			return nil, err
+		}
+		var scoped []string
+		for _, member := range members {
+			if in[member] {
+				scoped = append(scoped, member)
+			}
+		}
+		if len(scoped) > 0 {
+			byModule = append(byModule, ModuleCarbon{Module: group, Carbon: entity.CalculateCarbonForPhase(scoped...)})
+		}
+	}
+	if in["D"] {
+		byModule = append(byModule, ModuleCarbon{Module: "D", Carbon: entity.CalculateCarbonForPhase("D")})
+	}
+	return byModule, nil
+}
+
+// ComputeEmbodiedEstimate estimates the absolute embodied carbon of the
+// building's parametric geometry under the methodology. The estimate's rates
+// are upfront rates, so it counts in full when the methodology's scope
+// includes all of A1-A5, not at all when it includes none of them, and cannot
+// be reported when it includes only some, see ErrPartialScope. The rates hold
+// no biogenic carbon, so every biogenic accounting gives the same estimate.
+func (s *calculationService) ComputeEmbodiedEstimate(building *model.Building, methodology *model.Methodology) (*CarbonResult, error) {
+	modules, err := methodology.Modules()
+	if err != nil {
+		return nil, err
+	}
+	upfront, err := model.ScopeUpfront.Modules()
+	if err != nil {
+		return nil, err
+	}
+	included := 0
+	for _, module := range upfront {
+		for _, in := range modules {
+			if in == module {
+				included++
+				break
+			}
+		}
+	}
+	if included > 0 && included < len(upfront) {
+		return nil, fmt.Errorf("failed to estimate embodied carbon under methodology %s with scope %s: %w", methodology.ID, methodology.Scope, ErrPartialScope)
+	}
+
+	share := 0.0
+	if included == len(upfront) {
+		share = 1 + methodology.Uplift
+	}
+	total := s.ComputeEmbodiedCarbonSync(building) * share
+	byElement := building.CalculateEmbodiedCarbonByElement()
+	for element := range byElement {
+		byElement[element] *= share
+	}
+	result := newCarbonResult(total, byElement)
+	result.ByModule = newEmbodiedModuleCarbon(total)
+	result.Methodology = methodology
+	return result, nil
+}
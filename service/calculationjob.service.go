@@ -0,0 +1,597 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service/converter"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultJobWorkers is the number of calculation jobs run at the same time
+// when no other number is configured.
+const DefaultJobWorkers = 4
+
+// jobPollInterval is how often idle workers look for queued jobs they were
+// not woken up for.
+const jobPollInterval = 5 * time.Second
+
+// jobLease is how long a running job stays leased to the instance running it
+// without being renewed. Leases are renewed three times as often, and the
+// jobs of instances that stopped renewing them are queued again as often.
+const jobLease = time.Minute
+
+var (
+	// errJobStopped stops the running jobs when the service shuts down, they
+	// are queued again for the next start.
+	errJobStopped = errors.New("calculation service is shutting down")
+	// errJobLeaseLost stops a job whose lease expired, it is queued again and
+	// may already be run by someone else.
+	errJobLeaseLost = errors.New("calculation job lease was lost")
+)
+
+// CalculationJobService defines the operations available for running
+// calculations in the background and following their progress.
+type CalculationJobService interface {
+	SubmitJob(req CalculationJobRequest) (*model.CalculationJob, error)
+	GetJob(id uint) (*model.CalculationJob, error)
+	CancelJob(id uint) (*model.CalculationJob, error)
+	Calculate(ctx context.Context, req CalculationJobRequest, progress func(CalculationProgress), warn func(string)) (interface{}, error)
+	Start()
+	Stop()
+}
+
+// calculationJobService provides a concrete implementation of the
+// CalculationJobService, running jobs on a bounded pool of workers.
+type calculationJobService struct {
+	repo            repository.CalculationJobRepository
+	buildingService BuildingService // Building and portfolio calculations
+	scenarioService ScenarioService // Scenario comparisons
+	workers         int
+	owner           string // Identifies this instance of the service in the leases of its jobs
+
+	wake    chan struct{}                    // Signals idle workers that a job was submitted
+	stop    chan struct{}                    // Closed when the service shuts down
+	done    sync.WaitGroup                   // Waits for the workers to stop
+	mu      sync.Mutex                       // Guards claiming and cancelling jobs
+	running map[uint]context.CancelCauseFunc // Cancels the jobs running in this process
+}
+
+// NewCalculationJobService initializes a new calculation job service with
+// necessary dependencies. Jobs are only run once Start is called. The owner
+// identifies the instance of the service and must stay the same across its
+// restarts, so that it takes back the jobs it was running when it stopped.
+func NewCalculationJobService(r repository.CalculationJobRepository, bs BuildingService, ss ScenarioService, workers int, owner string) CalculationJobService {
+	if workers <= 0 {
+		workers = DefaultJobWorkers
+	}
+	return &calculationJobService{
+		repo:            r,
+		buildingService: bs,
+		scenarioService: ss,
+		workers:         workers,
+		owner:           owner,
+		wake:            make(chan struct{}, workers),
+		stop:            make(chan struct{}),
+		running:         make(map[uint]context.CancelCauseFunc),
+	}
+}
+
+// CalculationJobRequest describes the calculation of a job: the total carbon
+// of a building or of a portfolio of buildings, or the comparison of the
+// scenarios of a building, all of them when no ScenarioIDs are given. Each is
+// normalised, calculated by a methodology and presented in an output unit like
+// the building's total carbon.
+type CalculationJobRequest struct {
+	Kind          model.JobKind        `json:"kind" binding:"required"`
+	BuildingID    uint                 `json:"buildingId"`
+	BuildingIDs   []uint               `json:"buildingIds"`
+	ScenarioIDs   []uint               `json:"scenarioIds"`
+	Normalisation Normalisation        `json:"normalisation"`
+	Methodology   string               `json:"methodology"`
+	Unit          converter.OutputUnit `json:"unit"`
+}
+
+// CalculationProgress is how many of the entities of a calculation have been
+// processed, the absolute total in kgCO2e of those calculated so far and
+// warnings about the last one processed.
+type CalculationProgress struct {
+	Processed    int      `json:"processed"`
+	Total        int      `json:"total"`
+	PartialTotal float64  `json:"partialTotal"`
+	Warnings     []string `json:"warnings,omitempty"`
+}
+
+// Fraction returns the progress from 0 to 1.
+func (p CalculationProgress) Fraction() float64 {
+	if p.Total == 0 {
+		return 0
+	}
+	return float64(p.Processed) / float64(p.Total)
+}
+
+// PortfolioResult is the total carbon of a set of buildings, normalised by
+// the sum of their denominators, with the result of each building.
+type PortfolioResult struct {
+	CarbonResult
+	Buildings []PortfolioBuilding `json:"buildings"`
+}
+
+// PortfolioBuilding is the result of one building of a portfolio.
+type PortfolioBuilding struct {
+	BuildingID uint `json:"buildingId"`
+	*CarbonResult
+}
+
+// Validate checks that the request has what its kind of calculation needs, and
+// defaults its unit to kgCO2e, per m2 for results normalised by an area.
+func (req *CalculationJobRequest) Validate() error {
+	if !req.Kind.IsValid() {
+		return fmt.Errorf("unknown calculation kind '%s'", req.Kind)
+	}
+	switch {
+	case req.Kind == model.JobPortfolio && len(req.BuildingIDs) == 0:
+		return fmt.Errorf("a portfolio calculation needs buildingIds")
+	case req.Kind != model.JobPortfolio && req.BuildingID == 0:
+		return fmt.Errorf("a %s calculation needs a buildingId", req.Kind)
+	}
+	if !req.Normalisation.IsValid() {
+		return fmt.Errorf("unknown normalisation '%s'", req.Normalisation)
+	}
+	if req.Methodology != "" {
+		if _, err := FindMethodology(req.Methodology); err != nil {
+			return err
+		}
+	}
+	if req.Unit == "" {
+		req.Unit = OutputUnitFor(model.NewUnit(model.UnitSystemMetric), req.Normalisation)
+	}
+	scale, err := req.Unit.Scale()
+	if err != nil {
+		return err
+	}
+	if scale.PerArea() && !req.Normalisation.PerArea() {
+		return fmt.Errorf("output unit '%s' needs a result normalised by an area", req.Unit)
+	}
+	return nil
+}
+
+// SubmitJob queues a calculation and wakes up an idle worker to run it.
+func (js *calculationJobService) SubmitJob(req CalculationJobRequest) (*model.CalculationJob, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record calculation request: %w", err)
+	}
+	job := &model.CalculationJob{Kind: req.Kind, Status: model.JobQueued, Request: data}
+	if err := js.repo.Create(job); err != nil {
+		return nil, fmt.Errorf("failed to queue calculation job: %w", err)
+	}
+	select {
+	case js.wake <- struct{}{}:
+	default: // every worker is busy or already woken up
+	}
+	return job, nil
+}
+
+// GetJob fetches a calculation job with its status, progress and result.
+func (js *calculationJobService) GetJob(id uint) (*model.CalculationJob, error) {
+	job, err := js.repo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find calculation job with ID %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// CancelJob cancels a queued job straight away. A running job is stopped by
+// its worker at the next entity it calculates, and stays running until then.
+func (js *calculationJobService) CancelJob(id uint) (*model.CalculationJob, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, err := js.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status.IsFinished() {
+		return nil, fmt.Errorf("calculation job %d has already finished", id)
+	}
+	if cancel, ok := js.running[id]; ok {
+		cancel(nil)
+		return job, nil
+	}
+
+	cancelled, err := js.repo.UpdateStatus(id, model.JobQueued, model.JobCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel calculation job %d: %w", id, err)
+	}
+	if !cancelled {
+		return nil, fmt.Errorf("calculation job %d is not running in this process", id)
+	}
+	finished := time.Now()
+	job.Status = model.JobCancelled
+	job.FinishedAt = &finished
+	if err := js.repo.Update(job); err != nil {
+		return nil, fmt.Errorf("failed to cancel calculation job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// Start puts the jobs this instance was running when it last stopped, and
+// those of other instances that stopped renewing their lease, back in the
+// queue and starts the workers.
+func (js *calculationJobService) Start() {
+	if err := js.repo.RequeueRunning(js.owner); err != nil {
+		log.Printf("Failed to requeue interrupted calculation jobs: %v", err)
+	}
+	js.requeueExpired()
+	for i := 0; i < js.workers; i++ {
+		js.done.Add(1)
+		go js.work()
+	}
+	js.done.Add(1)
+	go js.reap()
+}
+
+// Stop stops the workers, putting the jobs they are running back in the queue
+// for the next start, and waits for them to return.
+func (js *calculationJobService) Stop() {
+	js.mu.Lock()
+	close(js.stop)
+	for _, cancel := range js.running {
+		cancel(errJobStopped)
+	}
+	js.mu.Unlock()
+	js.done.Wait()
+}
+
+// work runs queued jobs one after another, waiting for new ones when the
+// queue is empty, until the service stops.
+func (js *calculationJobService) work() {
+	defer js.done.Done()
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		job, ctx, err := js.claim()
+		if err == nil {
+			js.run(ctx, job)
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, errJobStopped) {
+			log.Printf("Failed to claim calculation job: %v", err)
+		}
+		select {
+		case <-js.stop:
+			return
+		case <-js.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap queues the jobs whose lease expired again until the service stops.
+func (js *calculationJobService) reap() {
+	defer js.done.Done()
+	ticker := time.NewTicker(jobLease)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-js.stop:
+			return
+		case <-ticker.C:
+			js.requeueExpired()
+		}
+	}
+}
+
+// requeueExpired puts the jobs whose lease expired back in the queue.
+func (js *calculationJobService) requeueExpired() {
+	if err := js.repo.RequeueExpired(); err != nil {
+		log.Printf("Failed to requeue calculation jobs with an expired lease: %v", err)
+	}
+}
+
+// claim marks the next queued job as running in this process, unless the
+// service is stopping.
+func (js *calculationJobService) claim() (*model.CalculationJob, context.Context, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	select {
+	case <-js.stop:
+		return nil, nil, errJobStopped
+	default:
+	}
+	job, err := js.repo.ClaimNext(js.owner, jobLease)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	js.running[job.ID] = cancel
+	return job, ctx, nil
+}
+
+// run calculates a claimed job, renewing its lease and saving its progress as
+// it goes and its result or error when it is done. A job whose calculation
+// was stopped by the service shutting down is queued again, one that lost its
+// lease is left to whoever runs it next.
+func (js *calculationJobService) run(ctx context.Context, job *model.CalculationJob) {
+	defer func() {
+		js.mu.Lock()
+		cancel := js.running[job.ID]
+		delete(js.running, job.ID)
+		js.mu.Unlock()
+		cancel(nil)
+	}()
+	go js.renewLease(ctx, job.ID)
+
+	started := time.Now()
+	job.StartedAt = &started
+	job.Progress = 0
+	result, err := js.calculateJob(ctx, job)
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	switch {
+	case errors.Is(context.Cause(ctx), errJobLeaseLost):
+		log.Printf("Calculation job %d lost its lease while running, leaving it to its next owner", job.ID)
+		return
+	case errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), errJobStopped):
+		job.Status = model.JobQueued
+		job.Progress = 0
+		job.StartedAt = nil
+		job.FinishedAt = nil
+		job.Owner = ""
+		job.LeaseExpiresAt = nil
+	case errors.Is(err, context.Canceled):
+		job.Status = model.JobCancelled
+	case err != nil:
+		job.Status = model.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = model.JobDone
+		job.Progress = 1
+		job.Result = result
+	}
+	if err := js.repo.Update(job); err != nil {
+		log.Printf("Failed to save calculation job %d: %v", job.ID, err)
+	}
+}
+
+// renewLease renews the lease of a running job until its calculation is
+// done, and stops the calculation if the lease was lost.
+func (js *calculationJobService) renewLease(ctx context.Context, id uint) {
+	ticker := time.NewTicker(jobLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		renewed, err := js.repo.RenewLease(id, js.owner, jobLease)
+		if err != nil {
+			log.Printf("Failed to renew the lease of calculation job %d: %v", id, err)
+			continue
+		}
+		if !renewed {
+			js.mu.Lock()
+			if cancel, ok := js.running[id]; ok {
+				cancel(errJobLeaseLost)
+			}
+			js.mu.Unlock()
+			return
+		}
+	}
+}
+
+// calculateJob runs the calculation of the job and returns its result, a
+// panic fails the job rather than the service.
+func (js *calculationJobService) calculateJob(ctx context.Context, job *model.CalculationJob) (data json.RawMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("calculation panicked: %v", r)
+		}
+	}()
+
+	var req CalculationJobRequest
+	if err := json.Unmarshal(job.Request, &req); err != nil {
+		return nil, fmt.Errorf("failed to read calculation request: %w", err)
+	}
+	result, err := js.calculate(ctx, req, func(progress CalculationProgress) {
+		job.Progress = progress.Fraction()
+		if err := js.repo.UpdateProgress(job.ID, job.Progress); err != nil {
+			log.Printf("Failed to save progress of calculation job %d: %v", job.ID, err)
+		}
+	}, func(warning string) {
+		log.Printf("Calculation job %d: %s", job.ID, warning)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+// Calculate runs the calculation of the request in the foreground, reporting
+// its progress and warning about the result as it goes. It stops before the
+// next entity once ctx is cancelled.
+func (js *calculationJobService) Calculate(ctx context.Context, req CalculationJobRequest, progress func(CalculationProgress), warn func(string)) (interface{}, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return js.calculate(ctx, req, progress, warn)
+}
+
+// calculate runs the calculation of the request and warns about results that
+// mix methodologies. A portfolio reports its progress after every building
+// and stops before the next one once ctx is cancelled. A building or scenario
+// calculation is not incremental: it is one entity, reported once calculated,
+// which cannot be stopped halfway. Breakdowns are left out, they can be
+// fetched from the calculation runs.
+func (js *calculationJobService) calculate(ctx context.Context, req CalculationJobRequest, progress func(CalculationProgress), warn func(string)) (interface{}, error) {
+	opts := CalculationOptions{Normalisation: req.Normalisation, Methodology: req.Methodology}
+	switch req.Kind {
+	case model.JobBuilding:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err := js.buildingService.ComputeTotalCarbon(req.BuildingID, opts)
+		if err != nil {
+			return nil, err
+		}
+		progress(CalculationProgress{
+			Processed:    1,
+			Total:        1,
+			PartialTotal: result.absoluteTotal(),
+			Warnings:     resultWarnings(req.BuildingID, result),
+		})
+		result.Breakdown = nil
+		return result, result.Present(req.Unit)
+
+	case model.JobPortfolio:
+		buildings := make([]PortfolioBuilding, 0, len(req.BuildingIDs))
+		var partialTotal float64
+		for i, buildingID := range req.BuildingIDs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			result, err := js.buildingService.ComputeTotalCarbon(buildingID, opts)
+			if err != nil {
+				return nil, err
+			}
+			result.Breakdown = nil
+			buildings = append(buildings, PortfolioBuilding{BuildingID: buildingID, CarbonResult: result})
+			partialTotal += result.absoluteTotal()
+			progress(CalculationProgress{
+				Processed:    i + 1,
+				Total:        len(req.BuildingIDs),
+				PartialTotal: partialTotal,
+				Warnings:     resultWarnings(buildingID, result),
+			})
+		}
+		portfolio := newPortfolioResult(buildings)
+		if mixesMethodologies(buildings) {
+			warn("the buildings are calculated by different methodologies, so the portfolio total mixes them")
+		}
+		for _, building := range portfolio.Buildings {
+			if err := building.Present(req.Unit); err != nil {
+				return nil, err
+			}
+		}
+		return portfolio, portfolio.Present(req.Unit)
+
+	case model.JobScenario:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		comparison, err := js.scenarioService.CompareScenarios(req.BuildingID, req.ScenarioIDs, req.Normalisation, req.Methodology)
+		if err != nil {
+			return nil, err
+		}
+		progress(CalculationProgress{Processed: 1, Total: 1, PartialTotal: comparison.Baseline.Total * comparison.Baseline.Normalisation.Value})
+		return comparison, comparison.Present(req.Unit)
+	}
+	return nil, fmt.Errorf("unknown calculation kind '%s'", req.Kind)
+}
+
+// absoluteTotal returns the total of a normalised result in kgCO2e.
+func (r *CarbonResult) absoluteTotal() float64 {
+	if r.Normalisation == nil {
+		return r.Total
+	}
+	return r.Total * r.Normalisation.Value
+}
+
+// resultWarnings points out results of a building that are likely incomplete.
+func resultWarnings(buildingID uint, result *CarbonResult) []string {
+	var warnings []string
+	if result.Total == 0 {
+		warnings = append(warnings, fmt.Sprintf("building %d has no carbon, check that it has assemblies and that their materials have EPD data", buildingID))
+	}
+	for _, element := range result.ByElement {
+		if element.Element == model.ElementUnclassified && element.Carbon != 0 {
+			warnings = append(warnings, fmt.Sprintf("building %d has carbon in assemblies that are not assigned to a building element", buildingID))
+		}
+	}
+	return warnings
+}
+
+// newPortfolioResult adds up the normalised results of the buildings: their
+// absolute results are summed and divided by the sum of their denominators.
+// The portfolio has a methodology only if all of its buildings share it.
+func newPortfolioResult(buildings []PortfolioBuilding) *PortfolioResult {
+	var total float64
+	denominator := &Denominator{Normalisation: NormaliseAbsolute, Value: 1}
+	if len(buildings) > 0 && buildings[0].Normalisation != nil && buildings[0].Normalisation.Normalisation != NormaliseAbsolute {
+		first := buildings[0].Normalisation
+		denominator = &Denominator{Normalisation: first.Normalisation, Unit: first.Unit}
+	}
+	byElement := make(map[model.Element]float64)
+	byModule := []ModuleCarbon{}
+	moduleIndex := make(map[string]int)
+	var methodology *model.Methodology
+	for i, building := range buildings {
+		value := 1.0
+		if building.Normalisation != nil {
+			value = building.Normalisation.Value
+		}
+		if denominator.Normalisation != NormaliseAbsolute {
+			denominator.Value += value
+		}
+
+		total += building.Total * value
+		for _, element := range building.ByElement {
+			byElement[element.Element] += element.Carbon * value
+		}
+		for _, module := range building.ByModule {
+			index, ok := moduleIndex[module.Module]
+			if !ok {
+				index = len(byModule)
+				moduleIndex[module.Module] = index
+				byModule = append(byModule, ModuleCarbon{Module: module.Module})
+			}
+			byModule[index].Carbon += module.Carbon * value
+		}
+
+		if i == 0 {
+			methodology = building.Methodology
+		} else if !sameMethodology(building.Methodology, methodology) {
+			methodology = nil
+		}
+	}
+	portfolio := &PortfolioResult{CarbonResult: *newCarbonResult(total, byElement), Buildings: buildings}
+	portfolio.ByModule = byModule
+	portfolio.Methodology = methodology
+	portfolio.CalculatedAt = time.Now()
+	portfolio.Normalise(denominator)
+	return portfolio
+}
+
+// mixesMethodologies reports whether the buildings are not all calculated by
+// the same methodology, counting no methodology as one.
+func mixesMethodologies(buildings []PortfolioBuilding) bool {
+	for _, building := range buildings {
+		if !sameMethodology(building.Methodology, buildings[0].Methodology) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameMethodology reports whether two results were calculated by the same
+// methodology, or both by none.
+func sameMethodology(a, b *model.Methodology) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"fmt"
+	"time"
+)
+
+// CalculationRunService defines the operations available for looking back at
+// the calculation runs of buildings and comparing them over time.
+type CalculationRunService interface {
+	GetRuns(buildingID uint) ([]RunSummary, error)
+	GetRun(buildingID uint, runID uint) (*CarbonResult, error)
+	DiffRuns(buildingID uint, fromID uint, toID uint) (*RunDiff, error)
+}
+
+// calculationRunService provides a concrete implementation of the CalculationRunService.
+type calculationRunService struct {
+	repo repository.CalculationRunRepository
+}
+
+// NewCalculationRunService initializes a new calculation run service with necessary dependencies.
+func NewCalculationRunService(r repository.CalculationRunRepository) CalculationRunService {
+	return &calculationRunService{
+		repo: r,
+	}
+}
+
+// RunSummary is the absolute result of a building in one calculation run.
+type RunSummary struct {
+	RunID              uint            `json:"runId"`
+	CalculatedAt       time.Time       `json:"calculatedAt"`
+	InputsHash         string          `json:"inputsHash"`
+	Methodology        string          `json:"methodology"`
+	MethodologyVersion string          `json:"methodologyVersion"`
+	TotalCarbon        float64         `json:"totalCarbon"`
+	ByElement          []ElementCarbon `json:"byElement"`
+	ByModule           []ModuleCarbon  `json:"byModule"`
+}
+
+// RunDiff is what changed between two calculation runs of a building. Deltas
+// are positive when the later run emits more.
+type RunDiff struct {
+	From          RunSummary      `json:"from"`
+	To            RunSummary      `json:"to"`
+	InputsChanged bool            `json:"inputsChanged"`
+	Total         float64         `json:"total"`
+	ByElement     []ElementCarbon `json:"byElement"`
+	ByModule      []ModuleCarbon  `json:"byModule"`
+}
+
+// GetRuns returns the results of the building's calculation runs in the order
+// they were calculated.
+func (rs *calculationRunService) GetRuns(buildingID uint) ([]RunSummary, error) {
+	runs, err := rs.repo.FindByBuildingID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find calculation runs of building %d: %w", buildingID, err)
+	}
+	summaries := make([]RunSummary, len(runs))
+	for i := range runs {
+		summary, err := newRunSummary(&runs[i])
+		if err != nil {
+			return nil, err
+		}
+		summaries[i] = *summary
+	}
+	return summaries, nil
+}
+
+// GetRun fetches the absolute result of a calculation run of the building with its breakdown.
+func (rs *calculationRunService) GetRun(buildingID uint, runID uint) (*CarbonResult, error) {
+	run, err := rs.findRun(buildingID, runID)
+	if err != nil {
+		return nil, err
+	}
+	return newRunResult(run)
+}
+
+// DiffRuns compares the results of two calculation runs of the building.
+func (rs *calculationRunService) DiffRuns(buildingID uint, fromID uint, toID uint) (*RunDiff, error) {
+	from, err := rs.findRun(buildingID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := rs.findRun(buildingID, toID)
+	if err != nil {
+		return nil, err
+	}
+	fromSummary, err := newRunSummary(from)
+	if err != nil {
+		return nil, err
+	}
+	toSummary, err := newRunSummary(to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunDiff{
+		From:          *fromSummary,
+		To:            *toSummary,
+		InputsChanged: from.InputsHash != to.InputsHash,
+		Total:         to.TotalCarbon - from.TotalCarbon,
+		ByElement:     elementDeltas(fromSummary.ByElement, toSummary.ByElement),
+		ByModule:      moduleDeltas(fromSummary.ByModule, toSummary.ByModule),
+	}, nil
+}
+
+// findRun fetches a calculation run, making sure it is of the building.
+func (rs *calculationRunService) findRun(buildingID uint, runID uint) (*model.CalculationRun, error) {
+	run, err := rs.repo.FindByID(runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find calculation run with ID %d: %w", runID, err)
+	}
+	if run.BuildingID != buildingID {
+		return nil, fmt.Errorf("calculation run %d is not of building %d", runID, buildingID)
+	}
+	return run, nil
+}
+
+// newRunSummary summarises the result of a calculation run.
+func newRunSummary(run *model.CalculationRun) (*RunSummary, error) {
+	result, err := newRunResult(run)
+	if err != nil {
+		return nil, err
+	}
+	return &RunSummary{
+		RunID:              run.ID,
+		CalculatedAt:       run.CalculatedAt,
+		InputsHash:         run.InputsHash,
+		Methodology:        run.Methodology,
+		MethodologyVersion: run.MethodologyVersion,
+		TotalCarbon:        run.TotalCarbon,
+		ByElement:          result.ByElement,
+		ByModule:           result.ByModule,
+	}, nil
+}
+
+// moduleDeltas subtracts the per module results of from from those of to,
+// for every module present in either, in the order of to then from.
+func moduleDeltas(from []ModuleCarbon, to []ModuleCarbon) []ModuleCarbon {
+	fromByModule := make(map[string]float64, len(from))
+	for _, module := range from {
+		fromByModule[module.Module] = module.Carbon
+	}
+	deltas := make([]ModuleCarbon, 0, len(to))
+	seen := make(map[string]bool, len(to))
+	for _, module := range to {
+		deltas = append(deltas, ModuleCarbon{Module: module.Module, Carbon: module.Carbon - fromByModule[module.Module]})
+		seen[module.Module] = true
+	}
+	for _, module := range from {
+		if !seen[module.Module] {
+			deltas = append(deltas, ModuleCarbon{Module: module.Module, Carbon: -module.Carbon})
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OutputUnit is a unit carbon results are presented in: a carbon unit,
+// optionally per area, e.g. "tCO2e" or "lbCO2e/ft2". Results are calculated
+// in kgCO2e, and kgCO2e/m2 when normalised by an area.
+type OutputUnit string
+
+const (
+	UnitKgCO2e       OutputUnit = "kgCO2e"
+	UnitTCO2e        OutputUnit = "tCO2e"
+	UnitLbCO2e       OutputUnit = "lbCO2e"
+	UnitKgCO2ePerM2  OutputUnit = "kgCO2e/m2"
+	UnitTCO2ePerM2   OutputUnit = "tCO2e/m2"
+	UnitKgCO2ePerFt2 OutputUnit = "kgCO2e/ft2"
+	UnitLbCO2ePerFt2 OutputUnit = "lbCO2e/ft2"
+)
+
+// OutputScale is how a result in kgCO2e, or kgCO2e/m2, is scaled into an output unit.
+type OutputScale struct {
+	Carbon     string  // carbon unit, e.g. "tCO2e"
+	Area       string  // area unit, empty unless the output unit is per area
+	CarbonRate float64 // factor from kgCO2e
+	AreaRate   float64 // factor from m2, 1 unless the output unit is per area
+}
+
+// PerArea reports whether the output unit is per area.
+func (s OutputScale) PerArea() bool {
+	return s.Area != ""
+}
+
+// Scale parses the output unit. An empty unit means kgCO2e.
+func (u OutputUnit) Scale() (OutputScale, error) {
+	if u == "" {
+		u = UnitKgCO2e
+	}
+	carbon, area, perArea := strings.Cut(string(u), "/")
+	carbonUnit, err := Parse(carbon)
+	if err != nil || carbonUnit.Dimension != DimensionOfCarbon {
+		return OutputScale{}, fmt.Errorf("unknown output unit '%s'", u)
+	}
+	scale := OutputScale{Carbon: carbon, CarbonRate: 1 / carbonUnit.Factor, AreaRate: 1}
+	if perArea {
+		areaUnit, err := Parse(area)
+		if err != nil || areaUnit.Dimension != DimensionOfArea {
+			return OutputScale{}, fmt.Errorf("unknown output unit '%s'", u)
+		}
+		scale.Area = area
+		scale.AreaRate = 1 / areaUnit.Factor
+	}
+	return scale, nil
+}
+
+// Convert scales a carbon value in kgCO2e, or kgCO2e/m2 when perArea, into the
+// output unit. Values per m2 take the area unit, which defaults to m2.
+func (s OutputScale) Convert(value float64, perArea bool) float64 {
+	value *= s.CarbonRate
+	if perArea {
+		value /= s.AreaRate
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package converter
+
+import "fmt"
+
+// Dimensions a material quantity can be expressed in.
+const (
+	DimensionMass   = "mass"
+	DimensionVolume = "volume"
+	DimensionArea   = "area"
+	DimensionPiece  = "piece"
+	DimensionEnergy = "energy"
+)
+
+// quantityDimensions maps the dimensions of the registry to the dimensions a
+// material quantity can be expressed in.
+var quantityDimensions = map[Dimension]string{
+	DimensionOfMass:   DimensionMass,
+	DimensionOfVolume: DimensionVolume,
+	DimensionOfArea:   DimensionArea,
+	DimensionOfCount:  DimensionPiece,
+	DimensionOfEnergy: DimensionEnergy,
+}
+
+// quantityUnit is a unit a material quantity can be expressed in,
+// with its factor to the base unit of its dimension (kg, m3, m2, piece, kWh).
+type quantityUnit struct {
+	dimension string
+	toBase    float64
+}
+
+// parseQuantityUnit parses a unit of the registry a material quantity can be expressed in.
+func parseQuantityUnit(symbol string) (quantityUnit, error) {
+	unit, err := Parse(symbol)
+	if err != nil {
+		return quantityUnit{}, err
+	}
+	dimension, ok := quantityDimensions[unit.Dimension]
+	if !ok {
+		return quantityUnit{}, fmt.Errorf("unit '%s' is not a mass, volume, area, piece count or energy", symbol)
+	}
+	return quantityUnit{dimension: dimension, toBase: unit.Factor}, nil
+}
+
+// MaterialProperties are the material specific properties needed to convert
+// a quantity from one dimension into another, e.g. from a volume to a mass.
+// Properties that are not known are left at zero.
+type MaterialProperties struct {
+	Density      float64 // kg/m3
+	Thickness    float64 // m
+	MassPerArea  float64 // kg/m2
+	MassPerPiece float64 // kg
+}
+
+// QuantityDimension returns the dimension of a quantity unit.
+func QuantityDimension(unit string) (string, error) {
+	u, err := parseQuantityUnit(unit)
+	if err != nil {
+		return "", err
+	}
+	return u.dimension, nil
+}
+
+// ConvertQuantity converts a quantity of a material from one unit into another.
+// Conversions within a dimension only need the unit factors, conversions
+// between dimensions go through the material's properties and fail if the
+// property they need is not known. Energy has no mass, so materials declared
+// per unit of energy only convert from other units of energy.
+func ConvertQuantity(value float64, from, to string, props MaterialProperties) (float64, error) {
+	fromUnit, err := parseQuantityUnit(from)
+	if err != nil {
+		return 0, err
+	}
+	toUnit, err := parseQuantityUnit(to)
+	if err != nil {
+		return 0, err
+	}
+
+	base := value * fromUnit.toBase
+	if fromUnit.dimension == toUnit.dimension {
+		return base / toUnit.toBase, nil
+	}
+
+	if fromUnit.dimension == DimensionEnergy || toUnit.dimension == DimensionEnergy {
+		return 0, fmt.Errorf("cannot convert %s to %s: energy has no mass", from, to)
+	}
+
+	// area and volume can be converted directly through the thickness
+	if fromUnit.dimension == DimensionArea && toUnit.dimension == DimensionVolume && props.Thickness > 0 {
+		return base * props.Thickness / toUnit.toBase, nil
+	}
+	if fromUnit.dimension == DimensionVolume && toUnit.dimension == DimensionArea && props.Thickness > 0 {
+		return base / props.Thickness / toUnit.toBase, nil
+	}
+
+	// everything else goes through the mass
+	fromMass := massFactor(fromUnit.dimension, props)
+	if fromMass == 0 {
+		return 0, fmt.Errorf("cannot convert %s to %s: missing %s", from, to, missingProperty(fromUnit.dimension))
+	}
+	toMass := massFactor(toUnit.dimension, props)
+	if toMass == 0 {
+		return 0, fmt.Errorf("cannot convert %s to %s: missing %s", from, to, missingProperty(toUnit.dimension))
+	}
+	return base * fromMass / toMass / toUnit.toBase, nil
+}
+
+// massFactor returns the mass in kg of one base unit of the dimension,
+// or zero if the material's properties do not define it.
+func massFactor(dimension string, props MaterialProperties) float64 {
+	switch dimension {
+	case DimensionMass:
+		return 1
+	case DimensionVolume:
+		return props.Density
+	case DimensionArea:
+		if props.MassPerArea > 0 {
+			return props.MassPerArea
+		}
+		return props.Thickness * props.Density
+	case DimensionPiece:
+		return props.MassPerPiece
+	default:
+		return 0
+	}
+}
+
+// missingProperty names the property needed to convert the dimension to a mass.
+func missingProperty(dimension string) string {
+	switch dimension {
+	case DimensionVolume:
+		return "density"
+	case DimensionArea:
+		return "mass per area or thickness and density"
+	case DimensionPiece:
+		return "mass per piece"
+	default:
+		return dimension
+	}
+}
@@ -0,0 +1,215 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// base dimensions units are made of. Energy and carbon are kept as base
+// dimensions of their own rather than derived from mass, length and time, as
+// results are reported in kWh and kgCO2e, never in joules per second.
+const (
+	baseMass = iota
+	baseLength
+	baseTime
+	baseEnergy
+	baseCarbon
+	baseCount
+	numBases
+)
+
+var baseNames = [numBases]string{"mass", "length", "time", "energy", "carbon", "count"}
+
+// Dimension is the exponent of each base dimension in a unit, e.g. length^2
+// for areas and mass·length^-3 for densities.
+type Dimension [numBases]int
+
+// Dimensions of the units used across the service.
+var (
+	DimensionNone            = Dimension{}
+	DimensionOfMass          = Dimension{baseMass: 1}
+	DimensionOfLength        = Dimension{baseLength: 1}
+	DimensionOfArea          = Dimension{baseLength: 2}
+	DimensionOfVolume        = Dimension{baseLength: 3}
+	DimensionOfTime          = Dimension{baseTime: 1}
+	DimensionOfEnergy        = Dimension{baseEnergy: 1}
+	DimensionOfCarbon        = Dimension{baseCarbon: 1}
+	DimensionOfCount         = Dimension{baseCount: 1}
+	DimensionOfDensity       = Dimension{baseMass: 1, baseLength: -3}
+	DimensionOfCarbonPerArea = Dimension{baseCarbon: 1, baseLength: -2}
+)
+
+// String describes the dimension, e.g. "mass·length^-3".
+func (d Dimension) String() string {
+	var parts []string
+	for i, exponent := range d {
+		switch exponent {
+		case 0:
+		case 1:
+			parts = append(parts, baseNames[i])
+		default:
+			parts = append(parts, fmt.Sprintf("%s^%d", baseNames[i], exponent))
+		}
+	}
+	if len(parts) == 0 {
+		return "dimensionless"
+	}
+	return strings.Join(parts, "·")
+}
+
+func (d Dimension) add(other Dimension, exponent int) Dimension {
+	for i := range d {
+		d[i] += other[i] * exponent
+	}
+	return d
+}
+
+// Unit is a parsed unit: its symbol, dimension and the factor that converts a
+// value in the unit into the base units of its dimension (kg, m, yr, kWh,
+// kgCO2e and pieces).
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+	Factor    float64
+}
+
+// Compatible reports whether values in u can be converted into other.
+func (u Unit) Compatible(other Unit) bool {
+	return u.Dimension == other.Dimension
+}
+
+// Registry holds the units that can be parsed, alone or combined into
+// compound units such as "lb/ft3" or "kBtu/ft2/yr".
+type Registry struct {
+	units map[string]Unit
+}
+
+// NewRegistry creates an empty unit registry.
+func NewRegistry() *Registry {
+	return &Registry{units: map[string]Unit{}}
+}
+
+// Register adds a unit with the factor to the base units of its dimension.
+func (r *Registry) Register(symbol string, dimension Dimension, factor float64) {
+	r.units[symbol] = Unit{Symbol: symbol, Dimension: dimension, Factor: factor}
+}
+
+// Units is the registry of the units used across the service.
+var Units = newUnits()
+
+func newUnits() *Registry {
+	r := NewRegistry()
+
+	r.Register("kg", DimensionOfMass, 1)
+	r.Register("g", DimensionOfMass, 1e-3)
+	r.Register("t", DimensionOfMass, 1e3)
+	r.Register("lb", DimensionOfMass, 0.45359237)
+	r.Register("ton", DimensionOfMass, 907.18474) // US short ton
+
+	r.Register("m", DimensionOfLength, 1)
+	r.Register("mm", DimensionOfLength, 1e-3)
+	r.Register("cm", DimensionOfLength, 1e-2)
+	r.Register("km", DimensionOfLength, 1e3)
+	r.Register("in", DimensionOfLength, 0.0254)
+	r.Register("ft", DimensionOfLength, 0.3048)
+	r.Register("yd", DimensionOfLength, 0.9144)
+
+	r.Register("yr", DimensionOfTime, 1)
+
+	r.Register("kWh", DimensionOfEnergy, 1)
+	r.Register("Wh", DimensionOfEnergy, 1e-3)
+	r.Register("MWh", DimensionOfEnergy, 1e3)
+	r.Register("MJ", DimensionOfEnergy, 1/3.6)
+	r.Register("GJ", DimensionOfEnergy, 1e3/3.6)
+	r.Register("Btu", DimensionOfEnergy, 2.9307107e-4)
+	r.Register("kBtu", DimensionOfEnergy, 2.9307107e-1)
+	r.Register("MMBtu", DimensionOfEnergy, 2.9307107e2)
+	r.Register("therm", DimensionOfEnergy, 2.9307107e1)
+
+	r.Register("kgCO2e", DimensionOfCarbon, 1)
+	r.Register("gCO2e", DimensionOfCarbon, 1e-3)
+	r.Register("tCO2e", DimensionOfCarbon, 1e3)
+	r.Register("lbCO2e", DimensionOfCarbon, 0.45359237)
+
+	r.Register("piece", DimensionOfCount, 1)
+	return r
+}
+
+// Parse parses a unit string into its dimension and factor. Units are
+// combined with "*" and divided with "/", each "/" dividing by the unit
+// directly after it, and raised to a power with a trailing exponent, with or
+// without "^": "m2", "m^2" and "m^-1".
+func (r *Registry) Parse(symbol string) (Unit, error) {
+	unit := Unit{Symbol: symbol, Factor: 1}
+	sign, start := 1, 0
+	for i := 0; i <= len(symbol); i++ {
+		if i < len(symbol) && symbol[i] != '*' && symbol[i] != '/' {
+			continue
+		}
+		base, exponent, err := r.parseTerm(strings.TrimSpace(symbol[start:i]))
+		if err != nil {
+			return Unit{}, fmt.Errorf("unknown unit '%s': %w", symbol, err)
+		}
+		exponent *= sign
+		unit.Dimension = unit.Dimension.add(base.Dimension, exponent)
+		unit.Factor *= math.Pow(base.Factor, float64(exponent))
+
+		if i < len(symbol) && symbol[i] == '/' {
+			sign = -1
+		} else {
+			sign = 1
+		}
+		start = i + 1
+	}
+	return unit, nil
+}
+
+// parseTerm parses a registered unit raised to an optional exponent.
+func (r *Registry) parseTerm(term string) (Unit, int, error) {
+	if unit, ok := r.units[term]; ok {
+		return unit, 1, nil
+	}
+	symbol, power, found := strings.Cut(term, "^")
+	if !found {
+		symbol = strings.TrimRight(term, "0123456789")
+		power = term[len(symbol):]
+	}
+	unit, ok := r.units[symbol]
+	if !ok || power == "" {
+		return Unit{}, 0, fmt.Errorf("'%s' is not a registered unit", term)
+	}
+	exponent, err := strconv.Atoi(power)
+	if err != nil {
+		return Unit{}, 0, fmt.Errorf("invalid exponent in '%s'", term)
+	}
+	return unit, exponent, nil
+}
+
+// Convert converts a value from one unit into another, failing if either
+// unit is unknown or they are of different dimensions.
+func (r *Registry) Convert(value float64, from, to string) (float64, error) {
+	fromUnit, err := r.Parse(from)
+	if err != nil {
+		return 0, err
+	}
+	toUnit, err := r.Parse(to)
+	if err != nil {
+		return 0, err
+	}
+	if !fromUnit.Compatible(toUnit) {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, fromUnit.Dimension, to, toUnit.Dimension)
+	}
+	return value * fromUnit.Factor / toUnit.Factor, nil
+}
+
+// Convert converts a value between units of the service's registry.
+func Convert(value float64, from, to string) (float64, error) {
+	return Units.Convert(value, from, to)
+}
+
+// Parse parses a unit of the service's registry.
+func Parse(symbol string) (Unit, error) {
+	return Units.Parse(symbol)
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"fmt"
+)
+
+// FloorService defines the operations available for managing the floors and
+// zones of buildings, the assemblies used on them and their carbon results.
+type FloorService interface {
+	AddFloor(buildingID uint, req FloorRequest) (*model.Floor, error)
+	GetFloors(buildingID uint) ([]model.Floor, error)
+	UpdateFloor(buildingID uint, floorID uint, req FloorRequest) (*model.Floor, error)
+	RemoveFloor(buildingID uint, floorID uint) error
+	AddZone(buildingID uint, floorID uint, req ZoneRequest) (*model.Zone, error)
+	RemoveZone(buildingID uint, floorID uint, zoneID uint) error
+	AssignAssembly(buildingID uint, floorID uint, req FloorAssemblyRequest) (*model.FloorAssembly, error)
+	RemoveAssembly(buildingID uint, floorID uint, linkID uint) error
+	ComputeCarbonByFloor(buildingID uint, normalisation Normalisation, methodology string) (*FloorCarbonResult, error)
+	ComputeCarbonByUse(buildingID uint, normalisation Normalisation, methodology string) (*UseCarbonResult, error)
+}
+
+// floorService provides a concrete implementation of the FloorService.
+type floorService struct {
+	repo              repository.FloorRepository
+	buildingRepo      repository.BuildingRepository // Buildings the floors belong to
+	assemblyRepo      repository.AssemblyRepository // Assemblies used on floors
+	carbonCalcService CalculationService            // Dependency for carbon calculations
+}
+
+// NewFloorService initializes a new floor service with necessary dependencies.
+func NewFloorService(r repository.FloorRepository, br repository.BuildingRepository, ar repository.AssemblyRepository, cs CalculationService) FloorService {
+	return &floorService{
+		repo:              r,
+		buildingRepo:      br,
+		assemblyRepo:      ar,
+		carbonCalcService: cs,
+	}
+}
+
+// FloorRequest describes a floor of a building. Level 0 is the ground floor,
+// basement levels are negative.
+type FloorRequest struct {
+	Name   string         `json:"name" binding:"required"`
+	Level  int            `json:"level"`
+	Area   float64        `json:"area" binding:"required,gt=0"`
+	Height float64        `json:"height" binding:"required,gt=0"`
+	Use    model.UseClass `json:"use" binding:"required"`
+}
+
+type ZoneRequest struct {
+	Name string         `json:"name" binding:"required"`
+	Area float64        `json:"area" binding:"required,gt=0"`
+	Use  model.UseClass `json:"use" binding:"required"`
+}
+
+// FloorAssemblyRequest assigns an assembly to a floor, or to one of its zones
+// when ZoneID is set. Quantity defaults to 1 when it is left out.
+type FloorAssemblyRequest struct {
+	AssemblyID uint          `json:"assemblyId" binding:"required"`
+	ZoneID     *uint         `json:"zoneId"`
+	Element    model.Element `json:"element"`
+	Quantity   *float64      `json:"quantity" binding:"omitempty,gte=0"`
+}
+
+// FloorCarbonResult is the carbon of a building per floor. Shared is the
+// carbon of the assemblies used on the building as a whole.
+type FloorCarbonResult struct {
+	Total         float64            `json:"total"`
+	Shared        float64            `json:"shared"`
+	Floors        []FloorCarbon      `json:"floors"`
+	Normalisation *Denominator       `json:"normalisation"`
+	Unit          string             `json:"unit"`
+	Methodology   *model.Methodology `json:"methodology"`
+}
+
+// FloorCarbon is the carbon of one floor and its intensity per m2 of floor area.
+type FloorCarbon struct {
+	FloorID       uint            `json:"floorId"`
+	Name          string          `json:"name"`
+	Level         int             `json:"level"`
+	Use           model.UseClass  `json:"use"`
+	Area          float64         `json:"area"`
+	Carbon        float64         `json:"carbon"`
+	CarbonPerArea float64         `json:"carbonPerArea"`
+	ByElement     []ElementCarbon `json:"byElement"`
+}
+
+// UseCarbonResult is the carbon of a building per use class. Shared is the
+// carbon of the assemblies used on the building as a whole.
+type UseCarbonResult struct {
+	Total         float64            `json:"total"`
+	Shared        float64            `json:"shared"`
+	ByUse         []UseCarbon        `json:"byUse"`
+	Normalisation *Denominator       `json:"normalisation"`
+	Unit          string             `json:"unit"`
+	Methodology   *model.Methodology `json:"methodology"`
+}
+
+// UseCarbon is the carbon of one use class and its intensity per m2 of the
+// floor area with that use.
+type UseCarbon struct {
+	Use           model.UseClass `json:"use"`
+	Area          float64        `json:"area"`
+	Carbon        float64        `json:"carbon"`
+	CarbonPerArea float64        `json:"carbonPerArea"`
+}
+
+// AddFloor adds a floor to the building.
+func (fs *floorService) AddFloor(buildingID uint, req FloorRequest) (*model.Floor, error) {
+	if !req.Use.IsValid() {
+		return nil, fmt.Errorf("unknown use class '%s'", req.Use)
+	}
+	if _, err := fs.buildingRepo.FindByID(buildingID); err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+
+	floor := &model.Floor{
+		BuildingID: buildingID,
+		Name:       req.Name,
+		Level:      req.Level,
+		Area:       req.Area,
+		Height:     req.Height,
+		Use:        req.Use,
+	}
+	if err := fs.repo.Save(floor); err != nil {
+		return nil, fmt.Errorf("failed to add floor to building %d: %w", buildingID, err)
+	}
+	return floor, nil
+}
+
+// GetFloors fetches the floors of a building from the lowest level up.
+func (fs *floorService) GetFloors(buildingID uint) ([]model.Floor, error) {
+	floors, err := fs.repo.FindByBuildingID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find floors of building %d: %w", buildingID, err)
+	}
+	return floors, nil
+}
+
+// UpdateFloor replaces the name, level, area, height and use of a floor.
+// The floor cannot shrink below the area of its zones.
+func (fs *floorService) UpdateFloor(buildingID uint, floorID uint, req FloorRequest) (*model.Floor, error) {
+	if !req.Use.IsValid() {
+		return nil, fmt.Errorf("unknown use class '%s'", req.Use)
+	}
+	floor, err := fs.findFloor(buildingID, floorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if zonedArea := floor.ZonedArea(); zonedArea > req.Area {
+		return nil, fmt.Errorf("zones of floor %d cover %.2f m2, more than its new %.2f m2", floorID, zonedArea, req.Area)
+	}
+
+	floor.Name = req.Name
+	floor.Level = req.Level
+	floor.Area = req.Area
+	floor.Height = req.Height
+	floor.Use = req.Use
+	if err := fs.repo.Save(floor); err != nil {
+		return nil, fmt.Errorf("failed to update floor %d: %w", floorID, err)
+	}
+	return floor, nil
+}
+
+// RemoveFloor removes a floor with its zones and assemblies from the building.
+func (fs *floorService) RemoveFloor(buildingID uint, floorID uint) error {
+	floor, err := fs.findFloor(buildingID, floorID)
+	if err != nil {
+		return err
+	}
+	if err := fs.repo.Delete(floor); err != nil {
+		return fmt.Errorf("failed to remove floor %d: %w", floorID, err)
+	}
+	return nil
+}
+
+// AddZone adds a zone to a floor. The zones of a floor cannot be larger than the floor.
+func (fs *floorService) AddZone(buildingID uint, floorID uint, req ZoneRequest) (*model.Zone, error) {
+	if !req.Use.IsValid() {
+		return nil, fmt.Errorf("unknown use class '%s'", req.Use)
+	}
+	floor, err := fs.findFloor(buildingID, floorID)
+	if err != nil {
+		return nil, err
+	}
+	if zonedArea := floor.ZonedArea() + req.Area; zonedArea > floor.Area {
+		return nil, fmt.Errorf("zones of floor %d would cover %.2f m2 of its %.2f m2", floorID, zonedArea, floor.Area)
+	}
+
+	zone := &model.Zone{FloorID: floorID, Name: req.Name, Area: req.Area, Use: req.Use}
+	if err := fs.repo.SaveZone(zone); err != nil {
+		return nil, fmt.Errorf("failed to add zone to floor %d: %w", floorID, err)
+	}
+	return zone, nil
+}
+
+// RemoveZone removes a zone and the assemblies assigned to it from a floor.
+func (fs *floorService) RemoveZone(buildingID uint, floorID uint, zoneID uint) error {
+	floor, err := fs.findFloor(buildingID, floorID)
+	if err != nil {
+		return err
+	}
+	zone := floor.FindZone(zoneID)
+	if zone == nil {
+		return fmt.Errorf("zone %d is not on floor %d", zoneID, floorID)
+	}
+	if err := fs.repo.DeleteZone(zone); err != nil {
+		return fmt.Errorf("failed to remove zone %d: %w", zoneID, err)
+	}
+	return nil
+}
+
+// AssignAssembly assigns an assembly to a floor or to one of its zones.
+func (fs *floorService) AssignAssembly(buildingID uint, floorID uint, req FloorAssemblyRequest) (*model.FloorAssembly, error) {
+	element := req.Element
+	if element == "" {
+		element = model.ElementUnclassified
+	}
+	if !element.IsValid() {
+		return nil, fmt.Errorf("unknown building element '%s'", element)
+	}
+	floor, err := fs.findFloor(buildingID, floorID)
+	if err != nil {
+		return nil, err
+	}
+	if req.ZoneID != nil && floor.FindZone(*req.ZoneID) == nil {
+		return nil, fmt.Errorf("zone %d is not on floor %d", *req.ZoneID, floorID)
+	}
+	if _, err := fs.assemblyRepo.FindByID(req.AssemblyID); err != nil {
+		return nil, fmt.Errorf("failed to find assembly with ID %d: %w", req.AssemblyID, err)
+	}
+
+	link := &model.FloorAssembly{
+		FloorID:    floorID,
+		ZoneID:     req.ZoneID,
+		AssemblyID: req.AssemblyID,
+		Element:    element,
+		Quantity:   1,
+	}
+	if req.Quantity != nil {
+		link.Quantity = *req.Quantity
+	}
+	if err := fs.repo.SaveAssemblyLink(link); err != nil {
+		return nil, fmt.Errorf("failed to assign assembly %d to floor %d: %w", req.AssemblyID, floorID, err)
+	}
+	if err := fs.buildingRepo.PinMaterials([]uint{buildingID}); err != nil {
+		return nil, fmt.Errorf("failed to pin material versions for building %d: %w", buildingID, err)
+	}
+	return link, nil
+}
+
+// RemoveAssembly removes an assembly assignment from a floor.
+func (fs *floorService) RemoveAssembly(buildingID uint, floorID uint, linkID uint) error {
+	floor, err := fs.findFloor(buildingID, floorID)
+	if err != nil {
+		return err
+	}
+	for _, link := range floor.AssemblyLinks {
+		if link.ID == linkID {
+			if err := fs.repo.DeleteAssemblyLink(link); err != nil {
+				return fmt.Errorf("failed to remove assembly assignment %d: %w", linkID, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("assembly assignment %d is not on floor %d", linkID, floorID)
+}
+
+// ComputeCarbonByFloor calculates the carbon of each floor of the building.
+func (fs *floorService) ComputeCarbonByFloor(buildingID uint, normalisation Normalisation, methodologyID string) (*FloorCarbonResult, error) {
+	building, buildingCarbon, modules, err := fs.calculateBuilding(buildingID, methodologyID)
+	if err != nil {
+		return nil, err
+	}
+	denominator, err := NewDenominator(building, normalisation)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FloorCarbonResult{
+		Total:       buildingCarbon.Total,
+		Shared:      building.CalculateSharedCarbonForPhase(modules...),
+		Floors:      make([]FloorCarbon, 0, len(building.Floors)),
+		Methodology: buildingCarbon.Methodology,
+	}
+	for _, floor := range building.Floors {
+		carbon := floor.CalculateCarbonForPhase(modules...)
+		result.Floors = append(result.Floors, FloorCarbon{
+			FloorID:       floor.ID,
+			Name:          floor.Name,
+			Level:         floor.Level,
+			Use:           floor.Use,
+			Area:          floor.Area,
+			Carbon:        carbon,
+			CarbonPerArea: perArea(carbon, floor.Area),
+			ByElement:     newCarbonResult(carbon, floor.CalculateCarbonByElementForPhase(modules...)).ByElement,
+		})
+	}
+	result.Normalise(denominator)
+	return result, nil
+}
+
+// ComputeCarbonByUse calculates the carbon of each use class across the building's floors.
+func (fs *floorService) ComputeCarbonByUse(buildingID uint, normalisation Normalisation, methodologyID string) (*UseCarbonResult, error) {
+	building, buildingCarbon, modules, err := fs.calculateBuilding(buildingID, methodologyID)
+	if err != nil {
+		return nil, err
+	}
+	denominator, err := NewDenominator(building, normalisation)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UseCarbonResult{
+		Total:       buildingCarbon.Total,
+		Shared:      building.CalculateSharedCarbonForPhase(modules...),
+		ByUse:       []UseCarbon{},
+		Methodology: buildingCarbon.Methodology,
+	}
+	carbonByUse := building.CalculateCarbonByUseForPhase(modules...)
+	areaByUse := building.AreaByUse()
+	for _, use := range model.UseClasses {
+		carbon, hasCarbon := carbonByUse[use]
+		area, hasArea := areaByUse[use]
+		if !hasCarbon && !hasArea {
+			continue
+		}
+		result.ByUse = append(result.ByUse, UseCarbon{
+			Use:           use,
+			Area:          area,
+			Carbon:        carbon,
+			CarbonPerArea: perArea(carbon, area),
+		})
+	}
+	result.Normalise(denominator)
+	return result, nil
+}
+
+// findFloor fetches a floor and checks that it belongs to the building.
+func (fs *floorService) findFloor(buildingID uint, floorID uint) (*model.Floor, error) {
+	floor, err := fs.repo.FindByID(floorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find floor with ID %d: %w", floorID, err)
+	}
+	if floor.BuildingID != buildingID {
+		return nil, fmt.Errorf("floor %d is not on building %d", floorID, buildingID)
+	}
+	return floor, nil
+}
+
+// calculateBuilding eagerly loads a building with its pinned material versions
+// and calculates it by the methodology, the building's own when none is given.
+// The modules the methodology includes are returned to break the result down.
+func (fs *floorService) calculateBuilding(buildingID uint, methodologyID string) (*model.Building, *CarbonResult, []string, error) {
+	building, err := fs.buildingRepo.EagerFindByID(buildingID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	methodology, err := resolveMethodology(methodologyID, building)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	modules, err := methodology.Modules()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	building.PinMaterialVersions()
+	carbon, err := fs.carbonCalcService.ComputeBuildingCarbon(building, methodology)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return building, carbon, modules, nil
+}
+
+// perArea divides carbon by an area, an empty area has no intensity.
+func perArea(carbon float64, area float64) float64 {
+	if area <= 0 {
+		return 0
+	}
+	return carbon / area
+}
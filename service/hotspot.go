@@ -0,0 +1,121 @@
+package service
+
+import (
+	"carbon-service/model"
+	"sort"
+)
+
+// ParetoThreshold is the share of the impact, in percent, the hotspots flagged
+// as Pareto together make up.
+const ParetoThreshold = 80.0
+
+// HotspotAnalysis ranks the elements, assemblies and materials of a building
+// by the carbon they contribute to a module scope, largest first, with the
+// materials' values under the recorded methodology.
+type HotspotAnalysis struct {
+	BuildingID  uint               `json:"buildingId"`
+	Scope       model.ModuleScope  `json:"scope"`
+	Total       float64            `json:"total"`
+	Elements    []Hotspot          `json:"elements"`
+	Assemblies  []Hotspot          `json:"assemblies"`
+	Materials   []Hotspot          `json:"materials"`
+	Unit        string             `json:"unit"`
+	Methodology *model.Methodology `json:"methodology"`
+}
+
+// Hotspot is one contributor with its share and the cumulative share of it and
+// every larger contributor, in percent of the total. Pareto flags the
+// contributors that together make up ParetoThreshold percent of the total.
+type Hotspot struct {
+	ID              uint    `json:"id,omitempty"`
+	Name            string  `json:"name"`
+	Carbon          float64 `json:"carbon"`
+	Share           float64 `json:"share"`
+	CumulativeShare float64 `json:"cumulativeShare"`
+	Pareto          bool    `json:"pareto"`
+}
+
+// NewHotspotAnalysis ranks the contributors of the building's breakdown to the
+// modules of the scope. Material versions have to be pinned first.
+func NewHotspotAnalysis(building *model.Building, scope model.ModuleScope, modules []string) *HotspotAnalysis {
+	inScope := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		inScope[module] = true
+	}
+
+	root := building.Breakdown()
+	analysis := &HotspotAnalysis{BuildingID: building.ID, Scope: scope, Total: scopedSubtotal(root, inScope)}
+
+	var elements, assemblies []Hotspot
+	materials := make(map[uint]*Hotspot)
+	for _, element := range root.Children {
+		elements = append(elements, Hotspot{Name: element.Name, Carbon: scopedSubtotal(element, inScope)})
+		for _, assembly := range element.Children {
+			name := assembly.Name
+			if assembly.Location != "" {
+				name += " (" + assembly.Location + ")"
+			}
+			assemblies = append(assemblies, Hotspot{ID: assembly.ID, Name: name, Carbon: scopedSubtotal(assembly, inScope)})
+			addMaterialHotspots(materials, assembly, inScope)
+		}
+	}
+
+	analysis.Elements = rankHotspots(elements, analysis.Total)
+	analysis.Assemblies = rankHotspots(assemblies, analysis.Total)
+	analysis.Materials = make([]Hotspot, 0, len(materials))
+	for _, material := range materials {
+		analysis.Materials = append(analysis.Materials, *material)
+	}
+	analysis.Materials = rankHotspots(analysis.Materials, analysis.Total)
+	return analysis
+}
+
+// scopedSubtotal sums the module nodes of the breakdown that are in scope.
+func scopedSubtotal(node *model.BreakdownNode, inScope map[string]bool) float64 {
+	if node.Level == model.LevelModule {
+		if inScope[node.Name] {
+			return node.Subtotal
+		}
+		return 0
+	}
+	var total float64
+	for _, child := range node.Children {
+		total += scopedSubtotal(child, inScope)
+	}
+	return total
+}
+
+// addMaterialHotspots adds the carbon of every material of the breakdown,
+// including those of sub-assemblies, to the material's hotspot.
+func addMaterialHotspots(materials map[uint]*Hotspot, node *model.BreakdownNode, inScope map[string]bool) {
+	for _, child := range node.Children {
+		switch child.Level {
+		case model.LevelMaterial:
+			if materials[child.ID] == nil {
+				materials[child.ID] = &Hotspot{ID: child.ID, Name: child.Name}
+			}
+			materials[child.ID].Carbon += scopedSubtotal(child, inScope)
+		case model.LevelAssembly:
+			addMaterialHotspots(materials, child, inScope)
+		}
+	}
+}
+
+// rankHotspots sorts the hotspots largest first and sets their shares of the
+// total and Pareto flags.
+func rankHotspots(hotspots []Hotspot, total float64) []Hotspot {
+	if hotspots == nil {
+		return []Hotspot{}
+	}
+	sort.SliceStable(hotspots, func(i, j int) bool { return hotspots[i].Carbon > hotspots[j].Carbon })
+	var cumulative float64
+	for i := range hotspots {
+		if total != 0 {
+			hotspots[i].Share = hotspots[i].Carbon / total * 100
+		}
+		hotspots[i].Pareto = total != 0 && cumulative < ParetoThreshold
+		cumulative += hotspots[i].Share
+		hotspots[i].CumulativeShare = cumulative
+	}
+	return hotspots
+}
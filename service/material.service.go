@@ -3,48 +3,484 @@ package service
 import (
 	"carbon-service/model"
 	"carbon-service/repository"
+	"carbon-service/service/converter"
 	"fmt"
+
+	"gorm.io/gorm"
 )
 
 // MaterialService defines the operations available for managing materials,
 // including creation, retrieval, and carbon footprint calculation.
 type MaterialService interface {
-	CreateMaterial(name string) (*model.Material, error)
+	CreateMaterial(req CreateMaterialRequest) (*model.Material, error)
 	GetMaterial(id uint) (*model.Material, error)
 	GetAllMaterials() ([]model.Material, error)
 	ComputeTotalCarbon(materialID uint) (float64, error)
+	ComputeBreakdown(materialID uint) (*model.BreakdownNode, error)
+	CreateMaterialVersion(materialID uint, req CreateMaterialVersionRequest) (*model.MaterialVersion, error)
+	GetMaterialVersions(materialID uint) ([]model.MaterialVersion, error)
+	PreviewMaterialUpgrade(materialID uint) (*MaterialUpgrade, error)
+	UpgradeMaterial(materialID uint) (*MaterialUpgrade, error)
+	GetWhereUsed(materialID uint) (*MaterialUsage, error)
+	DryRunMaterialChange(materialID uint, gwp model.Gwp) (*MaterialDryRun, error)
+	ConvertToDeclaredUnit(materialID uint, quantity float64, unit string, system model.Unit) (*DeclaredQuantity, error)
 }
 
 // materialService provides a concrete implementation of the MaterialService,
 // interacting with material data and carbon calculations.
 type materialService struct {
 	repo              repository.MaterialRepository
-	carbonCalcService CalculationService // Dependency for carbon calculations
+	assemblyRepo      repository.AssemblyRepository // Assemblies containing materials
+	buildingRepo      repository.BuildingRepository // Buildings using materials
+	carbonCalcService CalculationService            // Dependency for carbon calculations
+}
+
+// CreateMaterialRequest describes a new material. DeclaredUnit defaults to kg.
+type CreateMaterialRequest struct {
+	Name         string  `json:"name" binding:"required"`
+	DeclaredUnit string  `json:"declaredUnit"`
+	Density      float64 `json:"density"`
+	Thickness    float64 `json:"thickness"`
+	MassPerArea  float64 `json:"massPerArea"`
+	MassPerPiece float64 `json:"massPerPiece"`
+}
+
+// DeclaredQuantity is a quantity of a material converted to its declared unit.
+type DeclaredQuantity struct {
+	Quantity         float64 `json:"quantity"`
+	Unit             string  `json:"unit"`
+	DeclaredQuantity float64 `json:"declaredQuantity"`
+	DeclaredUnit     string  `json:"declaredUnit"`
+}
+
+type CreateMaterialVersionRequest struct {
+	EpdSource string    `json:"epdSource"`
+	Gwp       model.Gwp `json:"gwp" binding:"required"`
+}
+
+// MaterialUpgrade describes the effect of moving every building that uses a
+// material onto the material's latest version.
+type MaterialUpgrade struct {
+	MaterialID      uint                  `json:"materialId"`
+	LatestVersion   int                   `json:"latestVersion"`
+	LatestVersionID uint                  `json:"latestVersionId"`
+	Buildings       []BuildingCarbonDelta `json:"buildings"`
+}
+
+// MaterialDryRun describes the effect of replacing a material's GWP values
+// with a proposed set on every building that uses the material.
+type MaterialDryRun struct {
+	MaterialID uint                  `json:"materialId"`
+	Buildings  []BuildingCarbonDelta `json:"buildings"`
+}
+
+// BuildingCarbonDelta is the whole life carbon of a building before and after a material change.
+type BuildingCarbonDelta struct {
+	BuildingID     uint    `json:"buildingId"`
+	BuildingName   string  `json:"buildingName"`
+	PinnedVersion  int     `json:"pinnedVersion"`
+	CurrentCarbon  float64 `json:"currentCarbon"`
+	UpgradedCarbon float64 `json:"upgradedCarbon"`
+	Delta          float64 `json:"delta"`
+}
+
+// MaterialUsage lists every assembly and building reachable from a material.
+type MaterialUsage struct {
+	MaterialID uint            `json:"materialId"`
+	Assemblies []AssemblyUsage `json:"assemblies"`
+	Buildings  []BuildingUsage `json:"buildings"`
+}
+
+// AssemblyUsage is an assembly containing a material, directly or in one of
+// its sub-assemblies, and the buildings using that assembly directly.
+type AssemblyUsage struct {
+	AssemblyID   uint   `json:"assemblyId"`
+	AssemblyName string `json:"assemblyName"`
+	BuildingIDs  []uint `json:"buildingIds"`
+}
+
+// BuildingUsage is a building using a material and the material's share of its total.
+type BuildingUsage struct {
+	BuildingID     uint    `json:"buildingId"`
+	BuildingName   string  `json:"buildingName"`
+	TotalCarbon    float64 `json:"totalCarbon"`
+	MaterialCarbon float64 `json:"materialCarbon"`
+	Share          float64 `json:"share"`
 }
 
 // ComputeTotalCarbon implements MaterialService.
+// The material's latest version is used for the calculation.
 func (m *materialService) ComputeTotalCarbon(materialID uint) (float64, error) {
 	var material *model.Material
-	material, err := m.repo.FindByID(materialID)
+	material, err := m.repo.EagerFindByID(materialID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
 	}
+	material.UseVersion(material.LatestVersion())
 	return material.ComputeWholeLifeCarbon(), nil
 }
 
+// ComputeBreakdown implements MaterialService.
+// It traces the whole life carbon of one declared unit of the material back
+// to the modules of its latest version.
+func (m *materialService) ComputeBreakdown(materialID uint) (*model.BreakdownNode, error) {
+	material, err := m.repo.EagerFindByID(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+	material.UseVersion(material.LatestVersion())
+	return material.Breakdown(1), nil
+}
+
+// CreateMaterialVersion implements MaterialService.
+// It records a renewed EPD as the next version of the material,
+// leaving earlier versions untouched.
+func (m *materialService) CreateMaterialVersion(materialID uint, req CreateMaterialVersionRequest) (*model.MaterialVersion, error) {
+	material, err := m.repo.EagerFindByID(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+
+	next := 1
+	if latest := material.LatestVersion(); latest != nil {
+		next = latest.Version + 1
+	}
+
+	gwp := req.Gwp
+	gwp.Model = gorm.Model{}
+	gwp.MaterialID = material.ID
+	version := &model.MaterialVersion{
+		MaterialID: material.ID,
+		Version:    next,
+		EpdSource:  req.EpdSource,
+		Gwp:        gwp,
+	}
+	if err := m.repo.SaveVersion(version); err != nil {
+		return nil, fmt.Errorf("failed to create version of material %d: %w", materialID, err)
+	}
+	return version, nil
+}
+
+// GetMaterialVersions implements MaterialService.
+func (m *materialService) GetMaterialVersions(materialID uint) ([]model.MaterialVersion, error) {
+	if _, err := m.repo.FindByID(materialID); err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+	versions, err := m.repo.FindVersions(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find versions of material %d: %w", materialID, err)
+	}
+	return versions, nil
+}
+
+// PreviewMaterialUpgrade implements MaterialService.
+// It reports the carbon delta of every building using the material that is
+// pinned to an older version of it, without changing any pins.
+func (m *materialService) PreviewMaterialUpgrade(materialID uint) (*MaterialUpgrade, error) {
+	material, err := m.repo.EagerFindByID(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+	latest := material.LatestVersion()
+	if latest == nil {
+		return nil, fmt.Errorf("material %d has no versions", materialID)
+	}
+
+	buildings, err := m.findBuildingsUsing(material)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrade := &MaterialUpgrade{
+		MaterialID:      material.ID,
+		LatestVersion:   latest.Version,
+		LatestVersionID: latest.ID,
+		Buildings:       []BuildingCarbonDelta{},
+	}
+	for i := range buildings {
+		building := &buildings[i]
+		building.PinMaterialVersions()
+
+		pinned := pinnedVersion(building, material)
+		if pinned == nil || pinned.ID == latest.ID {
+			continue
+		}
+
+		upgrade.Buildings = append(upgrade.Buildings, m.carbonDelta(building, material.ID, pinned, latest))
+	}
+	return upgrade, nil
+}
+
+// UpgradeMaterial implements MaterialService.
+// It pins every building using the material to its latest version and
+// returns the deltas that were applied.
+func (m *materialService) UpgradeMaterial(materialID uint) (*MaterialUpgrade, error) {
+	upgrade, err := m.PreviewMaterialUpgrade(materialID)
+	if err != nil {
+		return nil, err
+	}
+	buildingIDs := make([]uint, len(upgrade.Buildings))
+	for i, delta := range upgrade.Buildings {
+		buildingIDs[i] = delta.BuildingID
+	}
+	if err := m.buildingRepo.RepinMaterial(materialID, upgrade.LatestVersionID, buildingIDs); err != nil {
+		return nil, fmt.Errorf("failed to upgrade material %d: %w", materialID, err)
+	}
+	return upgrade, nil
+}
+
+// GetWhereUsed implements MaterialService.
+// It walks the assemblies containing the material, at any depth, to the
+// buildings using them and reports the material's share of each building's
+// whole life carbon.
+func (m *materialService) GetWhereUsed(materialID uint) (*MaterialUsage, error) {
+	material, err := m.repo.EagerFindByID(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+
+	usage := &MaterialUsage{
+		MaterialID: material.ID,
+		Assemblies: []AssemblyUsage{},
+		Buildings:  []BuildingUsage{},
+	}
+	assemblies, buildingIDs, err := m.findUsage(material.ID)
+	if err != nil {
+		return nil, err
+	}
+	usage.Assemblies = append(usage.Assemblies, assemblies...)
+
+	buildings, err := m.buildingRepo.EagerFindByIDs(buildingIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find buildings using material %d: %w", material.ID, err)
+	}
+	for i := range buildings {
+		building := &buildings[i]
+		building.PinMaterialVersions()
+
+		total := m.carbonCalcService.ComputeWholeLifeCarbonSync(building)
+		materialCarbon := building.ComputeMaterialCarbon(material.ID)
+		var share float64
+		if total != 0 {
+			share = materialCarbon / total
+		}
+		usage.Buildings = append(usage.Buildings, BuildingUsage{
+			BuildingID:     building.ID,
+			BuildingName:   building.Name,
+			TotalCarbon:    total,
+			MaterialCarbon: materialCarbon,
+			Share:          share,
+		})
+	}
+	return usage, nil
+}
+
+// DryRunMaterialChange implements MaterialService.
+// It recalculates every building using the material as if the material's
+// GWP values were replaced by the proposed ones, without persisting anything.
+func (m *materialService) DryRunMaterialChange(materialID uint, gwp model.Gwp) (*MaterialDryRun, error) {
+	material, err := m.repo.EagerFindByID(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+
+	buildings, err := m.findBuildingsUsing(material)
+	if err != nil {
+		return nil, err
+	}
+
+	proposed := &model.MaterialVersion{MaterialID: material.ID, Gwp: gwp}
+	dryRun := &MaterialDryRun{
+		MaterialID: material.ID,
+		Buildings:  []BuildingCarbonDelta{},
+	}
+	for i := range buildings {
+		building := &buildings[i]
+		building.PinMaterialVersions()
+		pinned := pinnedVersion(building, material)
+		dryRun.Buildings = append(dryRun.Buildings, m.carbonDelta(building, material.ID, pinned, proposed))
+	}
+	return dryRun, nil
+}
+
+// findBuildingsUsing loads every building using the material, through
+// assemblies containing it at any depth.
+func (m *materialService) findBuildingsUsing(material *model.Material) ([]model.Building, error) {
+	_, ids, err := m.findUsage(material.ID)
+	if err != nil {
+		return nil, err
+	}
+	buildings, err := m.buildingRepo.EagerFindByIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find buildings using material %d: %w", material.ID, err)
+	}
+	return buildings, nil
+}
+
+// findUsage walks up from the assemblies containing the material directly,
+// as a material quantity or in a layer, through every assembly containing
+// them as a sub-assembly. It returns each of these assemblies with the
+// buildings using it directly, and the IDs of all those buildings.
+// The assemblies are loaded a level of the walk at a time, so the queries
+// grow with the depth of nesting rather than the number of assemblies.
+func (m *materialService) findUsage(materialID uint) ([]AssemblyUsage, []uint, error) {
+	level, err := m.assemblyRepo.FindIDsByMaterial(materialID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find assemblies containing material %d: %w", materialID, err)
+	}
+	seen := make(map[uint]bool, len(level))
+	for _, id := range level {
+		seen[id] = true
+	}
+
+	var assemblies []AssemblyUsage
+	var buildingIDs []uint
+	usedBy := make(map[uint]bool)
+	for len(level) > 0 {
+		found, err := m.assemblyRepo.FindByIDs(level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find assemblies with IDs %v: %w", level, err)
+		}
+		byID := make(map[uint]model.Assembly, len(found))
+		for _, assembly := range found {
+			byID[assembly.ID] = assembly
+		}
+		usingIDs, err := m.buildingRepo.FindIDsByAssemblies(level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find buildings using assemblies %v: %w", level, err)
+		}
+
+		for _, assemblyID := range level {
+			assembly, ok := byID[assemblyID]
+			if !ok {
+				return nil, nil, fmt.Errorf("failed to find assembly with ID %d: %w", assemblyID, gorm.ErrRecordNotFound)
+			}
+			ids := usingIDs[assemblyID]
+			if ids == nil {
+				ids = []uint{}
+			}
+			assemblies = append(assemblies, AssemblyUsage{AssemblyID: assembly.ID, AssemblyName: assembly.Name, BuildingIDs: ids})
+			for _, id := range ids {
+				if !usedBy[id] {
+					usedBy[id] = true
+					buildingIDs = append(buildingIDs, id)
+				}
+			}
+		}
+
+		parentIDs, err := m.assemblyRepo.FindParentIDsOf(level)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find assemblies containing assemblies %v: %w", level, err)
+		}
+		level = nil
+		for _, parentID := range parentIDs {
+			if !seen[parentID] {
+				seen[parentID] = true
+				level = append(level, parentID)
+			}
+		}
+	}
+	return assemblies, buildingIDs, nil
+}
+
+// carbonDelta calculates the building's whole life carbon with its current
+// material versions and again with the material switched to the proposed version.
+// The building is left calculating with the proposed version.
+func (m *materialService) carbonDelta(building *model.Building, materialID uint, pinned, proposed *model.MaterialVersion) BuildingCarbonDelta {
+	current := m.carbonCalcService.ComputeWholeLifeCarbonSync(building)
+	building.UseMaterialVersion(materialID, proposed)
+	proposedCarbon := m.carbonCalcService.ComputeWholeLifeCarbonSync(building)
+
+	delta := BuildingCarbonDelta{
+		BuildingID:     building.ID,
+		BuildingName:   building.Name,
+		CurrentCarbon:  current,
+		UpgradedCarbon: proposedCarbon,
+		Delta:          proposedCarbon - current,
+	}
+	if pinned != nil {
+		delta.PinnedVersion = pinned.Version
+	}
+	return delta
+}
+
+// toDeclaredUnit converts a quantity of the material to the material's declared
+// unit using its conversion properties.
+func toDeclaredUnit(material *model.Material, quantity float64, unit string) (float64, error) {
+	props := converter.MaterialProperties{
+		Density:      material.Density,
+		Thickness:    material.Thickness,
+		MassPerArea:  material.MassPerArea,
+		MassPerPiece: material.MassPerPiece,
+	}
+	declared, err := converter.ConvertQuantity(quantity, unit, material.DeclaredUnit, props)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert material %d to its declared unit: %w", material.ID, err)
+	}
+	return declared, nil
+}
+
+// pinnedVersion returns the version of the material the building is pinned to.
+func pinnedVersion(building *model.Building, material *model.Material) *model.MaterialVersion {
+	for _, pin := range building.MaterialPins {
+		if pin.MaterialID == material.ID {
+			return material.FindVersion(pin.MaterialVersionID)
+		}
+	}
+	return nil
+}
+
 // CreateMaterial implements MaterialService.
-func (m *materialService) CreateMaterial(name string) (*model.Material, error) {
-	if m.repo.ExistsByMaterialName(name) {
-		return nil, fmt.Errorf("material name '%s' already exists", name)
+func (m *materialService) CreateMaterial(req CreateMaterialRequest) (*model.Material, error) {
+	if m.repo.ExistsByMaterialName(req.Name) {
+		return nil, fmt.Errorf("material name '%s' already exists", req.Name)
+	}
+	declaredUnit := req.DeclaredUnit
+	if declaredUnit == "" {
+		declaredUnit = model.DefaultDeclaredUnit
+	}
+	if _, err := converter.QuantityDimension(declaredUnit); err != nil {
+		return nil, fmt.Errorf("invalid declared unit: %w", err)
 	}
 
-	material := &model.Material{Name: name}
+	material := &model.Material{
+		Name:         req.Name,
+		DeclaredUnit: declaredUnit,
+		Density:      req.Density,
+		Thickness:    req.Thickness,
+		MassPerArea:  req.MassPerArea,
+		MassPerPiece: req.MassPerPiece,
+	}
 	if err := m.repo.Save(material); err != nil {
 		return nil, fmt.Errorf("failed to create material: %w", err)
 	}
 	return material, nil
 }
 
+// ConvertToDeclaredUnit implements MaterialService. Without a unit the quantity
+// is in the unit of the unit system for the dimension the material is declared in.
+func (m *materialService) ConvertToDeclaredUnit(materialID uint, quantity float64, unit string, system model.Unit) (*DeclaredQuantity, error) {
+	material, err := m.repo.FindByID(materialID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find material with ID %d: %w", materialID, err)
+	}
+	if unit == "" {
+		if unit, err = QuantityUnitFor(system, material.DeclaredUnit); err != nil {
+			return nil, fmt.Errorf("failed to convert material %d to its declared unit: %w", material.ID, err)
+		}
+	}
+	declared, err := toDeclaredUnit(material, quantity, unit)
+	if err != nil {
+		return nil, err
+	}
+	return &DeclaredQuantity{
+		Quantity:         quantity,
+		Unit:             unit,
+		DeclaredQuantity: declared,
+		DeclaredUnit:     material.DeclaredUnit,
+	}, nil
+}
+
 // GetAllMaterials implements MaterialService.
 func (m *materialService) GetAllMaterials() ([]model.Material, error) {
 	materials, err := m.repo.FindAll()
@@ -64,9 +500,11 @@ func (m *materialService) GetMaterial(id uint) (*model.Material, error) {
 }
 
 // NewMaterialService initializes a new material service with necessary dependencies.
-func NewMaterialService(r repository.MaterialRepository, cs CalculationService) MaterialService {
+func NewMaterialService(r repository.MaterialRepository, ar repository.AssemblyRepository, br repository.BuildingRepository, cs CalculationService) MaterialService {
 	return &materialService{
 		repo:              r,
+		assemblyRepo:      ar,
+		buildingRepo:      br,
 		carbonCalcService: cs,
 	}
 }
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"carbon-service/model"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrPartialScope is returned when a methodology includes only some of the
+// modules an estimate covers, so the estimate cannot be reported under it.
+var ErrPartialScope = errors.New("the methodology includes only part of the modules the estimate covers")
+
+// Identifiers of the built-in methodologies.
+const (
+	MethodologyEN15978  = "en-15978"
+	MethodologyRICS2017 = "rics-ps-2017"
+	MethodologyRICS2023 = "rics-ps-2023"
+	MethodologyISO21930 = "iso-21930"
+
+	// DefaultMethodology is used by buildings and requests that do not select one.
+	DefaultMethodology = MethodologyEN15978
+)
+
+var (
+	methodologiesMu sync.RWMutex
+	methodologies   = map[string]*model.Methodology{}
+)
+
+func init() {
+	for _, methodology := range DefaultMethodologies() {
+		if err := RegisterMethodology(methodology); err != nil {
+			panic(fmt.Sprintf("invalid built-in methodology '%s': %v", methodology.ID, err))
+		}
+	}
+}
+
+// DefaultMethodologies returns the built-in methodologies: EN 15978 building
+// assessments, the first and second editions of the RICS professional
+// statement on whole life carbon assessment, and the ISO 21930 core rules
+// for construction product EPDs, which cover the product stage.
+func DefaultMethodologies() []*model.Methodology {
+	return []*model.Methodology{
+		{
+			ID:       MethodologyEN15978,
+			Name:     "EN 15978",
+			Version:  "2011",
+			Scope:    model.ScopeWholeLife,
+			Biogenic: model.BiogenicMinusOnePlusOne,
+		},
+		{
+			ID:          MethodologyRICS2017,
+			Name:        "RICS PS Whole life carbon assessment for the built environment",
+			Version:     "1st edition (2017)",
+			Scope:       model.ScopeWholeLife,
+			Biogenic:    model.BiogenicZeroZero,
+			StudyPeriod: 60,
+		},
+		{
+			ID:          MethodologyRICS2023,
+			Name:        "RICS PS Whole life carbon assessment for the built environment",
+			Version:     "2nd edition (2023)",
+			Scope:       model.ScopeWholeLife,
+			Biogenic:    model.BiogenicMinusOnePlusOne,
+			StudyPeriod: 60,
+		},
+		{
+			ID:       MethodologyISO21930,
+			Name:     "ISO 21930",
+			Version:  "2017",
+			Scope:    "A1-A3",
+			Biogenic: model.BiogenicMinusOnePlusOne,
+		},
+	}
+}
+
+// RegisterMethodology makes a methodology selectable by its ID, replacing any
+// methodology registered with the same ID.
+func RegisterMethodology(methodology *model.Methodology) error {
+	if methodology.ID == "" {
+		return fmt.Errorf("methodology needs an ID")
+	}
+	if _, err := methodology.Modules(); err != nil {
+		return err
+	}
+	methodologiesMu.Lock()
+	defer methodologiesMu.Unlock()
+	methodologies[methodology.ID] = methodology
+	return nil
+}
+
+// FindMethodology returns the registered methodology with the ID.
+func FindMethodology(id string) (*model.Methodology, error) {
+	methodologiesMu.RLock()
+	defer methodologiesMu.RUnlock()
+	methodology, ok := methodologies[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown methodology '%s'", id)
+	}
+	return methodology, nil
+}
+
+// Methodologies returns the registered methodologies ordered by ID.
+func Methodologies() []*model.Methodology {
+	methodologiesMu.RLock()
+	defer methodologiesMu.RUnlock()
+	list := make([]*model.Methodology, 0, len(methodologies))
+	for _, methodology := range methodologies {
+		list = append(list, methodology)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// resolveMethodology returns the methodology selected by the request, or else
+// by the building, or else the default one.
+func resolveMethodology(id string, building *model.Building) (*model.Methodology, error) {
+	if id == "" {
+		id = building.Methodology
+	}
+	if id == "" {
+		id = DefaultMethodology
+	}
+	return FindMethodology(id)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/service/converter"
+	"errors"
+	"fmt"
+)
+
+// ErrNoDenominator is returned when a building has no value for the
+// normalisation its results are asked for, e.g. no occupants.
+var ErrNoDenominator = errors.New("the building has no value to normalise by")
+
+// Normalisation is the denominator carbon results are divided by.
+type Normalisation string
+
+const (
+	NormaliseAbsolute      Normalisation = "absolute"
+	NormalisePerGFA        Normalisation = "per-m2-gfa"
+	NormalisePerGIA        Normalisation = "per-m2-gia"
+	NormalisePerGFAPerYear Normalisation = "per-m2-gfa-year"
+	NormalisePerGIAPerYear Normalisation = "per-m2-gia-year"
+	NormalisePerOccupant   Normalisation = "per-occupant"
+	NormalisePerBedroom    Normalisation = "per-bedroom"
+)
+
+// Normalisations lists the supported normalisations.
+var Normalisations = []Normalisation{
+	NormaliseAbsolute, NormalisePerGFA, NormalisePerGIA, NormalisePerGFAPerYear,
+	NormalisePerGIAPerYear, NormalisePerOccupant, NormalisePerBedroom,
+}
+
+// IsValid reports whether n is a supported normalisation. Empty means absolute.
+func (n Normalisation) IsValid() bool {
+	if n == "" {
+		return true
+	}
+	for _, normalisation := range Normalisations {
+		if n == normalisation {
+			return true
+		}
+	}
+	return false
+}
+
+// Denominator records what a result was normalised by: the normalisation,
+// its value for the building and its unit, e.g. 1200 "m2 GIA".
+type Denominator struct {
+	Normalisation Normalisation `json:"normalisation"`
+	Value         float64       `json:"value"`
+	Unit          string        `json:"unit"`
+}
+
+// NewDenominator returns the building's denominator for the normalisation.
+// An empty normalisation means absolute results.
+func NewDenominator(building *model.Building, normalisation Normalisation) (*Denominator, error) {
+	studyPeriod := float64(building.ReferenceStudyPeriod())
+
+	var denominator Denominator
+	switch normalisation {
+	case "", NormaliseAbsolute:
+		denominator = Denominator{Normalisation: NormaliseAbsolute, Value: 1}
+	case NormalisePerGFA:
+		denominator = Denominator{Normalisation: normalisation, Value: building.GFA, Unit: "m2 GFA"}
+	case NormalisePerGIA:
+		denominator = Denominator{Normalisation: normalisation, Value: building.GIA, Unit: "m2 GIA"}
+	case NormalisePerGFAPerYear:
+		denominator = Denominator{Normalisation: normalisation, Value: building.GFA * studyPeriod, Unit: "m2 GFA year"}
+	case NormalisePerGIAPerYear:
+		denominator = Denominator{Normalisation: normalisation, Value: building.GIA * studyPeriod, Unit: "m2 GIA year"}
+	case NormalisePerOccupant:
+		denominator = Denominator{Normalisation: normalisation, Value: float64(building.Occupants), Unit: "occupant"}
+	case NormalisePerBedroom:
+		denominator = Denominator{Normalisation: normalisation, Value: float64(building.Bedrooms), Unit: "bedroom"}
+	default:
+		return nil, fmt.Errorf("unknown normalisation '%s'", normalisation)
+	}
+	if denominator.Value <= 0 {
+		return nil, fmt.Errorf("failed to normalise building %d %s: %w", building.ID, normalisation, ErrNoDenominator)
+	}
+	return &denominator, nil
+}
+
+// PerArea reports whether the normalisation is by an area, over the study period or not.
+func (n Normalisation) PerArea() bool {
+	switch n {
+	case NormalisePerGFA, NormalisePerGIA, NormalisePerGFAPerYear, NormalisePerGIAPerYear:
+		return true
+	}
+	return false
+}
+
+// PerArea reports whether the denominator is an area, over the study period or not.
+func (d *Denominator) PerArea() bool {
+	return d.Normalisation.PerArea()
+}
+
+// perYear reports whether the denominator is over the study period.
+func (d *Denominator) perYear() bool {
+	return d.Normalisation == NormalisePerGFAPerYear || d.Normalisation == NormalisePerGIAPerYear
+}
+
+// Normalise divides the total and every element and module of the result by the denominator.
+func (r *CarbonResult) Normalise(denominator *Denominator) {
+	r.Normalisation = denominator
+	r.Total /= denominator.Value
+	for i := range r.ByElement {
+		r.ByElement[i].Carbon /= denominator.Value
+	}
+	for i := range r.ByModule {
+		r.ByModule[i].Carbon /= denominator.Value
+	}
+}
+
+// Normalise divides the carbon of every part of the estimate by the denominator.
+func (r *SubstructureResult) Normalise(denominator *Denominator) {
+	r.Normalisation = denominator
+	r.RetainingWallCarbon /= denominator.Value
+	r.BasementSlabCarbon /= denominator.Value
+	r.FoundationCarbon /= denominator.Value
+	r.ExcavationCarbon /= denominator.Value
+	r.TotalCarbon /= denominator.Value
+}
+
+// Normalise divides the total and every element and module of the result by the denominator.
+func (r *ScenarioResult) Normalise(denominator *Denominator) {
+	r.Normalisation = denominator
+	r.Total /= denominator.Value
+	for i := range r.ByElement {
+		r.ByElement[i].Carbon /= denominator.Value
+	}
+	for i := range r.ByModule {
+		r.ByModule[i].Carbon /= denominator.Value
+	}
+}
+
+// Normalise divides the total, the shared carbon and the carbon of every
+// floor by the denominator. Intensities per m2 of floor area are kept.
+func (r *FloorCarbonResult) Normalise(denominator *Denominator) {
+	r.Normalisation = denominator
+	r.Total /= denominator.Value
+	r.Shared /= denominator.Value
+	for i := range r.Floors {
+		r.Floors[i].Carbon /= denominator.Value
+		for j := range r.Floors[i].ByElement {
+			r.Floors[i].ByElement[j].Carbon /= denominator.Value
+		}
+	}
+}
+
+// Normalise divides the total, the shared carbon and the carbon of every use
+// class by the denominator. Intensities per m2 of floor area are kept.
+func (r *UseCarbonResult) Normalise(denominator *Denominator) {
+	r.Normalisation = denominator
+	r.Total /= denominator.Value
+	r.Shared /= denominator.Value
+	for i := range r.ByUse {
+		r.ByUse[i].Carbon /= denominator.Value
+	}
+}
+
+// Normalise divides the total and every element of the snapshot's result by
+// the denominator. The intensity per m2 GFA is kept.
+func (p *TrajectoryPoint) Normalise(denominator *Denominator) {
+	p.Normalisation = denominator
+	p.TotalCarbon /= denominator.Value
+	for i := range p.ByElement {
+		p.ByElement[i].Carbon /= denominator.Value
+	}
+}
+
+// Present converts the normalised result into the output unit and records the
+// unit it is expressed in, e.g. "tCO2e/m2/yr". Per area units need a result
+// normalised by an area. Only the result is converted, stored values are not.
+func (r *CarbonResult) Present(unit converter.OutputUnit) error {
+	scale, perArea, label, err := resultScale(unit, r.Normalisation)
+	if err != nil {
+		return err
+	}
+	r.Total = scale.Convert(r.Total, perArea)
+	convertElements(r.ByElement, scale, perArea)
+	convertModules(r.ByModule, scale, perArea)
+	r.Unit = label
+	return nil
+}
+
+// resultScale parses the output unit of a result normalised by the
+// denominator, absolute when nil. It returns the scale of the result, whether
+// its values are per m2, and the unit it is then expressed in.
+func resultScale(unit converter.OutputUnit, denominator *Denominator) (converter.OutputScale, bool, string, error) {
+	scale, err := unit.Scale()
+	if err != nil {
+		return scale, false, "", err
+	}
+	if denominator == nil {
+		denominator = &Denominator{Normalisation: NormaliseAbsolute, Value: 1}
+	}
+	perArea := denominator.PerArea()
+	if scale.PerArea() && !perArea {
+		return scale, false, "", fmt.Errorf("output unit '%s' needs a result normalised by an area", unit)
+	}
+
+	label := scale.Carbon
+	switch {
+	case perArea && scale.PerArea():
+		label += "/" + scale.Area
+	case perArea:
+		label += "/m2"
+	case denominator.Normalisation != NormaliseAbsolute:
+		label += "/" + denominator.Unit
+	}
+	if denominator.perYear() {
+		label += "/yr"
+	}
+	return scale, perArea, label, nil
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ScenarioService defines the operations available for managing the design
+// options of buildings and comparing them.
+type ScenarioService interface {
+	CreateScenario(buildingID uint, req ScenarioRequest) (*model.Scenario, error)
+	GetScenarios(buildingID uint) ([]model.Scenario, error)
+	UpdateScenario(buildingID uint, scenarioID uint, req ScenarioRequest) (*model.Scenario, error)
+	RemoveScenario(buildingID uint, scenarioID uint) error
+	OverrideAssembly(buildingID uint, scenarioID uint, assemblyID uint, req OverrideAssemblyRequest) (*model.Scenario, error)
+	RemoveOverride(buildingID uint, scenarioID uint, assemblyID uint) (*model.Scenario, error)
+	CompareScenarios(buildingID uint, scenarioIDs []uint, normalisation Normalisation, methodology string) (*ScenarioComparison, error)
+}
+
+// scenarioService provides a concrete implementation of the ScenarioService.
+type scenarioService struct {
+	repo              repository.ScenarioRepository
+	buildingRepo      repository.BuildingRepository // Buildings the scenarios belong to
+	assemblyRepo      repository.AssemblyRepository // Replacement assemblies
+	carbonCalcService CalculationService            // Dependency for carbon calculations
+}
+
+// NewScenarioService initializes a new scenario service with necessary dependencies.
+func NewScenarioService(r repository.ScenarioRepository, br repository.BuildingRepository, ar repository.AssemblyRepository, cs CalculationService) ScenarioService {
+	return &scenarioService{
+		repo:              r,
+		buildingRepo:      br,
+		assemblyRepo:      ar,
+		carbonCalcService: cs,
+	}
+}
+
+// ScenarioRequest describes a scenario. Geometry parameters that are left out
+// keep the building's value.
+type ScenarioRequest struct {
+	Name                  string   `json:"name" binding:"required"`
+	Description           string   `json:"description"`
+	FTF                   *float64 `json:"ftf" binding:"omitempty,gt=0"`
+	GroundFloorArea       *float64 `json:"groundFloorArea" binding:"omitempty,gt=0"`
+	WWR                   *float64 `json:"wwr" binding:"omitempty,gte=0,lte=1"`
+	AboveGroundFloorCount *int     `json:"aboveGroundFloorCount" binding:"omitempty,gte=0"`
+	UnderGroundFloorCount *int     `json:"underGroundFloorCount" binding:"omitempty,gte=0"`
+}
+
+// OverrideAssemblyRequest overrides an assembly of the building in a scenario:
+// it is replaced by another assembly, given another quantity, or removed.
+type OverrideAssemblyRequest struct {
+	ReplacementID *uint    `json:"replacementId"`
+	Quantity      *float64 `json:"quantity" binding:"omitempty,gte=0"`
+	Removed       bool     `json:"removed"`
+}
+
+// ScenarioComparison is the building's baseline result side by side with the
+// results of its scenarios.
+type ScenarioComparison struct {
+	Baseline  ScenarioResult   `json:"baseline"`
+	Scenarios []ScenarioResult `json:"scenarios"`
+}
+
+// ScenarioResult is the carbon of a building or of one of its scenarios,
+// normalised by its own denominator and expressed in Unit, calculated by the
+// recorded methodology, with its difference to the baseline for scenarios.
+type ScenarioResult struct {
+	ScenarioID    uint               `json:"scenarioId"`
+	Name          string             `json:"name"`
+	Total         float64            `json:"total"`
+	ByElement     []ElementCarbon    `json:"byElement"`
+	ByModule      []ModuleCarbon     `json:"byModule"`
+	Normalisation *Denominator       `json:"normalisation"`
+	Unit          string             `json:"unit"`
+	Methodology   *model.Methodology `json:"methodology"`
+	Delta         *ScenarioDelta     `json:"delta,omitempty"`
+}
+
+// ScenarioDelta is the difference of a scenario's result to the baseline,
+// positive when the scenario emits more.
+type ScenarioDelta struct {
+	Total     float64         `json:"total"`
+	ByElement []ElementCarbon `json:"byElement"`
+	ByModule  []ModuleCarbon  `json:"byModule"`
+}
+
+// ModuleCarbon is the carbon of one life cycle module.
+type ModuleCarbon struct {
+	Module string  `json:"module"`
+	Carbon float64 `json:"carbon"`
+}
+
+// CreateScenario adds a scenario to the building.
+func (ss *scenarioService) CreateScenario(buildingID uint, req ScenarioRequest) (*model.Scenario, error) {
+	if _, err := ss.buildingRepo.FindByID(buildingID); err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	scenario := &model.Scenario{BuildingID: buildingID}
+	applyScenarioRequest(scenario, req)
+	if err := ss.repo.Save(scenario); err != nil {
+		return nil, fmt.Errorf("failed to create scenario: %w", err)
+	}
+	return scenario, nil
+}
+
+// GetScenarios fetches the scenarios of a building with their overrides.
+func (ss *scenarioService) GetScenarios(buildingID uint) ([]model.Scenario, error) {
+	scenarios, err := ss.repo.FindByBuildingID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scenarios of building %d: %w", buildingID, err)
+	}
+	return scenarios, nil
+}
+
+// UpdateScenario replaces the name, description and geometry parameters of a scenario.
+func (ss *scenarioService) UpdateScenario(buildingID uint, scenarioID uint, req ScenarioRequest) (*model.Scenario, error) {
+	scenario, err := ss.findScenario(buildingID, scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	applyScenarioRequest(scenario, req)
+	if err := ss.repo.Save(scenario); err != nil {
+		return nil, fmt.Errorf("failed to update scenario %d: %w", scenarioID, err)
+	}
+	return scenario, nil
+}
+
+// RemoveScenario removes a scenario with its overrides.
+func (ss *scenarioService) RemoveScenario(buildingID uint, scenarioID uint) error {
+	scenario, err := ss.findScenario(buildingID, scenarioID)
+	if err != nil {
+		return err
+	}
+	if err := ss.repo.Delete(scenario); err != nil {
+		return fmt.Errorf("failed to remove scenario %d: %w", scenarioID, err)
+	}
+	return nil
+}
+
+// OverrideAssembly overrides an assembly of the building in the scenario.
+// A replacement must not be used on the building already.
+func (ss *scenarioService) OverrideAssembly(buildingID uint, scenarioID uint, assemblyID uint, req OverrideAssemblyRequest) (*model.Scenario, error) {
+	if !req.Removed && req.ReplacementID == nil && req.Quantity == nil {
+		return nil, fmt.Errorf("an override needs a replacement, a quantity or to remove the assembly")
+	}
+	scenario, err := ss.findScenario(buildingID, scenarioID)
+	if err != nil {
+		return nil, err
+	}
+	building, err := ss.buildingRepo.EagerFindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	if !usesAssembly(building, assemblyID) {
+		return nil, fmt.Errorf("assembly %d is not used on building %d", assemblyID, buildingID)
+	}
+	if req.ReplacementID != nil {
+		if usesAssembly(building, *req.ReplacementID) {
+			return nil, fmt.Errorf("assembly %d is already used on building %d", *req.ReplacementID, buildingID)
+		}
+		if _, err := ss.assemblyRepo.FindByID(*req.ReplacementID); err != nil {
+			return nil, fmt.Errorf("failed to find assembly with ID %d: %w", *req.ReplacementID, err)
+		}
+	}
+
+	override := &model.ScenarioAssembly{
+		ScenarioID:    scenarioID,
+		AssemblyID:    assemblyID,
+		ReplacementID: req.ReplacementID,
+		Quantity:      req.Quantity,
+		Removed:       req.Removed,
+	}
+	if err := ss.repo.SaveOverride(override); err != nil {
+		return nil, fmt.Errorf("failed to override assembly %d in scenario %d: %w", assemblyID, scenarioID, err)
+	}
+	return ss.repo.FindByID(scenario.ID)
+}
+
+// RemoveOverride makes the scenario use the building's assembly as it is again.
+func (ss *scenarioService) RemoveOverride(buildingID uint, scenarioID uint, assemblyID uint) (*model.Scenario, error) {
+	if _, err := ss.findScenario(buildingID, scenarioID); err != nil {
+		return nil, err
+	}
+	if err := ss.repo.DeleteOverride(scenarioID, assemblyID); err != nil {
+		return nil, fmt.Errorf("failed to remove override of assembly %d from scenario %d: %w", assemblyID, scenarioID, err)
+	}
+	return ss.repo.FindByID(scenarioID)
+}
+
+// CompareScenarios calculates the building and the given scenarios, all of
+// them when none are given, and returns their results side by side with the
+// difference of each scenario to the building. Each result is normalised by
+// its own denominator, so a scenario changing the GFA is compared per its own
+// m2, and all of them are calculated by the methodology, the building's own
+// when none is given.
+func (ss *scenarioService) CompareScenarios(buildingID uint, scenarioIDs []uint, normalisation Normalisation, methodologyID string) (*ScenarioComparison, error) {
+	building, err := ss.buildingRepo.EagerFindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	methodology, err := resolveMethodology(methodologyID, building)
+	if err != nil {
+		return nil, err
+	}
+	building.PinMaterialVersions()
+	scenarios, err := ss.repo.EagerFindByBuildingID(buildingID, scenarioIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scenarios of building %d: %w", buildingID, err)
+	}
+	if len(scenarioIDs) > 0 && len(scenarios) != len(scenarioIDs) {
+		return nil, fmt.Errorf("not every scenario belongs to building %d: %w", buildingID, gorm.ErrRecordNotFound)
+	}
+
+	baseline, err := ss.scenarioResult(building, methodology, normalisation)
+	if err != nil {
+		return nil, err
+	}
+	comparison := &ScenarioComparison{
+		Baseline:  baseline,
+		Scenarios: make([]ScenarioResult, 0, len(scenarios)),
+	}
+	comparison.Baseline.Name = building.Name
+	for _, scenario := range scenarios {
+		// replacement materials follow the building's pins, or their latest
+		// version, without pinning the building to them. Pinning also resets
+		// the materials shared with the building before the methodology is
+		// applied to them again
+		variant := scenario.Apply(building)
+		variant.PinMaterialVersions()
+
+		result, err := ss.scenarioResult(variant, methodology, normalisation)
+		if err != nil {
+			return nil, err
+		}
+		result.ScenarioID = scenario.ID
+		result.Name = scenario.Name
+		result.Delta = newScenarioDelta(comparison.Baseline, result)
+		comparison.Scenarios = append(comparison.Scenarios, result)
+	}
+	return comparison, nil
+}
+
+// scenarioResult calculates the total, per element and per module carbon of a
+// building with pinned material versions by the methodology, normalised by its
+// denominator.
+func (ss *scenarioService) scenarioResult(building *model.Building, methodology *model.Methodology, normalisation Normalisation) (ScenarioResult, error) {
+	denominator, err := NewDenominator(building, normalisation)
+	if err != nil {
+		return ScenarioResult{}, err
+	}
+	carbon, err := ss.carbonCalcService.ComputeBuildingCarbon(building, methodology)
+	if err != nil {
+		return ScenarioResult{}, err
+	}
+	result := ScenarioResult{
+		Total:       carbon.Total,
+		ByElement:   carbon.ByElement,
+		ByModule:    carbon.ByModule,
+		Methodology: carbon.Methodology,
+	}
+	result.Normalise(denominator)
+	return result, nil
+}
+
+// findScenario fetches a scenario and checks that it belongs to the building.
+func (ss *scenarioService) findScenario(buildingID uint, scenarioID uint) (*model.Scenario, error) {
+	scenario, err := ss.repo.FindByID(scenarioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find scenario with ID %d: %w", scenarioID, err)
+	}
+	if scenario.BuildingID != buildingID {
+		return nil, fmt.Errorf("scenario %d is not on building %d", scenarioID, buildingID)
+	}
+	return scenario, nil
+}
+
+// applyScenarioRequest maps the request onto the scenario.
+func applyScenarioRequest(scenario *model.Scenario, req ScenarioRequest) {
+	scenario.Name = req.Name
+	scenario.Description = req.Description
+	scenario.FTF = req.FTF
+	scenario.GroundFloorArea = req.GroundFloorArea
+	scenario.WWR = req.WWR
+	scenario.AboveGroundFloorCount = req.AboveGroundFloorCount
+	scenario.UnderGroundFloorCount = req.UnderGroundFloorCount
+}
+
+// usesAssembly reports whether the assembly is used on the building as a whole.
+func usesAssembly(building *model.Building, assemblyID uint) bool {
+	for _, assembly := range building.Assemblies {
+		if assembly.ID == assemblyID {
+			return true
+		}
+	}
+	return false
+}
+
+// newScenarioDelta subtracts the baseline's result from the scenario's.
+func newScenarioDelta(baseline ScenarioResult, scenario ScenarioResult) *ScenarioDelta {
+	delta := &ScenarioDelta{
+		Total:     scenario.Total - baseline.Total,
+		ByElement: elementDeltas(baseline.ByElement, scenario.ByElement),
+		ByModule:  make([]ModuleCarbon, len(scenario.ByModule)),
+	}
+	for i, module := range scenario.ByModule {
+		delta.ByModule[i] = ModuleCarbon{Module: module.Module, Carbon: module.Carbon - baseline.ByModule[i].Carbon}
+	}
+	return delta
+}
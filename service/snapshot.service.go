@@ -0,0 +1,295 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SnapshotService defines the operations available for recording buildings
+// at design stages and tracking their carbon over time.
+type SnapshotService interface {
+	CreateSnapshot(buildingID uint, req CreateSnapshotRequest) (*model.Snapshot, error)
+	GetSnapshot(buildingID uint, snapshotID uint) (*model.Snapshot, error)
+	GetSnapshots(buildingID uint) ([]model.Snapshot, error)
+	GetTrajectory(buildingID uint, normalisation Normalisation) ([]TrajectoryPoint, error)
+	DiffSnapshots(buildingID uint, fromID uint, toID uint, normalisation Normalisation) (*SnapshotDiff, error)
+}
+
+// snapshotService provides a concrete implementation of the SnapshotService.
+type snapshotService struct {
+	repo              repository.SnapshotRepository
+	buildingRepo      repository.BuildingRepository // Buildings that are recorded
+	carbonCalcService CalculationService            // Dependency for carbon calculations
+}
+
+// NewSnapshotService initializes a new snapshot service with necessary dependencies.
+func NewSnapshotService(r repository.SnapshotRepository, br repository.BuildingRepository, cs CalculationService) SnapshotService {
+	return &snapshotService{
+		repo:              r,
+		buildingRepo:      br,
+		carbonCalcService: cs,
+	}
+}
+
+// CreateSnapshotRequest tags a snapshot with its RIBA stage, 0 to 7, and the
+// date it was taken, which defaults to now. Its results are calculated by the
+// methodology, the building's own when none is given.
+type CreateSnapshotRequest struct {
+	Stage       model.RibaStage `json:"stage" binding:"gte=0,lte=7"`
+	Date        *time.Time      `json:"date"`
+	Label       string          `json:"label"`
+	Methodology string          `json:"methodology"`
+}
+
+// TrajectoryPoint is the result of a building in one snapshot, normalised by
+// the denominator of the building as it was recorded and expressed in Unit,
+// calculated by the methodology recorded with the snapshot.
+type TrajectoryPoint struct {
+	SnapshotID         uint            `json:"snapshotId"`
+	Stage              model.RibaStage `json:"stage"`
+	StageName          string          `json:"stageName"`
+	Date               time.Time       `json:"date"`
+	Label              string          `json:"label"`
+	GFA                float64         `json:"gfa"`
+	TotalCarbon        float64         `json:"totalCarbon"`
+	CarbonPerArea      float64         `json:"carbonPerArea"`
+	ByElement          []ElementCarbon `json:"byElement"`
+	Normalisation      *Denominator    `json:"normalisation"`
+	Unit               string          `json:"unit"`
+	Methodology        string          `json:"methodology"`
+	MethodologyVersion string          `json:"methodologyVersion"`
+}
+
+// SnapshotDiff is what changed between two snapshots of a building. Deltas
+// are positive when the later snapshot emits more.
+type SnapshotDiff struct {
+	From       TrajectoryPoint   `json:"from"`
+	To         TrajectoryPoint   `json:"to"`
+	Total      float64           `json:"total"`
+	ByElement  []ElementCarbon   `json:"byElement"`
+	Parameters []ParameterChange `json:"parameters"`
+	Assemblies []AssemblyChange  `json:"assemblies"`
+}
+
+// ParameterChange is a building parameter that changed between two snapshots.
+type ParameterChange struct {
+	Parameter string  `json:"parameter"`
+	From      float64 `json:"from"`
+	To        float64 `json:"to"`
+}
+
+// AssemblyChange is an assembly that was added, removed or changed between two snapshots.
+type AssemblyChange struct {
+	AssemblyID   uint    `json:"assemblyId"`
+	Name         string  `json:"name"`
+	Change       string  `json:"change"`
+	FromQuantity float64 `json:"fromQuantity"`
+	ToQuantity   float64 `json:"toQuantity"`
+	FromCarbon   float64 `json:"fromCarbon"`
+	ToCarbon     float64 `json:"toCarbon"`
+	Delta        float64 `json:"delta"`
+}
+
+const (
+	assemblyAdded   = "added"
+	assemblyRemoved = "removed"
+	assemblyChanged = "changed"
+)
+
+// CreateSnapshot records the building with its current results by the
+// requested methodology. Material versions are pinned first, so the snapshot
+// matches the building's total carbon by the same methodology.
+func (ss *snapshotService) CreateSnapshot(buildingID uint, req CreateSnapshotRequest) (*model.Snapshot, error) {
+	if !req.Stage.IsValid() {
+		return nil, fmt.Errorf("unknown RIBA stage %d", req.Stage)
+	}
+	building, err := ss.buildingRepo.EagerFindByID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find building with ID %d: %w", buildingID, err)
+	}
+	methodology, err := resolveMethodology(req.Methodology, building)
+	if err != nil {
+		return nil, err
+	}
+	building.PinMaterialVersions()
+	carbon, err := ss.carbonCalcService.ComputeBuildingCarbon(building, methodology)
+	if err != nil {
+		return nil, err
+	}
+
+	date := time.Now()
+	if req.Date != nil {
+		date = *req.Date
+	}
+	snapshot, err := model.NewSnapshot(building, methodology, req.Stage, date, req.Label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record building %d: %w", buildingID, err)
+	}
+	snapshot.TotalCarbon = carbon.Total
+	for _, element := range carbon.ByElement {
+		snapshot.Elements = append(snapshot.Elements, &model.SnapshotElement{Element: element.Element, Carbon: element.Carbon})
+	}
+	if err := ss.repo.Create(snapshot); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot of building %d: %w", buildingID, err)
+	}
+	return snapshot, nil
+}
+
+// GetSnapshot fetches a snapshot of the building with its full state.
+func (ss *snapshotService) GetSnapshot(buildingID uint, snapshotID uint) (*model.Snapshot, error) {
+	snapshot, err := ss.repo.FindByID(snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find snapshot with ID %d: %w", snapshotID, err)
+	}
+	if snapshot.BuildingID != buildingID {
+		return nil, fmt.Errorf("snapshot %d is not of building %d: %w", snapshotID, buildingID, gorm.ErrRecordNotFound)
+	}
+	return snapshot, nil
+}
+
+// GetSnapshots fetches the snapshots of a building in date order, without their full state.
+func (ss *snapshotService) GetSnapshots(buildingID uint) ([]model.Snapshot, error) {
+	snapshots, err := ss.repo.FindByBuildingID(buildingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find snapshots of building %d: %w", buildingID, err)
+	}
+	return snapshots, nil
+}
+
+// GetTrajectory returns the totals and per element results of the building
+// across its snapshots in date order, each normalised by the denominator of
+// the building as it was recorded.
+func (ss *snapshotService) GetTrajectory(buildingID uint, normalisation Normalisation) ([]TrajectoryPoint, error) {
+	snapshots, err := ss.GetSnapshots(buildingID)
+	if err != nil {
+		return nil, err
+	}
+	trajectory := make([]TrajectoryPoint, len(snapshots))
+	for i := range snapshots {
+		point, err := newTrajectoryPoint(&snapshots[i], normalisation)
+		if err != nil {
+			return nil, err
+		}
+		trajectory[i] = point
+	}
+	return trajectory, nil
+}
+
+// DiffSnapshots compares two snapshots of the building: their results, the
+// building parameters and the assemblies that changed. Results are normalised
+// by the denominator of each snapshot before they are compared.
+func (ss *snapshotService) DiffSnapshots(buildingID uint, fromID uint, toID uint, normalisation Normalisation) (*SnapshotDiff, error) {
+	from, err := ss.GetSnapshot(buildingID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := ss.GetSnapshot(buildingID, toID)
+	if err != nil {
+		return nil, err
+	}
+	fromPoint, err := newTrajectoryPoint(from, normalisation)
+	if err != nil {
+		return nil, err
+	}
+	toPoint, err := newTrajectoryPoint(to, normalisation)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{
+		From:       fromPoint,
+		To:         toPoint,
+		Total:      toPoint.TotalCarbon - fromPoint.TotalCarbon,
+		Parameters: []ParameterChange{},
+		Assemblies: []AssemblyChange{},
+	}
+	diff.ByElement = elementDeltas(diff.From.ByElement, diff.To.ByElement)
+
+	parameters := []ParameterChange{
+		{Parameter: "gfa", From: from.GFA, To: to.GFA},
+		{Parameter: "ftf", From: from.FTF, To: to.FTF},
+		{Parameter: "groundFloorArea", From: from.GroundFloorArea, To: to.GroundFloorArea},
+		{Parameter: "wwr", From: from.WWR, To: to.WWR},
+		{Parameter: "aboveGroundFloorCount", From: float64(from.AboveGroundFloorCount), To: float64(to.AboveGroundFloorCount)},
+		{Parameter: "underGroundFloorCount", From: float64(from.UnderGroundFloorCount), To: float64(to.UnderGroundFloorCount)},
+	}
+	for _, parameter := range parameters {
+		if parameter.From != parameter.To {
+			diff.Parameters = append(diff.Parameters, parameter)
+		}
+	}
+
+	for _, before := range from.Assemblies {
+		after := to.FindAssembly(before.AssemblyID)
+		if after == nil {
+			diff.Assemblies = append(diff.Assemblies, newAssemblyChange(assemblyRemoved, before, &model.SnapshotAssembly{}))
+		} else if after.Quantity != before.Quantity || after.Carbon != before.Carbon {
+			diff.Assemblies = append(diff.Assemblies, newAssemblyChange(assemblyChanged, before, after))
+		}
+	}
+	for _, after := range to.Assemblies {
+		if from.FindAssembly(after.AssemblyID) == nil {
+			diff.Assemblies = append(diff.Assemblies, newAssemblyChange(assemblyAdded, &model.SnapshotAssembly{}, after))
+		}
+	}
+	for i := range diff.Assemblies {
+		change := &diff.Assemblies[i]
+		change.FromCarbon /= fromPoint.Normalisation.Value
+		change.ToCarbon /= toPoint.Normalisation.Value
+		change.Delta = change.ToCarbon - change.FromCarbon
+	}
+	return diff, nil
+}
+
+// newTrajectoryPoint summarises the results of a snapshot normalised by the
+// denominator of the building as it was recorded.
+func newTrajectoryPoint(snapshot *model.Snapshot, normalisation Normalisation) (TrajectoryPoint, error) {
+	denominator, err := NewDenominator(snapshot.Parameters(), normalisation)
+	if err != nil {
+		return TrajectoryPoint{}, fmt.Errorf("failed to normalise snapshot %d: %w", snapshot.ID, err)
+	}
+	point := TrajectoryPoint{
+		SnapshotID:         snapshot.ID,
+		Stage:              snapshot.Stage,
+		StageName:          snapshot.Stage.Name(),
+		Date:               snapshot.Date,
+		Label:              snapshot.Label,
+		GFA:                snapshot.GFA,
+		TotalCarbon:        snapshot.TotalCarbon,
+		CarbonPerArea:      perArea(snapshot.TotalCarbon, snapshot.GFA),
+		ByElement:          make([]ElementCarbon, len(snapshot.Elements)),
+		Methodology:        snapshot.Methodology,
+		MethodologyVersion: snapshot.MethodologyVersion,
+	}
+	for i, element := range snapshot.Elements {
+		point.ByElement[i] = ElementCarbon{Element: element.Element, Carbon: element.Carbon}
+	}
+	point.Normalise(denominator)
+	return point, nil
+}
+
+// newAssemblyChange describes how an assembly changed between two snapshots.
+func newAssemblyChange(change string, before *model.SnapshotAssembly, after *model.SnapshotAssembly) AssemblyChange {
+	name := after.Name
+	if name == "" {
+		name = before.Name
+	}
+	assemblyID := after.AssemblyID
+	if assemblyID == 0 {
+		assemblyID = before.AssemblyID
+	}
+	return AssemblyChange{
+		AssemblyID:   assemblyID,
+		Name:         name,
+		Change:       change,
+		FromQuantity: before.Quantity,
+		ToQuantity:   after.Quantity,
+		FromCarbon:   before.Carbon,
+		ToCarbon:     after.Carbon,
+		Delta:        after.Carbon - before.Carbon,
+	}
+}
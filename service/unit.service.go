@@ -0,0 +1,484 @@
+package service
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service/converter"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// UnitService defines the operations available for managing the unit system
+// preferences of users and projects.
+type UnitService interface {
+	SetPreference(ownerType string, ownerID string, req UnitPreferenceRequest) (*model.Unit, error)
+	GetPreference(ownerType string, ownerID string) (*model.Unit, error)
+	ResolveUnit(projectID string, userID string) (model.Unit, error)
+}
+
+// unitService provides a concrete implementation of the UnitService.
+type unitService struct {
+	repo repository.UnitRepository
+}
+
+// NewUnitService initializes a new unit service with necessary dependencies.
+func NewUnitService(r repository.UnitRepository) UnitService {
+	return &unitService{
+		repo: r,
+	}
+}
+
+type UnitPreferenceRequest struct {
+	System model.UnitSystem `json:"system" binding:"required"`
+}
+
+// SetPreference stores the unit system of a user or project, replacing its previous one.
+func (us *unitService) SetPreference(ownerType string, ownerID string, req UnitPreferenceRequest) (*model.Unit, error) {
+	if ownerType != model.UnitOwnerUser && ownerType != model.UnitOwnerProject {
+		return nil, fmt.Errorf("unknown unit preference owner '%s'", ownerType)
+	}
+	if !req.System.IsValid() {
+		return nil, fmt.Errorf("unknown unit system '%s'", req.System)
+	}
+
+	unit := model.NewUnit(req.System)
+	if existing, err := us.repo.FindByOwner(ownerType, ownerID); err == nil {
+		unit.Model = existing.Model
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to find unit preference of %s %s: %w", ownerType, ownerID, err)
+	}
+	unit.OwnerType = ownerType
+	unit.OwnerID = ownerID
+	if err := us.repo.Save(&unit); err != nil {
+		return nil, fmt.Errorf("failed to save unit preference of %s %s: %w", ownerType, ownerID, err)
+	}
+	return &unit, nil
+}
+
+// GetPreference fetches the unit system of a user or project.
+func (us *unitService) GetPreference(ownerType string, ownerID string) (*model.Unit, error) {
+	unit, err := us.repo.FindByOwner(ownerType, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unit preference of %s %s: %w", ownerType, ownerID, err)
+	}
+	return unit, nil
+}
+
+// ResolveUnit returns the unit system of the project, or else of the user,
+// and metric when neither has a preference.
+func (us *unitService) ResolveUnit(projectID string, userID string) (model.Unit, error) {
+	if projectID == "" && userID == "" {
+		return model.NewUnit(model.UnitSystemMetric), nil
+	}
+	units, err := us.repo.FindByProjectOrUser(projectID, userID)
+	if err != nil {
+		return model.Unit{}, fmt.Errorf("failed to find unit preferences of project %s and user %s: %w", projectID, userID, err)
+	}
+	for _, ownerType := range []string{model.UnitOwnerProject, model.UnitOwnerUser} {
+		for _, unit := range units {
+			if unit.OwnerType == ownerType {
+				return unit, nil
+			}
+		}
+	}
+	return model.NewUnit(model.UnitSystemMetric), nil
+}
+
+// convertValues converts the values in place from one unit into another.
+func convertValues(from string, to string, values ...*float64) error {
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		converted, err := converter.Convert(*value, from, to)
+		if err != nil {
+			return err
+		}
+		*value = converted
+	}
+	return nil
+}
+
+// ToMetric converts the geometry of the request from the unit system it was sent in.
+func (req *CreateBuildingRequest) ToMetric(unit model.Unit) error {
+	if err := convertValues(unit.Length, "m", &req.FTF); err != nil {
+		return err
+	}
+	return convertValues(unit.Area, "m2", &req.GroundFloorArea, &req.GIA)
+}
+
+// ToMetric converts the geometry of the request from the unit system it was sent in.
+func (req *UpdateBuildingRequest) ToMetric(unit model.Unit) error {
+	if err := convertValues(unit.Length, "m", req.FTF); err != nil {
+		return err
+	}
+	return convertValues(unit.Area, "m2", req.GroundFloorArea, req.GIA)
+}
+
+// ToMetric converts the geometry of the request from the unit system it was sent in.
+func (req *BuildingFromArchetypeRequest) ToMetric(unit model.Unit) error {
+	if err := convertValues(unit.Length, "m", req.FTF); err != nil {
+		return err
+	}
+	return convertValues(unit.Area, "m2", &req.GFA)
+}
+
+// ToMetric converts the geometry of the request from the unit system it was sent in.
+func (req *FloorRequest) ToMetric(unit model.Unit) error {
+	if err := convertValues(unit.Length, "m", &req.Height); err != nil {
+		return err
+	}
+	return convertValues(unit.Area, "m2", &req.Area)
+}
+
+// ToMetric converts the area of the request from the unit system it was sent in.
+func (req *ZoneRequest) ToMetric(unit model.Unit) error {
+	return convertValues(unit.Area, "m2", &req.Area)
+}
+
+// BuildingInUnit returns a copy of the building with its geometry, and that
+// of its floors and zones, in the unit system. The building is not modified.
+func BuildingInUnit(building *model.Building, unit model.Unit) (*model.Building, error) {
+	b := *building
+	if err := convertValues("m", unit.Length, &b.FTF); err != nil {
+		return nil, err
+	}
+	if err := convertValues("m2", unit.Area, &b.GFA, &b.GroundFloorArea, &b.FacadeArea,
+		&b.GlazingArea, &b.CladdingArea, &b.RoofArea, &b.GIA); err != nil {
+		return nil, err
+	}
+	if building.Floors == nil {
+		return &b, nil
+	}
+	b.Floors = make([]*model.Floor, len(building.Floors))
+	for i, floor := range building.Floors {
+		f, err := FloorInUnit(floor, unit)
+		if err != nil {
+			return nil, err
+		}
+		b.Floors[i] = f
+	}
+	return &b, nil
+}
+
+// FloorInUnit returns a copy of the floor with its geometry, and that of its
+// zones, in the unit system. The floor is not modified.
+func FloorInUnit(floor *model.Floor, unit model.Unit) (*model.Floor, error) {
+	f := *floor
+	if err := convertValues("m", unit.Length, &f.Height); err != nil {
+		return nil, err
+	}
+	if err := convertValues("m2", unit.Area, &f.Area); err != nil {
+		return nil, err
+	}
+	if floor.Zones == nil {
+		return &f, nil
+	}
+	f.Zones = make([]*model.Zone, len(floor.Zones))
+	for i, zone := range floor.Zones {
+		z, err := ZoneInUnit(zone, unit)
+		if err != nil {
+			return nil, err
+		}
+		f.Zones[i] = z
+	}
+	return &f, nil
+}
+
+// ZoneInUnit returns a copy of the zone with its area in the unit system.
+func ZoneInUnit(zone *model.Zone, unit model.Unit) (*model.Zone, error) {
+	z := *zone
+	if err := convertValues("m2", unit.Area, &z.Area); err != nil {
+		return nil, err
+	}
+	return &z, nil
+}
+
+// OutputUnitFor returns the output unit of carbon results in the unit system,
+// per area when the results are normalised by an area.
+func OutputUnitFor(unit model.Unit, normalisation Normalisation) converter.OutputUnit {
+	if normalisation.PerArea() {
+		return converter.OutputUnit(unit.CarbonPerArea)
+	}
+	return converter.OutputUnit(unit.Carbon)
+}
+
+// QuantityUnitFor returns the unit of the unit system for quantities in the
+// dimension of the declared unit, e.g. "kBtu" for a material declared per kWh
+// in imperial.
+func QuantityUnitFor(unit model.Unit, declaredUnit string) (string, error) {
+	dimension, err := converter.QuantityDimension(declaredUnit)
+	if err != nil {
+		return "", err
+	}
+	switch dimension {
+	case converter.DimensionMass:
+		return unit.Mass, nil
+	case converter.DimensionVolume:
+		return unit.Volume, nil
+	case converter.DimensionArea:
+		return unit.Area, nil
+	case converter.DimensionEnergy:
+		return unit.Energy, nil
+	default:
+		return declaredUnit, nil
+	}
+}
+
+// intensityUnit returns the unit intensities per m2 of floor area are
+// presented in: the output unit when it is per area, or else its carbon unit
+// per the area unit of the unit system, e.g. "lbCO2e/ft2".
+func intensityUnit(scale converter.OutputScale, unit model.Unit) string {
+	if scale.PerArea() {
+		return scale.Carbon + "/" + scale.Area
+	}
+	return scale.Carbon + "/" + unit.Area
+}
+
+// ToMetric converts the geometry of the request from the unit system it was sent in.
+func (req *ScenarioRequest) ToMetric(unit model.Unit) error {
+	if err := convertValues(unit.Length, "m", req.FTF); err != nil {
+		return err
+	}
+	return convertValues(unit.Area, "m2", req.GroundFloorArea)
+}
+
+// ScenarioInUnit returns a copy of the scenario with the geometry it
+// overrides in the unit system. The scenario is not modified.
+func ScenarioInUnit(scenario *model.Scenario, unit model.Unit) (*model.Scenario, error) {
+	s := *scenario
+	if scenario.FTF != nil {
+		ftf := *scenario.FTF
+		s.FTF = &ftf
+	}
+	if scenario.GroundFloorArea != nil {
+		area := *scenario.GroundFloorArea
+		s.GroundFloorArea = &area
+	}
+	if err := convertValues("m", unit.Length, s.FTF); err != nil {
+		return nil, err
+	}
+	if err := convertValues("m2", unit.Area, s.GroundFloorArea); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Present converts the normalised carbon of the estimate into the output unit,
+// and its quantities and factors into the unit system.
+func (r *SubstructureResult) Present(output converter.OutputUnit, unit model.Unit) error {
+	scale, perArea, label, err := resultScale(output, r.Normalisation)
+	if err != nil {
+		return err
+	}
+	for _, carbon := range []*float64{&r.RetainingWallCarbon, &r.BasementSlabCarbon, &r.FoundationCarbon, &r.ExcavationCarbon, &r.TotalCarbon} {
+		*carbon = scale.Convert(*carbon, perArea)
+	}
+	if err := convertValues("m2", unit.Area, &r.RetainingWallArea, &r.BasementSlabArea, &r.FoundationArea); err != nil {
+		return err
+	}
+	if err := convertValues("m3", unit.Volume, &r.ExcavationVolume); err != nil {
+		return err
+	}
+	if err := convertValues("kgCO2e/m2", scale.Carbon+"/"+unit.Area, r.Factors.RetainingWall, r.Factors.BasementSlab, r.Factors.Foundation); err != nil {
+		return err
+	}
+	if err := convertValues("kgCO2e/m3", scale.Carbon+"/"+unit.Volume, r.Factors.Excavation); err != nil {
+		return err
+	}
+	r.Unit = label
+	return nil
+}
+
+// Present converts the normalised results of the building and its scenarios,
+// and their differences, into the output unit.
+func (c *ScenarioComparison) Present(output converter.OutputUnit) error {
+	if err := c.Baseline.Present(output); err != nil {
+		return err
+	}
+	for i := range c.Scenarios {
+		if err := c.Scenarios[i].Present(output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Present converts the normalised result, and its difference to the baseline,
+// into the output unit.
+func (r *ScenarioResult) Present(output converter.OutputUnit) error {
+	scale, perArea, label, err := resultScale(output, r.Normalisation)
+	if err != nil {
+		return err
+	}
+	r.Total = scale.Convert(r.Total, perArea)
+	convertElements(r.ByElement, scale, perArea)
+	convertModules(r.ByModule, scale, perArea)
+	if r.Delta != nil {
+		r.Delta.Total = scale.Convert(r.Delta.Total, perArea)
+		convertElements(r.Delta.ByElement, scale, perArea)
+		convertModules(r.Delta.ByModule, scale, perArea)
+	}
+	r.Unit = label
+	return nil
+}
+
+// Present converts the normalised carbon of the snapshot into the output unit,
+// and its GFA and carbon per m2 GFA into the unit system.
+func (p *TrajectoryPoint) Present(output converter.OutputUnit, unit model.Unit) error {
+	scale, perArea, label, err := resultScale(output, p.Normalisation)
+	if err != nil {
+		return err
+	}
+	p.TotalCarbon = scale.Convert(p.TotalCarbon, perArea)
+	convertElements(p.ByElement, scale, perArea)
+	if err := convertValues("m2", unit.Area, &p.GFA); err != nil {
+		return err
+	}
+	if err := convertValues("kgCO2e/m2", intensityUnit(scale, unit), &p.CarbonPerArea); err != nil {
+		return err
+	}
+	p.Unit = label
+	return nil
+}
+
+// Present converts the snapshots, the differences of their normalised results
+// and the geometry parameters that changed into the output unit and unit system.
+func (d *SnapshotDiff) Present(output converter.OutputUnit, unit model.Unit) error {
+	scale, perArea, _, err := resultScale(output, d.To.Normalisation)
+	if err != nil {
+		return err
+	}
+	if err := d.From.Present(output, unit); err != nil {
+		return err
+	}
+	if err := d.To.Present(output, unit); err != nil {
+		return err
+	}
+	d.Total = scale.Convert(d.Total, perArea)
+	convertElements(d.ByElement, scale, perArea)
+	for i := range d.Assemblies {
+		change := &d.Assemblies[i]
+		change.FromCarbon = scale.Convert(change.FromCarbon, perArea)
+		change.ToCarbon = scale.Convert(change.ToCarbon, perArea)
+		change.Delta = scale.Convert(change.Delta, perArea)
+	}
+	for i := range d.Parameters {
+		parameter := &d.Parameters[i]
+		switch parameter.Parameter {
+		case "gfa", "groundFloorArea":
+			err = convertValues("m2", unit.Area, &parameter.From, &parameter.To)
+		case "ftf":
+			err = convertValues("m", unit.Length, &parameter.From, &parameter.To)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Present converts the GFA into the unit system, and the carbon, carbon per
+// m2, limits and distances of every rating into its carbon units.
+func (c *BenchmarkComparison) Present(unit model.Unit) error {
+	if err := convertValues("m2", unit.Area, &c.Area); err != nil {
+		return err
+	}
+	for i := range c.Ratings {
+		rating := &c.Ratings[i]
+		if err := convertValues("kgCO2e", unit.Carbon, &rating.Carbon); err != nil {
+			return err
+		}
+		if err := convertValues("kgCO2e/m2", unit.CarbonPerArea, &rating.CarbonPerArea); err != nil {
+			return err
+		}
+		for j := range rating.Targets {
+			target := &rating.Targets[j]
+			if err := convertValues("kgCO2e/m2", unit.CarbonPerArea, &target.Limit, &target.Distance); err != nil {
+				return err
+			}
+		}
+	}
+	c.Unit = unit.CarbonPerArea
+	return nil
+}
+
+// Present converts the carbon of the analysis and of every contributor into
+// the output unit, which cannot be per area.
+func (a *HotspotAnalysis) Present(output converter.OutputUnit) error {
+	scale, perArea, label, err := resultScale(output, nil)
+	if err != nil {
+		return err
+	}
+	a.Total = scale.Convert(a.Total, perArea)
+	for _, hotspots := range [][]Hotspot{a.Elements, a.Assemblies, a.Materials} {
+		for i := range hotspots {
+			hotspots[i].Carbon = scale.Convert(hotspots[i].Carbon, perArea)
+		}
+	}
+	a.Unit = label
+	return nil
+}
+
+// Present converts the normalised carbon of the building and its floors into
+// the output unit, and the floor areas and intensities into the unit system.
+func (r *FloorCarbonResult) Present(output converter.OutputUnit, unit model.Unit) error {
+	scale, perArea, label, err := resultScale(output, r.Normalisation)
+	if err != nil {
+		return err
+	}
+	r.Total = scale.Convert(r.Total, perArea)
+	r.Shared = scale.Convert(r.Shared, perArea)
+	for i := range r.Floors {
+		floor := &r.Floors[i]
+		floor.Carbon = scale.Convert(floor.Carbon, perArea)
+		convertElements(floor.ByElement, scale, perArea)
+		if err := convertValues("m2", unit.Area, &floor.Area); err != nil {
+			return err
+		}
+		if err := convertValues("kgCO2e/m2", intensityUnit(scale, unit), &floor.CarbonPerArea); err != nil {
+			return err
+		}
+	}
+	r.Unit = label
+	return nil
+}
+
+// Present converts the normalised carbon of the building and its use classes
+// into the output unit, and their areas and intensities into the unit system.
+func (r *UseCarbonResult) Present(output converter.OutputUnit, unit model.Unit) error {
+	scale, perArea, label, err := resultScale(output, r.Normalisation)
+	if err != nil {
+		return err
+	}
+	r.Total = scale.Convert(r.Total, perArea)
+	r.Shared = scale.Convert(r.Shared, perArea)
+	for i := range r.ByUse {
+		use := &r.ByUse[i]
+		use.Carbon = scale.Convert(use.Carbon, perArea)
+		if err := convertValues("m2", unit.Area, &use.Area); err != nil {
+			return err
+		}
+		if err := convertValues("kgCO2e/m2", intensityUnit(scale, unit), &use.CarbonPerArea); err != nil {
+			return err
+		}
+	}
+	r.Unit = label
+	return nil
+}
+
+// convertElements scales the carbon of every element into the output unit.
+func convertElements(elements []ElementCarbon, scale converter.OutputScale, perArea bool) {
+	for i := range elements {
+		elements[i].Carbon = scale.Convert(elements[i].Carbon, perArea)
+	}
+}
+
+// convertModules scales the carbon of every module into the output unit.
+func convertModules(modules []ModuleCarbon, scale converter.OutputScale, perArea bool) {
+	for i := range modules {
+		modules[i].Carbon = scale.Convert(modules[i].Carbon, perArea)
+	}
+}
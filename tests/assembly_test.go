@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestNestedAssemblyCarbon tests that assembly carbon recurses through
+// material quantities, layers and sub-assemblies.
+func TestNestedAssemblyCarbon(t *testing.T) {
+	glass := newVersionedMaterial(1, 2)
+	aluminium := newVersionedMaterial(2, 10)
+
+	// one glazing unit is 1.5 m2 of glass with a 0.5 kg/m2 aluminium spacer layer
+	area := 1.5
+	glazing := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		Area:          &area,
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: glass, DeclaredQuantity: 1.5}},
+		Layers:        []*model.AssemblyLayer{{MaterialID: 2, Material: aluminium, DeclaredQuantity: 0.5}},
+	}
+	window := &model.Assembly{
+		Model:      gorm.Model{ID: 11},
+		Components: []*model.AssemblyComponent{{ParentID: 11, ChildID: 10, Child: glazing, Quantity: 2}},
+	}
+	wall := &model.Assembly{
+		Model:      gorm.Model{ID: 12},
+		Components: []*model.AssemblyComponent{{ParentID: 12, ChildID: 11, Child: window, Quantity: 3}},
+	}
+	wall.UseLatestVersions()
+
+	// glazing: 1.5 x 2 + 1.5 x 0.5 x 10 = 10.5, wall: 3 x 2 x 10.5 = 63
+	assert.Equal(t, 10.5, glazing.ComputeWholeLifeCarbon())
+	assert.Equal(t, 63.0, wall.ComputeWholeLifeCarbon())
+	assert.Equal(t, 63.0, wall.CalculateCarbonForPhase("A1toA5"))
+	assert.Equal(t, 18.0, wall.ComputeMaterialCarbon(1))
+	assert.Equal(t, 45.0, wall.ComputeMaterialCarbon(2))
+
+	// the wall contains the glazing, so adding the wall to the glazing is a cycle
+	assert.True(t, wall.Contains(glazing.ID))
+	assert.False(t, glazing.Contains(wall.ID))
+}
+
+// TestLayeredAssemblyCarbon tests that the per m2 carbon of layers is scaled
+// by the m2 of layers in one unit of the assembly before it is added to the
+// carbon of its material quantities.
+func TestLayeredAssemblyCarbon(t *testing.T) {
+	clt := newVersionedMaterial(1, 100)
+	insulation := newVersionedMaterial(2, 3)
+	fixings := newVersionedMaterial(3, 2)
+
+	// a 2.5 m2 panel of 0.2 m3/m2 CLT and 2 kg/m2 insulation, held by 4 kg of fixings
+	area := 2.5
+	panel := &model.Assembly{
+		Model:         gorm.Model{ID: 20},
+		Area:          &area,
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 3, Material: fixings, DeclaredQuantity: 4}},
+		Layers: []*model.AssemblyLayer{
+			{MaterialID: 1, Material: clt, Position: 0, DeclaredQuantity: 0.2},
+			{MaterialID: 2, Material: insulation, Position: 1, DeclaredQuantity: 2},
+		},
+	}
+	panel.UseLatestVersions()
+
+	// layers: 0.2 x 100 + 2 x 3 = 26 per m2, panel: 4 x 2 + 2.5 x 26 = 73
+	assert.Equal(t, 26.0, panel.ComputeCarbonPerArea())
+	assert.Equal(t, 73.0, panel.ComputeWholeLifeCarbon())
+	assert.Equal(t, 73.0, panel.CalculateCarbonForPhase("A1toA5"))
+	assert.Equal(t, 50.0, panel.ComputeMaterialCarbon(1))
+
+	building := &model.Building{
+		Assemblies:    []*model.Assembly{panel},
+		AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 20, Quantity: 10}},
+	}
+	assert.Equal(t, 730.0, building.ComputeWholeLifeCarbon())
+
+	// without an area one unit of the assembly is one m2 of its layers
+	panel.Area = nil
+	assert.Equal(t, 34.0, panel.ComputeWholeLifeCarbon())
+}
+
+// fakeAssemblyRepository serves a single assembly and records its totals.
+type fakeAssemblyRepository struct {
+	repository.AssemblyRepository
+	assembly *model.Assembly
+	totals   []float64
+}
+
+func (r *fakeAssemblyRepository) FindByID(id uint) (*model.Assembly, error) {
+	return r.EagerFindByID(id)
+}
+
+func (r *fakeAssemblyRepository) EagerFindByID(id uint) (*model.Assembly, error) {
+	if r.assembly.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.assembly, nil
+}
+
+func (r *fakeAssemblyRepository) SaveMaterialLink(link *model.AssemblyMaterial) error {
+	return nil
+}
+
+func (r *fakeAssemblyRepository) UpdateTotals(assembly *model.Assembly) error {
+	r.totals = append(r.totals, assembly.TotalCarbon)
+	return nil
+}
+
+func (r *fakeAssemblyRepository) FindParentIDs(childID uint) ([]uint, error) {
+	return nil, nil
+}
+
+// fakeMaterialFinder finds materials by ID.
+type fakeMaterialFinder struct {
+	repository.MaterialRepository
+	materials map[uint]*model.Material
+}
+
+func (r *fakeMaterialFinder) FindByID(id uint) (*model.Material, error) {
+	material, ok := r.materials[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return material, nil
+}
+
+// pinnedBuildingRepository has one building using every assembly and counts
+// how often its materials are pinned.
+type pinnedBuildingRepository struct {
+	repository.BuildingRepository
+	pinned int
+}
+
+func (r *pinnedBuildingRepository) FindIDsByAssembly(assemblyID uint) ([]uint, error) {
+	return []uint{7}, nil
+}
+
+func (r *pinnedBuildingRepository) PinMaterials(buildingIDs []uint) error {
+	r.pinned++
+	return nil
+}
+
+// TestRecomputeTotalsUsesLatestVersions tests that the cached totals of an
+// assembly are recalculated with the latest versions of its materials, like
+// its own total, whatever versions its buildings are pinned to, and that the
+// buildings are pinned before the assembly changes.
+func TestRecomputeTotalsUsesLatestVersions(t *testing.T) {
+	steel := newVersionedMaterial(1, 10, 12)
+	steel.DeclaredUnit = "kg"
+	timber := newVersionedMaterial(2, 5, 4)
+	timber.DeclaredUnit = "kg"
+	assemblies := &fakeAssemblyRepository{assembly: &model.Assembly{
+		Model: gorm.Model{ID: 3},
+		MaterialLinks: []*model.AssemblyMaterial{
+			{AssemblyID: 3, MaterialID: 1, Material: steel, Quantity: 1, Unit: "kg", DeclaredQuantity: 1},
+			{AssemblyID: 3, MaterialID: 2, Material: timber, Quantity: 2, Unit: "kg", DeclaredQuantity: 2},
+		},
+	}}
+	buildings := &pinnedBuildingRepository{}
+	assemblyService := service.NewAssemblyService(assemblies,
+		&fakeMaterialFinder{materials: map[uint]*model.Material{1: steel, 2: timber}},
+		buildings, service.NewCalculationService())
+
+	assembly, err := assemblyService.UpdateMaterialQuantity(3, 1, service.MaterialQuantityRequest{Quantity: 3, Unit: "kg"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, buildings.pinned)
+	// 3 kg of steel at its latest 12, 2 kg of timber at its latest 4
+	assert.Equal(t, 44.0, assembly.TotalCarbon)
+	assert.Equal(t, []float64{44}, assemblies.totals)
+
+	total, err := assemblyService.ComputeTotalCarbon(3)
+	assert.NoError(t, err)
+	assert.Equal(t, assembly.TotalCarbon, total)
+}
+
+// newTemplate creates a template with a material link, two layers and a sub-assembly.
+func newTemplate() *model.Assembly {
+	return &model.Assembly{
+		Model:         gorm.Model{ID: 30},
+		IsTemplate:    true,
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Quantity: 4, Unit: "kg", DeclaredQuantity: 4}},
+		Layers: []*model.AssemblyLayer{
+			{Position: 0, MaterialID: 2, Thickness: 0.2, DeclaredQuantity: 0.2},
+			{Position: 1, MaterialID: 3, Rate: 2, RateUnit: "kg", DeclaredQuantity: 2},
+		},
+		Components: []*model.AssemblyComponent{{ParentID: 30, ChildID: 10, Quantity: 2}},
+	}
+}
+
+// TestCloneDrift tests that a clone matches its template until its material
+// links, layers or sub-assemblies are edited.
+func TestCloneDrift(t *testing.T) {
+	template := newTemplate()
+	clone := template.Clone("Project wall")
+	assert.Equal(t, uint(30), *clone.TemplateID)
+	assert.False(t, clone.DriftedFrom(template))
+
+	edits := map[string]func(clone *model.Assembly){
+		"material quantity": func(clone *model.Assembly) { clone.MaterialLinks[0].DeclaredQuantity = 5 },
+		"layer thickness":   func(clone *model.Assembly) { clone.Layers[0].Thickness = 0.25 },
+		"layer quantity":    func(clone *model.Assembly) { clone.Layers[1].DeclaredQuantity = 3 },
+		"layer material":    func(clone *model.Assembly) { clone.Layers[1].MaterialID = 4 },
+		"layer removed":     func(clone *model.Assembly) { clone.Layers = clone.Layers[:1] },
+		"layer area":        func(clone *model.Assembly) { area := 2.0; clone.Area = &area },
+		"component":         func(clone *model.Assembly) { clone.Components[0].Quantity = 3 },
+	}
+	for name, edit := range edits {
+		clone := template.Clone("Project wall")
+		edit(clone)
+		assert.True(t, clone.DriftedFrom(template), name)
+	}
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestModuleScope tests parsing module scopes into individual modules and
+// calculating carbon over them.
+func TestModuleScope(t *testing.T) {
+	modules, err := model.ScopeEmbodied.Modules()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A1", "A2", "A3", "A4", "A5", "B1", "B2", "B3", "B4", "B5", "C1", "C2", "C3", "C4"}, modules)
+
+	_, err = model.ModuleScope("C4-A1").Modules()
+	assert.Error(t, err)
+
+	material := &model.Material{Indicator: model.Gwp{A1: 100, A4: 10, B6: 50, C3: 5, D: -20}}
+	assert.Equal(t, 110.0, material.CalculateCarbonForPhase("A1", "A2", "A3", "A4", "A5"))
+	assert.Equal(t, -20.0, material.CalculateCarbonForPhase("D"))
+	assert.Equal(t, 110.0, material.CalculateCarbonForPhase(model.ModuleA1toA5))
+}
+
+// TestBenchmarkRating tests that a building is rated in the strictest band it meets.
+func TestBenchmarkRating(t *testing.T) {
+	set := &model.BenchmarkSet{Benchmarks: []*model.Benchmark{
+		{Typology: model.UseOffice, Scope: model.ScopeUpfront, Band: "B", Limit: 475},
+		{Typology: model.UseOffice, Scope: model.ScopeUpfront, Band: "A", Limit: 350},
+		{Typology: model.UseOffice, Scope: model.ScopeUpfront, Band: "C", Limit: 600},
+		{Typology: model.UseResidential, Scope: model.ScopeUpfront, Band: "A", Limit: 300},
+	}}
+
+	benchmarks := set.BenchmarksFor(model.UseOffice)[model.ScopeUpfront]
+	assert.Len(t, benchmarks, 3)
+	assert.Equal(t, "B", model.Rate(benchmarks, 400).Band)
+	assert.Equal(t, "A", model.Rate(benchmarks, 350).Band)
+	assert.Nil(t, model.Rate(benchmarks, 700))
+}
+
+// fakeBenchmarkRepository serves fixed benchmark sets.
+type fakeBenchmarkRepository struct {
+	repository.BenchmarkRepository
+	sets []model.BenchmarkSet
+}
+
+func (r *fakeBenchmarkRepository) FindAll() ([]model.BenchmarkSet, error) {
+	return r.sets, nil
+}
+
+// TestRateBuildingPerGIA tests that buildings are rated per m2 of GIA like the
+// benchmarks, and per m2 of GFA with a warning when they have no GIA.
+func TestRateBuildingPerGIA(t *testing.T) {
+	frame := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 300), DeclaredQuantity: 1}},
+	}
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		Typology:              model.UseOffice,
+		FTF:                   4,
+		GroundFloorArea:       200,
+		AboveGroundFloorCount: 3,
+		GIA:                   500,
+		Assemblies:            []*model.Assembly{frame},
+		AssemblyLinks:         []*model.BuildingAssembly{{BuildingID: 1, AssemblyID: 10, Quantity: 1000}},
+	}
+	sets := &fakeBenchmarkRepository{sets: []model.BenchmarkSet{{
+		Name: "LETI",
+		Benchmarks: []*model.Benchmark{
+			{Typology: model.UseOffice, Scope: model.ScopeUpfront, Band: "A", Limit: 550},
+			{Typology: model.UseOffice, Scope: model.ScopeUpfront, Band: "B", Limit: 650},
+		},
+	}}}
+	bms := service.NewBenchmarkService(sets, &foundBuildingRepository{building: building}, service.NewCalculationService())
+
+	comparison, err := bms.RateBuilding(1, "", service.MethodologyISO21930)
+	assert.NoError(t, err)
+	assert.Equal(t, 500.0, comparison.Area)
+	assert.Equal(t, service.NormalisePerGIA, comparison.AreaBasis)
+	assert.Empty(t, comparison.Warnings)
+	assert.InDelta(t, 600.0, comparison.Ratings[0].CarbonPerArea, 1e-9)
+	assert.Equal(t, "B", comparison.Ratings[0].Band)
+
+	building.GIA = 0
+	comparison, err = bms.RateBuilding(1, "", service.MethodologyISO21930)
+	assert.NoError(t, err)
+	assert.Equal(t, 600.0, comparison.Area)
+	assert.Equal(t, service.NormalisePerGFA, comparison.AreaBasis)
+	assert.Len(t, comparison.Warnings, 1)
+	assert.InDelta(t, 500.0, comparison.Ratings[0].CarbonPerArea, 1e-9)
+	assert.Equal(t, "A", comparison.Ratings[0].Band)
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestBuildingBreakdown tests that the breakdown adds up to the building's
+// whole life carbon and traces every material to the version it used.
+func TestBuildingBreakdown(t *testing.T) {
+	concrete := &model.Material{
+		Model:        gorm.Model{ID: 1},
+		Name:         "Concrete",
+		DeclaredUnit: "m3",
+		Versions: []*model.MaterialVersion{{
+			Model:     gorm.Model{ID: 11},
+			Version:   2,
+			EpdSource: "EPD-123",
+			Gwp:       model.Gwp{MaterialVersionID: 11, A1: 200, A4: 10, C3: 5, D: -20},
+		}},
+	}
+	slab := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		Name:          "Slab",
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: concrete, DeclaredQuantity: 0.25}},
+	}
+	building := &model.Building{
+		Model:         gorm.Model{ID: 1},
+		Assemblies:    []*model.Assembly{slab},
+		AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Element: model.ElementUpperFloors, Quantity: 100}},
+	}
+	building.PinMaterialVersions()
+
+	root := building.Breakdown()
+	assert.InDelta(t, building.ComputeWholeLifeCarbon(), root.Subtotal, 1e-9)
+	assert.InDelta(t, 5375.0, root.Subtotal, 1e-9)
+
+	element := root.Children[0]
+	assert.Equal(t, string(model.ElementUpperFloors), element.Name)
+	assembly := element.Children[0]
+	assert.InDelta(t, 100.0, assembly.Quantity, 1e-9)
+	material := assembly.Children[0]
+	assert.InDelta(t, 25.0, material.Quantity, 1e-9)
+	assert.Equal(t, "m3", material.Unit)
+	assert.Equal(t, "EPD-123", material.Source)
+	assert.Equal(t, 2, material.MaterialVersion)
+	assert.Len(t, material.Children, 3, "modules without a value and D are left out")
+	assert.InDelta(t, 5000.0, material.Children[0].Subtotal, 1e-9)
+}
+
+// TestBreakdownOnRequest tests that the breakdown of the total carbon is only
+// calculated for detailed results, and that a cached run without one is
+// calculated again when a detailed result is asked for.
+func TestBreakdownOnRequest(t *testing.T) {
+	slab := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		Name:          "Slab",
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 200), DeclaredQuantity: 0.25}},
+	}
+	building := &model.Building{
+		Model:         gorm.Model{ID: 1},
+		Assemblies:    []*model.Assembly{slab},
+		AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Element: model.ElementUpperFloors, Quantity: 100}},
+	}
+	runs := &memoryRunRepository{}
+	bs := service.NewBuildingService(&foundBuildingRepository{building: building}, nil, runs, service.NewCalculationService())
+
+	result, err := bs.ComputeTotalCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, result.Breakdown)
+	assert.Empty(t, runs.runs[0].Breakdown)
+
+	result, err = bs.ComputeTotalCarbon(1, service.CalculationOptions{Detailed: true})
+	assert.NoError(t, err)
+	assert.False(t, result.Cached, "the cached run has no breakdown")
+	if assert.NotNil(t, result.Breakdown) {
+		assert.InDelta(t, result.Total, result.Breakdown.Subtotal, 1e-9)
+	}
+
+	result, err = bs.ComputeTotalCarbon(1, service.CalculationOptions{Detailed: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Cached)
+	assert.NotNil(t, result.Breakdown)
+
+	result, err = bs.ComputeTotalCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	assert.True(t, result.Cached)
+	assert.Nil(t, result.Breakdown)
+}
+
+// TestEmbodiedBreakdown tests that the breakdown of the embodied carbon
+// estimate includes the substructure and the methodology's uplift, so that it
+// adds up to the estimate.
+func TestEmbodiedBreakdown(t *testing.T) {
+	assert.NoError(t, service.RegisterMethodology(&model.Methodology{ID: "uplifted", Scope: model.ScopeWholeLife, Uplift: 0.1}))
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.25,
+		AboveGroundFloorCount: 2,
+		UnderGroundFloorCount: 1,
+	}
+	bs := service.NewBuildingService(&foundBuildingRepository{building: building}, nil, nil, service.NewCalculationService())
+
+	result, err := bs.ComputeEmbodiedCarbon(1, service.CalculationOptions{Methodology: "uplifted", Detailed: true})
+	assert.NoError(t, err)
+	root := result.Breakdown
+	assert.InDelta(t, result.Total, root.Subtotal, 1e-9)
+
+	substructure := root.Children[0]
+	assert.Equal(t, string(model.ElementSubstructure), substructure.Name)
+	assert.InDelta(t, building.EstimateSubstructure().TotalCarbon*1.1, substructure.Subtotal, 1e-9)
+	assert.Len(t, substructure.Children, 5, "four parts and the uplift")
+	assert.Equal(t, model.LevelUplift, substructure.Children[4].Level)
+	for _, element := range result.ByElement {
+		for _, node := range root.Children {
+			if node.Name == string(element.Element) {
+				assert.InDelta(t, element.Carbon, node.Subtotal, 1e-9)
+			}
+		}
+	}
+
+	result, err = bs.ComputeEmbodiedCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	assert.Nil(t, result.Breakdown)
+}
+
+// TestAssemblyBreakdown tests that the breakdown of an assembly adds up to its
+// whole life carbon.
+func TestAssemblyBreakdown(t *testing.T) {
+	frame := &model.Assembly{
+		Model: gorm.Model{ID: 10},
+		Name:  "Frame",
+		MaterialLinks: []*model.AssemblyMaterial{
+			{MaterialID: 1, Material: newVersionedMaterial(1, 70), DeclaredQuantity: 2},
+			{MaterialID: 2, Material: newVersionedMaterial(2, 20), DeclaredQuantity: 1},
+		},
+	}
+	as := service.NewAssemblyService(&fakeAssemblyRepository{assembly: frame}, nil, nil, service.NewCalculationService())
+
+	total, err := as.ComputeTotalCarbon(10)
+	assert.NoError(t, err)
+	breakdown, err := as.ComputeBreakdown(10)
+	assert.NoError(t, err)
+	assert.InDelta(t, 160.0, total, 1e-9)
+	assert.InDelta(t, total, breakdown.Subtotal, 1e-9)
+	assert.Len(t, breakdown.Children, 2)
+}
+
+// TestHotspotAnalysis tests that contributors are ranked over the module scope
+// and that those making up the first 80% of it are flagged.
+func TestHotspotAnalysis(t *testing.T) {
+	steel := newVersionedMaterial(1, 70)
+	timber := newVersionedMaterial(2, 20)
+	glass := newVersionedMaterial(3, 10)
+	frame := &model.Assembly{
+		Model: gorm.Model{ID: 10},
+		Name:  "Frame",
+		MaterialLinks: []*model.AssemblyMaterial{
+			{MaterialID: 1, Material: steel, DeclaredQuantity: 1},
+			{MaterialID: 2, Material: timber, DeclaredQuantity: 1},
+		},
+	}
+	window := &model.Assembly{
+		Model:         gorm.Model{ID: 11},
+		Name:          "Window",
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 3, Material: glass, DeclaredQuantity: 1}},
+	}
+	building := &model.Building{
+		Assemblies: []*model.Assembly{window, frame},
+		AssemblyLinks: []*model.BuildingAssembly{
+			{AssemblyID: 10, Element: model.ElementFrame, Quantity: 1},
+			{AssemblyID: 11, Element: model.ElementWindows, Quantity: 1},
+		},
+	}
+	building.PinMaterialVersions()
+
+	analysis := service.NewHotspotAnalysis(building, model.ScopeUpfront, []string{"A1", "A2", "A3", "A4", "A5"})
+	assert.InDelta(t, 100.0, analysis.Total, 1e-9)
+	assert.Equal(t, "Frame", analysis.Assemblies[0].Name)
+	assert.Len(t, analysis.Materials, 3)
+	assert.Equal(t, uint(1), analysis.Materials[0].ID)
+	assert.InDelta(t, 90.0, analysis.Materials[1].CumulativeShare, 1e-9)
+	assert.True(t, analysis.Materials[1].Pareto, "timber brings the cumulative share past 80%")
+	assert.False(t, analysis.Materials[2].Pareto)
+
+	analysis = service.NewHotspotAnalysis(building, "C1-C4", []string{"C1", "C2", "C3", "C4"})
+	assert.InDelta(t, 0.0, analysis.Total, 1e-9)
+	assert.False(t, analysis.Elements[0].Pareto)
+}
@@ -0,0 +1,443 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"carbon-service/service/converter"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestGeometricRoleQuantities tests that assemblies with a geometric role
+// follow the building's geometry once it is derived again after its
+// parameters change, and that reading their quantity changes nothing.
+func TestGeometricRoleQuantities(t *testing.T) {
+	glass := newVersionedMaterial(1, 10)
+	glazing := &model.Assembly{
+		Model:         gorm.Model{ID: 20},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: glass, DeclaredQuantity: 1}},
+	}
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.5,
+		AboveGroundFloorCount: 3,
+		Assemblies:            []*model.Assembly{glazing},
+		AssemblyLinks: []*model.BuildingAssembly{
+			{BuildingID: 1, AssemblyID: 20, Element: model.ElementWindows, Role: model.RoleGlazing},
+		},
+	}
+	building.UpdateGeometry()
+	building.PinMaterialVersions()
+
+	// a 10 x 20 m footprint has a 60 m perimeter, so 720 m2 of facade and 360 m2 of glazing
+	assert.InDelta(t, 360.0, building.AssemblyQuantity(20), 1e-9)
+	assert.InDelta(t, 3600.0, building.ComputeWholeLifeCarbon(), 1e-9)
+
+	building.WWR = 0.25
+	building.AboveGroundFloorCount = 4
+	assert.InDelta(t, 360.0, building.AssemblyQuantity(20), 1e-9)
+	building.UpdateGeometry()
+	links := building.RefreshRoleQuantities()
+	assert.Len(t, links, 1)
+	assert.InDelta(t, 240.0, links[0].Quantity, 1e-9)
+	assert.InDelta(t, 2400.0, building.ComputeWholeLifeCarbonByElement()[model.ElementWindows], 1e-9)
+}
+
+// TestSubstructureEstimate tests the substructure estimate from the footprint
+// and underground floor count, with a factor overridden on the building.
+func TestSubstructureEstimate(t *testing.T) {
+	retainingWall := 100.0
+	building := &model.Building{
+		FTF:                   4,
+		GroundFloorArea:       200,
+		AboveGroundFloorCount: 3,
+		UnderGroundFloorCount: 2,
+		SubstructureFactors:   model.SubstructureFactors{RetainingWall: &retainingWall},
+	}
+
+	// a 60 m perimeter over two 4 m basement levels
+	estimate := building.EstimateSubstructure()
+	assert.InDelta(t, 480.0, estimate.RetainingWallArea, 1e-9)
+	assert.InDelta(t, 400.0, estimate.BasementSlabArea, 1e-9)
+	assert.InDelta(t, 200.0, estimate.FoundationArea, 1e-9)
+	assert.InDelta(t, 1600.0, estimate.ExcavationVolume, 1e-9)
+	assert.Equal(t, model.DefaultSubstructureFactors.BasementSlab, estimate.Factors.BasementSlab)
+	assert.InDelta(t, 108000.0, estimate.TotalCarbon, 1e-9)
+	assert.InDelta(t, 108000.0, building.CalculateEmbodiedCarbonByElement()[model.ElementSubstructure], 1e-9)
+
+	// a factor set to zero is kept rather than replaced by its default
+	foundation := 0.0
+	building.SubstructureFactors.Foundation = &foundation
+	estimate = building.EstimateSubstructure()
+	assert.Equal(t, 0.0, estimate.FoundationCarbon)
+	assert.InDelta(t, 96000.0, estimate.TotalCarbon, 1e-9)
+}
+
+// TestCarbonByElement tests that assemblies are grouped by the element they
+// are tagged with on the building, untagged assemblies being unclassified.
+func TestCarbonByElement(t *testing.T) {
+	concrete := newVersionedMaterial(1, 100)
+	steel := newVersionedMaterial(2, 10)
+	building := &model.Building{
+		Model: gorm.Model{ID: 1},
+		Assemblies: []*model.Assembly{
+			{Model: gorm.Model{ID: 30}, MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: concrete, DeclaredQuantity: 2}}},
+			{Model: gorm.Model{ID: 31}, MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 2, Material: steel, DeclaredQuantity: 5}}},
+			{Model: gorm.Model{ID: 32}, MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 2, Material: steel, DeclaredQuantity: 1}}},
+		},
+		AssemblyLinks: []*model.BuildingAssembly{
+			{BuildingID: 1, AssemblyID: 30, Element: model.ElementFrame, Quantity: 3},
+			{BuildingID: 1, AssemblyID: 31, Element: model.ElementFrame, Quantity: 2},
+		},
+	}
+	building.PinMaterialVersions()
+
+	// frame: 3 x 2 x 100 + 2 x 5 x 10 = 700, the untagged assembly counts once
+	assert.Equal(t, map[model.Element]float64{
+		model.ElementFrame:        700,
+		model.ElementUnclassified: 10,
+	}, building.ComputeWholeLifeCarbonByElement())
+	assert.Equal(t, 710.0, building.ComputeWholeLifeCarbon())
+}
+
+// TestEmbodiedCarbonByElement tests that the parametric estimate charges the
+// glazing rate on the glazed part of the facade and the cladding rate on the rest.
+func TestEmbodiedCarbonByElement(t *testing.T) {
+	building := &model.Building{
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.25,
+		AboveGroundFloorCount: 5,
+	}
+	building.UpdateGeometry()
+
+	// a 60 m perimeter over five 4 m floors is 1200 m2 of facade, 300 m2 of it glazed
+	byElement := building.CalculateEmbodiedCarbonByElement()
+	assert.InDelta(t, 300.0, building.GlazingArea, 1e-9)
+	assert.InDelta(t, 4080.0, byElement[model.ElementWindows], 1e-9)
+	assert.InDelta(t, 7920.0, byElement[model.ElementExternalWalls], 1e-9)
+	assert.InDelta(t, 1540.0, byElement[model.ElementRoof], 1e-9)
+}
+
+// TestNormalisation tests that results are divided by the building's
+// denominator, over the default study period when it has none.
+func TestNormalisation(t *testing.T) {
+	building := &model.Building{
+		GroundFloorArea:       100,
+		AboveGroundFloorCount: 2,
+		GIA:                   180,
+		Occupants:             10,
+	}
+	building.UpdateGeometry()
+
+	denominator, err := service.NewDenominator(building, service.NormalisePerGFAPerYear)
+	assert.NoError(t, err)
+	assert.InDelta(t, 200.0*model.DefaultStudyPeriod, denominator.Value, 1e-9)
+
+	result := &service.CarbonResult{
+		Total:     1800,
+		ByElement: []service.ElementCarbon{{Element: model.ElementRoof, Carbon: 900}},
+		ByModule:  []service.ModuleCarbon{{Module: model.ModuleA1toA5, Carbon: 1800}},
+	}
+	denominator, err = service.NewDenominator(building, service.NormalisePerGIA)
+	assert.NoError(t, err)
+	result.Normalise(denominator)
+	assert.InDelta(t, 10.0, result.Total, 1e-9)
+	assert.InDelta(t, 5.0, result.ByElement[0].Carbon, 1e-9)
+	assert.InDelta(t, 10.0, result.ByModule[0].Carbon, 1e-9)
+	assert.Equal(t, "m2 GIA", result.Normalisation.Unit)
+
+	_, err = service.NewDenominator(building, service.NormalisePerBedroom)
+	assert.ErrorIs(t, err, service.ErrNoDenominator)
+	_, err = service.NewDenominator(building, "per-storey")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, service.ErrNoDenominator)
+}
+
+// TestNormalisedEstimates tests that the substructure and embodied carbon
+// estimates are normalised, and that the embodied estimate is all upfront.
+func TestNormalisedEstimates(t *testing.T) {
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.25,
+		AboveGroundFloorCount: 2,
+		UnderGroundFloorCount: 1,
+	}
+	estimate := building.EstimateSubstructure()
+	bs := service.NewBuildingService(&foundBuildingRepository{building: building}, nil, nil, service.NewCalculationService())
+
+	substructure, err := bs.EstimateSubstructure(1, service.NormalisePerGFA)
+	assert.NoError(t, err)
+	assert.InDelta(t, estimate.TotalCarbon/600, substructure.TotalCarbon, 1e-9)
+	assert.InDelta(t, estimate.FoundationCarbon/600, substructure.FoundationCarbon, 1e-9)
+	assert.Equal(t, estimate.FoundationArea, substructure.FoundationArea)
+	_, err = bs.EstimateSubstructure(1, service.NormalisePerOccupant)
+	assert.ErrorIs(t, err, service.ErrNoDenominator)
+
+	embodied, err := bs.ComputeEmbodiedCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, embodied.ByModule, len(model.Modules)) {
+		assert.Equal(t, model.ModuleA1toA5, embodied.ByModule[0].Module)
+		assert.InDelta(t, embodied.Total, embodied.ByModule[0].Carbon, 1e-9)
+		assert.Equal(t, 0.0, embodied.ByModule[1].Carbon+embodied.ByModule[2].Carbon)
+	}
+}
+
+// TestPresentResult tests that normalised results are converted into the
+// output unit without per area units applying to absolute results.
+func TestPresentResult(t *testing.T) {
+	result := &service.CarbonResult{
+		Total:         50,
+		ByElement:     []service.ElementCarbon{{Element: model.ElementRoof, Carbon: 20}},
+		Normalisation: &service.Denominator{Normalisation: service.NormalisePerGFAPerYear, Value: 1200, Unit: "m2 GFA year"},
+	}
+	assert.NoError(t, result.Present(converter.UnitTCO2ePerM2))
+	assert.InDelta(t, 0.05, result.Total, 1e-9)
+	assert.InDelta(t, 0.02, result.ByElement[0].Carbon, 1e-9)
+	assert.Equal(t, "tCO2e/m2/yr", result.Unit)
+
+	absolute := &service.CarbonResult{Total: 1000}
+	assert.Error(t, absolute.Present(converter.UnitKgCO2ePerM2))
+	assert.NoError(t, absolute.Present(converter.UnitTCO2e))
+	assert.Equal(t, "tCO2e", absolute.Unit)
+}
+
+// TestBuildingInUnit tests that imperial geometry is stored in metric and
+// presented back in imperial without modifying the stored building.
+func TestBuildingInUnit(t *testing.T) {
+	imperial := model.NewUnit(model.UnitSystemImperial)
+	req := service.CreateBuildingRequest{FTF: 10, GroundFloorArea: 1000}
+	assert.NoError(t, req.ToMetric(imperial))
+	assert.InDelta(t, 3.048, req.FTF, 1e-9)
+	assert.InDelta(t, 92.90304, req.GroundFloorArea, 1e-9)
+
+	building := &model.Building{
+		FTF:             req.FTF,
+		GroundFloorArea: req.GroundFloorArea,
+		Floors:          []*model.Floor{{Area: req.GroundFloorArea, Height: req.FTF}},
+	}
+	presented, err := service.BuildingInUnit(building, imperial)
+	assert.NoError(t, err)
+	assert.InDelta(t, 10.0, presented.FTF, 1e-9)
+	assert.InDelta(t, 1000.0, presented.GroundFloorArea, 1e-9)
+	assert.InDelta(t, 1000.0, presented.Floors[0].Area, 1e-9)
+	assert.InDelta(t, 92.90304, building.Floors[0].Area, 1e-9)
+
+	assert.Equal(t, converter.OutputUnit("lbCO2e/ft2"), service.OutputUnitFor(imperial, service.NormalisePerGFA))
+}
+
+// fakeUnitRepository holds the unit preferences of projects and users and
+// counts the queries made for them.
+type fakeUnitRepository struct {
+	repository.UnitRepository
+	units   []model.Unit
+	queries int
+}
+
+func (r *fakeUnitRepository) FindByProjectOrUser(projectID string, userID string) ([]model.Unit, error) {
+	r.queries++
+	var units []model.Unit
+	for _, unit := range r.units {
+		if (unit.OwnerType == model.UnitOwnerProject && unit.OwnerID == projectID) ||
+			(unit.OwnerType == model.UnitOwnerUser && unit.OwnerID == userID) {
+			units = append(units, unit)
+		}
+	}
+	return units, nil
+}
+
+// TestResolveUnit tests that the project's unit system is preferred over the
+// user's in a single query, and that no query is made without either.
+func TestResolveUnit(t *testing.T) {
+	imperial := model.NewUnit(model.UnitSystemImperial)
+	imperial.OwnerType, imperial.OwnerID = model.UnitOwnerProject, "p1"
+	metric := model.NewUnit(model.UnitSystemMetric)
+	metric.OwnerType, metric.OwnerID = model.UnitOwnerUser, "u1"
+	repo := &fakeUnitRepository{units: []model.Unit{metric, imperial}}
+	unitService := service.NewUnitService(repo)
+
+	unit, err := unitService.ResolveUnit("p1", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, model.UnitSystemImperial, unit.System)
+	assert.Equal(t, 1, repo.queries)
+
+	unit, err = unitService.ResolveUnit("p2", "u1")
+	assert.NoError(t, err)
+	assert.Equal(t, model.UnitSystemMetric, unit.System)
+
+	repo.queries = 0
+	unit, err = unitService.ResolveUnit("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, model.UnitSystemMetric, unit.System)
+	assert.Zero(t, repo.queries)
+}
+
+// TestPresentInImperial tests that trajectories, benchmark ratings and
+// material quantities are presented in the imperial unit system.
+func TestPresentInImperial(t *testing.T) {
+	imperial := model.NewUnit(model.UnitSystemImperial)
+
+	point := service.TrajectoryPoint{
+		GFA:           1000,
+		TotalCarbon:   2,
+		CarbonPerArea: 2,
+		ByElement:     []service.ElementCarbon{{Element: model.ElementFrame, Carbon: 2}},
+		Normalisation: &service.Denominator{Normalisation: service.NormalisePerGFA, Value: 1000, Unit: "m2 GFA"},
+	}
+	assert.NoError(t, point.Present(service.OutputUnitFor(imperial, service.NormalisePerGFA), imperial))
+	assert.InDelta(t, 10763.91, point.GFA, 1e-2)
+	assert.InDelta(t, 0.409632, point.TotalCarbon, 1e-5)
+	assert.InDelta(t, 0.409632, point.CarbonPerArea, 1e-5)
+	assert.InDelta(t, 0.409632, point.ByElement[0].Carbon, 1e-5)
+	assert.Equal(t, "lbCO2e/ft2", point.Unit)
+
+	comparison := service.BenchmarkComparison{
+		Area:    1000,
+		Ratings: []service.BenchmarkRating{{Carbon: 1000, CarbonPerArea: 1, Targets: []service.TargetDistance{{Limit: 2, Distance: -1}}}},
+	}
+	assert.NoError(t, comparison.Present(imperial))
+	assert.InDelta(t, 2204.62, comparison.Ratings[0].Carbon, 1e-2)
+	assert.InDelta(t, 0.204816, comparison.Ratings[0].CarbonPerArea, 1e-5)
+	assert.InDelta(t, 0.409632, comparison.Ratings[0].Targets[0].Limit, 1e-5)
+	assert.Equal(t, "lbCO2e/ft2", comparison.Unit)
+
+	unit, err := service.QuantityUnitFor(imperial, "kWh")
+	assert.NoError(t, err)
+	assert.Equal(t, "kBtu", unit)
+	unit, err = service.QuantityUnitFor(imperial, "piece")
+	assert.NoError(t, err)
+	assert.Equal(t, "piece", unit)
+}
+
+// TestGeometryWithoutFootprint tests that a building without a ground floor
+// area is rejected instead of crashing the geometry calculation.
+func TestGeometryWithoutFootprint(t *testing.T) {
+	building := &model.Building{
+		FTF:                   4,
+		WWR:                   0.4,
+		AboveGroundFloorCount: 3,
+		AssemblyLinks:         []*model.BuildingAssembly{{AssemblyID: 20, Role: model.RoleGlazing}},
+	}
+
+	assert.Error(t, building.ValidateGeometry())
+	assert.NotPanics(t, func() { building.UpdateGeometry() })
+	assert.Equal(t, 0.0, building.AssemblyQuantity(20))
+	assert.Equal(t, 0.0, building.EstimateSubstructure().RetainingWallArea)
+
+	buildingService := service.NewBuildingService(&namedBuildingRepository{}, nil, nil, service.NewCalculationService())
+	_, err := buildingService.CreateBuilding(service.CreateBuildingRequest{
+		Name:                  "Pavilion",
+		FTF:                   4,
+		WWR:                   0.4,
+		AboveGroundFloorCount: 1,
+	})
+	assert.Error(t, err)
+
+	building.GroundFloorArea = 200
+	assert.NoError(t, building.ValidateGeometry())
+	building.WWR = 1.5
+	assert.Error(t, building.ValidateGeometry())
+}
+
+// namedBuildingRepository knows no building names.
+type namedBuildingRepository struct {
+	repository.BuildingRepository
+}
+
+func (r *namedBuildingRepository) ExistsByBuildingName(name string) bool {
+	return false
+}
+
+// creatingBuildingRepository records what a building is created with, and
+// fails the creation when err is set.
+type creatingBuildingRepository struct {
+	namedBuildingRepository
+	err      error
+	creates  int
+	building *model.Building
+	links    []*model.BuildingAssembly
+}
+
+func (r *creatingBuildingRepository) Create(building *model.Building, links []*model.BuildingAssembly) error {
+	r.creates++
+	if r.err != nil {
+		return r.err
+	}
+	building.ID = 9
+	for _, link := range links {
+		link.BuildingID = building.ID
+	}
+	r.building, r.links = building, links
+	return nil
+}
+
+func (r *creatingBuildingRepository) EagerFindByID(id uint) (*model.Building, error) {
+	if r.building == nil || r.building.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.building, nil
+}
+
+// TestCreateBuildingWithAssemblies tests that a building is created together
+// with its assembly links in a single repository call, and that nothing is
+// reported as created when that call fails.
+func TestCreateBuildingWithAssemblies(t *testing.T) {
+	glazing := &model.Assembly{Model: gorm.Model{ID: 20}}
+	req := service.CreateBuildingRequest{
+		Name:                  "Office",
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.5,
+		AboveGroundFloorCount: 3,
+		AssemblyAssignments: []service.AssemblyAssignment{
+			{AssemblyID: 20, AssignAssemblyRequest: service.AssignAssemblyRequest{Role: model.RoleGlazing}},
+		},
+	}
+
+	repo := &creatingBuildingRepository{}
+	bs := service.NewBuildingService(repo, &fakeAssemblyRepository{assembly: glazing}, nil, nil)
+	building, err := bs.CreateBuilding(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, repo.creates)
+	assert.Equal(t, uint(9), building.ID)
+	if assert.Len(t, repo.links, 1) {
+		assert.Equal(t, uint(9), repo.links[0].BuildingID)
+		assert.Equal(t, model.ElementWindows, repo.links[0].Element)
+		assert.InDelta(t, 360.0, repo.links[0].Quantity, 1e-9)
+	}
+
+	repo = &creatingBuildingRepository{err: errors.New("insert failed")}
+	bs = service.NewBuildingService(repo, &fakeAssemblyRepository{assembly: glazing}, nil, nil)
+	building, err = bs.CreateBuilding(req)
+	assert.Error(t, err)
+	assert.Nil(t, building)
+	assert.Equal(t, 1, repo.creates)
+
+	req.AssemblyAssignments[0].AssemblyID = 21
+	repo = &creatingBuildingRepository{}
+	bs = service.NewBuildingService(repo, &fakeAssemblyRepository{assembly: glazing}, nil, nil)
+	_, err = bs.CreateBuilding(req)
+	assert.Error(t, err)
+	assert.Equal(t, 0, repo.creates)
+
+	// without a role the quantity defaults to 1, an explicit zero is kept
+	zero := 0.0
+	for quantity, expected := range map[*float64]float64{nil: 1, &zero: 0} {
+		req.AssemblyAssignments[0] = service.AssemblyAssignment{AssemblyID: 20, AssignAssemblyRequest: service.AssignAssemblyRequest{Quantity: quantity}}
+		repo = &creatingBuildingRepository{}
+		bs = service.NewBuildingService(repo, &fakeAssemblyRepository{assembly: glazing}, nil, nil)
+		_, err = bs.CreateBuilding(req)
+		assert.NoError(t, err)
+		if assert.Len(t, repo.links, 1) {
+			assert.Equal(t, expected, repo.links[0].Quantity)
+		}
+	}
+}
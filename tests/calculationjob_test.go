@@ -0,0 +1,345 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/service"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryJobRepository keeps calculation jobs in memory.
+type memoryJobRepository struct {
+	mu   sync.Mutex
+	jobs []*model.CalculationJob
+}
+
+func (r *memoryJobRepository) Create(job *model.CalculationJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.ID = uint(len(r.jobs) + 1)
+	saved := *job
+	r.jobs = append(r.jobs, &saved)
+	return nil
+}
+
+func (r *memoryJobRepository) Update(job *model.CalculationJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saved := *job
+	r.jobs[job.ID-1] = &saved
+	return nil
+}
+
+func (r *memoryJobRepository) FindByID(id uint) (*model.CalculationJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || int(id) > len(r.jobs) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	saved := *r.jobs[id-1]
+	return &saved, nil
+}
+
+func (r *memoryJobRepository) ClaimNext(owner string, lease time.Duration) (*model.CalculationJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.Status == model.JobQueued {
+			expires := time.Now().Add(lease)
+			job.Status = model.JobRunning
+			job.Owner = owner
+			job.LeaseExpiresAt = &expires
+			saved := *job
+			return &saved, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryJobRepository) UpdateStatus(id uint, from model.JobStatus, to model.JobStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jobs[id-1].Status != from {
+		return false, nil
+	}
+	r.jobs[id-1].Status = to
+	return true, nil
+}
+
+func (r *memoryJobRepository) UpdateProgress(id uint, progress float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[id-1].Progress = progress
+	return nil
+}
+
+func (r *memoryJobRepository) RenewLease(id uint, owner string, lease time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.jobs[id-1]
+	if job.Status != model.JobRunning || job.Owner != owner {
+		return false, nil
+	}
+	expires := time.Now().Add(lease)
+	job.LeaseExpiresAt = &expires
+	return true, nil
+}
+
+func (r *memoryJobRepository) RequeueRunning(owner string) error {
+	return r.requeue(func(job *model.CalculationJob) bool { return job.Owner == owner })
+}
+
+func (r *memoryJobRepository) RequeueExpired() error {
+	return r.requeue(func(job *model.CalculationJob) bool {
+		return job.LeaseExpiresAt == nil || job.LeaseExpiresAt.Before(time.Now())
+	})
+}
+
+func (r *memoryJobRepository) requeue(matches func(job *model.CalculationJob) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.Status == model.JobRunning && matches(job) {
+			job.Status = model.JobQueued
+			job.Owner = ""
+			job.LeaseExpiresAt = nil
+		}
+	}
+	return nil
+}
+
+// fixedBuildingService calculates every building to its total per m2 GFA of 1000 m2.
+type fixedBuildingService struct {
+	service.BuildingService
+	totals map[uint]float64
+}
+
+func (bs *fixedBuildingService) ComputeTotalCarbon(buildingID uint, opts service.CalculationOptions) (*service.CarbonResult, error) {
+	total := bs.totals[buildingID]
+	return &service.CarbonResult{
+		Total:         total / 1000,
+		ByElement:     []service.ElementCarbon{{Element: model.ElementFrame, Carbon: total / 1000}},
+		Normalisation: &service.Denominator{Normalisation: opts.Normalisation, Value: 1000, Unit: "m2 GFA"},
+	}, nil
+}
+
+// absoluteBuildingService calculates every building to its total in kgCO2e,
+// by the methodology of the building if it has one.
+type absoluteBuildingService struct {
+	service.BuildingService
+	totals        map[uint]float64
+	methodologies map[uint]*model.Methodology
+}
+
+func (bs *absoluteBuildingService) ComputeTotalCarbon(buildingID uint, opts service.CalculationOptions) (*service.CarbonResult, error) {
+	total := bs.totals[buildingID]
+	return &service.CarbonResult{
+		Total:         total,
+		ByElement:     []service.ElementCarbon{{Element: model.ElementFrame, Carbon: total}},
+		Normalisation: &service.Denominator{Normalisation: service.NormaliseAbsolute, Value: 1},
+		Methodology:   bs.methodologies[buildingID],
+	}, nil
+}
+
+// waitForJob polls the job until it has finished.
+func waitForJob(t *testing.T, js service.CalculationJobService, id uint) *model.CalculationJob {
+	for i := 0; i < 200; i++ {
+		job, err := js.GetJob(id)
+		assert.NoError(t, err)
+		if job.Status.IsFinished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("calculation job %d did not finish", id)
+	return nil
+}
+
+// TestCalculationJobs tests that submitted jobs are run in the background,
+// portfolio results are normalised by the sum of the buildings' denominators,
+// and that queued jobs can be cancelled.
+func TestCalculationJobs(t *testing.T) {
+	repo := &memoryJobRepository{}
+	bs := &fixedBuildingService{totals: map[uint]float64{1: 100000, 2: 300000}}
+	js := service.NewCalculationJobService(repo, bs, nil, 2, "test")
+
+	_, err := js.SubmitJob(service.CalculationJobRequest{Kind: model.JobPortfolio})
+	assert.Error(t, err, "a portfolio needs buildings")
+
+	queued, err := js.SubmitJob(service.CalculationJobRequest{Kind: model.JobBuilding, BuildingID: 1})
+	assert.NoError(t, err)
+	cancelled, err := js.CancelJob(queued.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.JobCancelled, cancelled.Status)
+
+	submitted, err := js.SubmitJob(service.CalculationJobRequest{
+		Kind:          model.JobPortfolio,
+		BuildingIDs:   []uint{1, 2},
+		Normalisation: service.NormalisePerGFA,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, model.JobQueued, submitted.Status)
+	js.Start()
+
+	job := waitForJob(t, js, submitted.ID)
+	assert.Equal(t, model.JobDone, job.Status, job.Error)
+	assert.InDelta(t, 1.0, job.Progress, 1e-9)
+	var portfolio service.PortfolioResult
+	assert.NoError(t, json.Unmarshal(job.Result, &portfolio))
+	assert.InDelta(t, 200.0, portfolio.Total, 1e-9, "400000 kgCO2e over 2000 m2")
+	assert.Equal(t, "kgCO2e/m2", portfolio.Unit)
+	assert.Len(t, portfolio.Buildings, 2)
+	assert.InDelta(t, 300.0, portfolio.Buildings[1].Total, 1e-9)
+
+	job, _ = js.GetJob(queued.ID)
+	assert.Equal(t, model.JobCancelled, job.Status, "cancelled jobs are not run")
+	_, err = js.CancelJob(submitted.ID)
+	assert.Error(t, err, "finished jobs cannot be cancelled")
+	js.Stop()
+}
+
+// cancellingBuildingService cancels the job it calculates a building for,
+// after the calculation could have stopped.
+type cancellingBuildingService struct {
+	fixedBuildingService
+	jobs  service.CalculationJobService
+	jobID uint
+}
+
+func (bs *cancellingBuildingService) ComputeTotalCarbon(buildingID uint, opts service.CalculationOptions) (*service.CarbonResult, error) {
+	if _, err := bs.jobs.CancelJob(bs.jobID); err != nil {
+		return nil, err
+	}
+	return bs.fixedBuildingService.ComputeTotalCarbon(buildingID, opts)
+}
+
+// TestCancelFinishedCalculation tests that a job cancelled once its
+// calculation can no longer stop is saved with its result.
+func TestCancelFinishedCalculation(t *testing.T) {
+	bs := &cancellingBuildingService{fixedBuildingService: fixedBuildingService{totals: map[uint]float64{1: 100000}}}
+	js := service.NewCalculationJobService(&memoryJobRepository{}, bs, nil, 1, "test")
+	bs.jobs = js
+
+	submitted, err := js.SubmitJob(service.CalculationJobRequest{Kind: model.JobBuilding, BuildingID: 1})
+	assert.NoError(t, err)
+	bs.jobID = submitted.ID
+	js.Start()
+	defer js.Stop()
+
+	job := waitForJob(t, js, submitted.ID)
+	assert.Equal(t, model.JobDone, job.Status, job.Error)
+	assert.NotEmpty(t, job.Result)
+}
+
+// TestRequeueOwnJobs tests that a starting instance only takes back the jobs
+// it was running itself and those whose lease expired.
+func TestRequeueOwnJobs(t *testing.T) {
+	repo := &memoryJobRepository{}
+	leased := time.Now().Add(time.Hour)
+	expired := time.Now().Add(-time.Hour)
+	request, _ := json.Marshal(service.CalculationJobRequest{Kind: model.JobBuilding, BuildingID: 1})
+	for _, job := range []*model.CalculationJob{
+		{Kind: model.JobBuilding, Status: model.JobRunning, Request: request, Owner: "a", LeaseExpiresAt: &leased},
+		{Kind: model.JobBuilding, Status: model.JobRunning, Request: request, Owner: "b", LeaseExpiresAt: &leased},
+		{Kind: model.JobBuilding, Status: model.JobRunning, Request: request, Owner: "c", LeaseExpiresAt: &expired},
+	} {
+		assert.NoError(t, repo.Create(job))
+	}
+
+	bs := &fixedBuildingService{totals: map[uint]float64{1: 100000}}
+	js := service.NewCalculationJobService(repo, bs, nil, 1, "a")
+	js.Start()
+	defer js.Stop()
+
+	assert.Equal(t, model.JobDone, waitForJob(t, js, 1).Status, "the instance was running it when it stopped")
+	assert.Equal(t, model.JobDone, waitForJob(t, js, 3).Status, "its owner stopped renewing its lease")
+	job, _ := js.GetJob(2)
+	assert.Equal(t, model.JobRunning, job.Status, "another instance is still running it")
+	assert.Equal(t, "b", job.Owner)
+}
+
+// TestCalculateProgress tests that a calculation reports every building it
+// processed with the partial total and warnings about empty results.
+func TestCalculateProgress(t *testing.T) {
+	bs := &absoluteBuildingService{totals: map[uint]float64{1: 100000, 2: 300000}}
+	js := service.NewCalculationJobService(&memoryJobRepository{}, bs, nil, 1, "test")
+
+	var events []service.CalculationProgress
+	var warnings []string
+	result, err := js.Calculate(context.Background(), service.CalculationJobRequest{
+		Kind:        model.JobPortfolio,
+		BuildingIDs: []uint{1, 3, 2},
+	}, func(progress service.CalculationProgress) {
+		events = append(events, progress)
+	}, func(warning string) {
+		warnings = append(warnings, warning)
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, 400000.0, result.(*service.PortfolioResult).Total, 1e-9)
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, 3, events[2].Processed)
+	assert.Equal(t, 3, events[2].Total)
+	assert.InDelta(t, 100000.0, events[0].PartialTotal, 1e-9)
+	assert.InDelta(t, 400000.0, events[2].PartialTotal, 1e-9)
+	assert.Empty(t, events[0].Warnings)
+	assert.Len(t, events[1].Warnings, 1, "building 3 has no carbon")
+	assert.Empty(t, warnings, "every building is calculated without a methodology")
+
+	for _, kind := range []model.JobKind{model.JobPortfolio, model.JobBuilding} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = js.Calculate(ctx, service.CalculationJobRequest{Kind: kind, BuildingID: 1, BuildingIDs: []uint{1}}, func(service.CalculationProgress) {}, func(string) {})
+		assert.ErrorIs(t, err, context.Canceled, kind)
+	}
+}
+
+// TestMixedMethodologiesWarning tests that a portfolio whose buildings are
+// not all calculated by the same methodology is warned about once, whichever
+// building has none.
+func TestMixedMethodologiesWarning(t *testing.T) {
+	en15978, _ := service.FindMethodology(service.MethodologyEN15978)
+	rics, _ := service.FindMethodology(service.MethodologyRICS2023)
+	bs := &absoluteBuildingService{
+		totals:        map[uint]float64{1: 100000, 2: 300000, 3: 200000},
+		methodologies: map[uint]*model.Methodology{2: en15978, 3: en15978},
+	}
+	js := service.NewCalculationJobService(&memoryJobRepository{}, bs, nil, 1, "test")
+
+	for _, test := range []struct {
+		buildings []uint
+		warnings  int
+	}{
+		{[]uint{2, 3}, 0},
+		{[]uint{1, 2}, 1},
+		{[]uint{2, 1}, 1},
+	} {
+		var events []service.CalculationProgress
+		var warnings []string
+		_, err := js.Calculate(context.Background(), service.CalculationJobRequest{Kind: model.JobPortfolio, BuildingIDs: test.buildings},
+			func(progress service.CalculationProgress) {
+				events = append(events, progress)
+			}, func(warning string) {
+				warnings = append(warnings, warning)
+			})
+		assert.NoError(t, err)
+		assert.Len(t, events, len(test.buildings), "only buildings are reported as progress")
+		assert.Len(t, warnings, test.warnings, test.buildings)
+	}
+
+	bs.methodologies[3] = rics
+	var warnings []string
+	result, err := js.Calculate(context.Background(), service.CalculationJobRequest{Kind: model.JobPortfolio, BuildingIDs: []uint{2, 3}},
+		func(service.CalculationProgress) {}, func(warning string) {
+			warnings = append(warnings, warning)
+		})
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+	assert.Nil(t, result.(*service.PortfolioResult).Methodology)
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryRunRepository keeps calculation runs in memory.
+type memoryRunRepository struct {
+	repository.CalculationRunRepository
+	runs []*model.CalculationRun
+}
+
+func (r *memoryRunRepository) Create(run *model.CalculationRun) error {
+	run.ID = uint(len(r.runs) + 1)
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+func (r *memoryRunRepository) FindLatestByBuildingID(buildingID uint) (*model.CalculationRun, error) {
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if r.runs[i].BuildingID == buildingID {
+			return r.runs[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRunRepository) FindByInputsHash(buildingID uint, inputsHash string) (*model.CalculationRun, error) {
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if r.runs[i].BuildingID == buildingID && r.runs[i].InputsHash == inputsHash {
+			return r.runs[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// TestCalculationInputsHash tests that the inputs hash only changes when
+// something the result depends on changes.
+func TestCalculationInputsHash(t *testing.T) {
+	newBuilding := func() *model.Building {
+		concrete := &model.Material{
+			Model: gorm.Model{ID: 1},
+			Versions: []*model.MaterialVersion{
+				{Model: gorm.Model{ID: 11}, Version: 1, Gwp: model.Gwp{MaterialVersionID: 11, A1: 200}},
+				{Model: gorm.Model{ID: 12}, Version: 2, Gwp: model.Gwp{MaterialVersionID: 12, A1: 180}},
+			},
+		}
+		slab := &model.Assembly{
+			Model:         gorm.Model{ID: 10},
+			MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: concrete, DeclaredQuantity: 0.25}},
+		}
+		return &model.Building{
+			Model:         gorm.Model{ID: 1, CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Assemblies:    []*model.Assembly{slab},
+			AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Element: model.ElementUpperFloors, Quantity: 100}},
+		}
+	}
+	latest := map[uint]uint{1: 12}
+	en15978, _ := service.FindMethodology(service.MethodologyEN15978)
+	rics, _ := service.FindMethodology(service.MethodologyRICS2023)
+
+	first := newBuilding()
+	versions := first.CalculationVersions(latest)
+	assert.Equal(t, map[uint]uint{1: 12}, versions, "unpinned materials calculate with their latest version")
+	hash, err := model.CalculationInputsHash(first, versions, en15978)
+	assert.NoError(t, err)
+
+	// the same building loaded again with the pin saved by the first
+	// calculation, after being saved without changes, and without its materials
+	second := newBuilding()
+	second.MaterialPins = []*model.MaterialPin{{Model: gorm.Model{ID: 5}, BuildingID: 1, MaterialID: 1, MaterialVersionID: 12}}
+	second.UpdatedAt = time.Now()
+	second.Assemblies[0].MaterialLinks[0].Material = nil
+	same, _ := model.CalculationInputsHash(second, second.CalculationVersions(latest), en15978)
+	assert.Equal(t, hash, same, "bookkeeping and materials are not inputs")
+
+	other, _ := model.CalculationInputsHash(second, second.CalculationVersions(latest), rics)
+	assert.NotEqual(t, hash, other, "the methodology is an input")
+
+	older := newBuilding()
+	older.MaterialPins = []*model.MaterialPin{{BuildingID: 1, MaterialID: 1, MaterialVersionID: 11}}
+	pinned, _ := model.CalculationInputsHash(older, older.CalculationVersions(latest), en15978)
+	assert.NotEqual(t, hash, pinned, "the pinned material versions are inputs")
+
+	second.AssemblyLinks[0].Quantity = 120
+	changed, _ := model.CalculationInputsHash(second, second.CalculationVersions(latest), en15978)
+	assert.NotEqual(t, hash, changed)
+}
+
+// TestCachedRunBeforePreload tests that a cached run is returned from the
+// inputs of the building without loading it with its material versions.
+func TestCachedRunBeforePreload(t *testing.T) {
+	slab := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 200), DeclaredQuantity: 0.25}},
+	}
+	building := &model.Building{
+		Model:         gorm.Model{ID: 1},
+		Assemblies:    []*model.Assembly{slab},
+		AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Element: model.ElementUpperFloors, Quantity: 100}},
+	}
+	buildings := &foundBuildingRepository{building: building}
+	runs := &memoryRunRepository{}
+	bs := service.NewBuildingService(buildings, nil, runs, service.NewCalculationService())
+
+	result, err := bs.ComputeTotalCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	assert.InDelta(t, 5000.0, result.Total, 1e-9)
+	assert.Equal(t, 1, buildings.eagerLoads)
+
+	result, err = bs.ComputeTotalCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	assert.True(t, result.Cached)
+	assert.InDelta(t, 5000.0, result.Total, 1e-9)
+	assert.Equal(t, 1, buildings.eagerLoads, "the cached run is found before the building is loaded")
+
+	assert.Len(t, runs.runs, 1, "a cached result is not recorded again")
+
+	// a detailed calculation of the same inputs is recorded as a new run with its breakdown
+	result, err = bs.ComputeTotalCarbon(1, service.CalculationOptions{Detailed: true})
+	assert.NoError(t, err)
+	assert.Len(t, runs.runs, 2)
+	assert.Equal(t, runs.runs[1].ID, result.RunID)
+	assert.NotEmpty(t, runs.runs[1].Breakdown)
+	assert.Equal(t, runs.runs[0].InputsHash, runs.runs[1].InputsHash)
+}
+
+// TestRunsOverTime tests that a building whose inputs change and change back
+// keeps a run for every change, the last one reusing the earlier result.
+func TestRunsOverTime(t *testing.T) {
+	slab := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 200), DeclaredQuantity: 0.25}},
+	}
+	building := &model.Building{
+		Model:         gorm.Model{ID: 1},
+		Assemblies:    []*model.Assembly{slab},
+		AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Element: model.ElementUpperFloors, Quantity: 100}},
+	}
+	buildings := &foundBuildingRepository{building: building}
+	runs := &memoryRunRepository{}
+	bs := service.NewBuildingService(buildings, nil, runs, service.NewCalculationService())
+
+	var totals []float64
+	for _, quantity := range []float64{100, 120, 100} {
+		building.AssemblyLinks[0].Quantity = quantity
+		result, err := bs.ComputeTotalCarbon(1, service.CalculationOptions{})
+		assert.NoError(t, err)
+		totals = append(totals, result.Total)
+	}
+	assert.InDeltaSlice(t, []float64{5000, 6000, 5000}, totals, 1e-9)
+	assert.Len(t, runs.runs, 3)
+	assert.Equal(t, runs.runs[0].InputsHash, runs.runs[2].InputsHash)
+	assert.NotEqual(t, runs.runs[0].ID, runs.runs[2].ID)
+	assert.Equal(t, 2, buildings.eagerLoads, "the result of the first inputs is reused")
+}
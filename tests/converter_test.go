@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"carbon-service/service/converter"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConvertQuantity tests conversions of material quantities within and
+// between dimensions.
+func TestConvertQuantity(t *testing.T) {
+	props := converter.MaterialProperties{Density: 500, Thickness: 0.2}
+
+	volume, err := converter.ConvertQuantity(10, "m2", "m3", props)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.0, volume, 1e-9)
+
+	mass, err := converter.ConvertQuantity(2, "m3", "t", props)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0, mass, 1e-9)
+
+	area, err := converter.ConvertQuantity(100, "kg", "m2", props)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0, area, 1e-9)
+
+	_, err = converter.ConvertQuantity(1, "piece", "kg", props)
+	assert.Error(t, err, "pieces cannot be converted without a mass per piece")
+
+	energy, err := converter.ConvertQuantity(1000, "kBtu", "kWh", props)
+	assert.NoError(t, err)
+	assert.InDelta(t, 293.07107, energy, 1e-6)
+
+	_, err = converter.ConvertQuantity(1, "kWh", "kg", props)
+	assert.Error(t, err, "energy has no mass to convert through")
+
+	_, err = converter.ConvertQuantity(1, "kg", "furlong", props)
+	assert.Error(t, err, "unknown units cannot be converted")
+}
+
+// TestOutputUnit tests that results are scaled into carbon and per area output units.
+func TestOutputUnit(t *testing.T) {
+	scale, err := converter.UnitTCO2e.Scale()
+	assert.NoError(t, err)
+	assert.False(t, scale.PerArea())
+	assert.InDelta(t, 2.5, scale.Convert(2500, false), 1e-9)
+
+	scale, err = converter.UnitLbCO2ePerFt2.Scale()
+	assert.NoError(t, err)
+	assert.True(t, scale.PerArea())
+	assert.InDelta(t, 20.4816, scale.Convert(100, true), 1e-4)
+
+	_, err = converter.OutputUnit("kgCO2e/acre").Scale()
+	assert.Error(t, err, "unknown area units cannot be presented")
+}
+
+// TestUnitRegistry tests conversions between simple and compound units of the
+// same dimension, and that units of different dimensions are not converted.
+func TestUnitRegistry(t *testing.T) {
+	density, err := converter.Convert(1, "lb/ft3", "kg/m3")
+	assert.NoError(t, err)
+	assert.InDelta(t, 16.0185, density, 1e-4)
+
+	intensity, err := converter.Convert(1, "kBtu/ft2/yr", "kWh/m2/yr")
+	assert.NoError(t, err)
+	assert.InDelta(t, 3.1546, intensity, 1e-4)
+
+	area, err := converter.Convert(1, "m^2", "ft2")
+	assert.NoError(t, err)
+	assert.InDelta(t, 10.7639, area, 1e-4)
+
+	unit, err := converter.Parse("kgCO2e/m2")
+	assert.NoError(t, err)
+	assert.Equal(t, converter.DimensionOfCarbonPerArea, unit.Dimension)
+
+	_, err = converter.Convert(1, "kg/m3", "kg/m2")
+	assert.Error(t, err, "densities cannot be converted to masses per area")
+
+	_, err = converter.Parse("kg/furlong")
+	assert.Error(t, err, "unknown units cannot be parsed")
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestFloorCarbonByUse tests that assemblies on floors and zones add to the
+// building's total and are grouped by the use of their floor or zone.
+func TestFloorCarbonByUse(t *testing.T) {
+	slab := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 2), DeclaredQuantity: 1}},
+	}
+	fitOut := &model.Assembly{
+		Model:         gorm.Model{ID: 11},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 2, Material: newVersionedMaterial(2, 5), DeclaredQuantity: 1}},
+	}
+	shopID := uint(7)
+	building := &model.Building{
+		Model:      gorm.Model{ID: 1},
+		Occupants:  10,
+		Assemblies: []*model.Assembly{slab},
+		Floors: []*model.Floor{
+			{
+				Model: gorm.Model{ID: 1}, Level: 0, Area: 500, Use: model.UseResidential,
+				Zones: []*model.Zone{{Model: gorm.Model{ID: shopID}, Area: 200, Use: model.UseRetail}},
+				AssemblyLinks: []*model.FloorAssembly{
+					{AssemblyID: 10, Assembly: slab, Element: model.ElementUpperFloors, Quantity: 500},
+					{AssemblyID: 11, Assembly: fitOut, ZoneID: &shopID, Element: model.ElementFinishes, Quantity: 200},
+				},
+			},
+			{
+				Model: gorm.Model{ID: 2}, Level: 1, Area: 500, Use: model.UseResidential,
+				AssemblyLinks: []*model.FloorAssembly{
+					{AssemblyID: 10, Assembly: slab, Element: model.ElementUpperFloors, Quantity: 500},
+				},
+			},
+		},
+	}
+	building.PinMaterialVersions()
+
+	assert.InDelta(t, 2.0, building.ComputeSharedCarbon(), 1e-9)
+	assert.InDelta(t, 2.0+1000+1000+1000, building.ComputeWholeLifeCarbon(), 1e-9)
+	assert.InDelta(t, 1000.0, building.ComputeWholeLifeCarbonByElement()[model.ElementFinishes], 1e-9)
+
+	byUse := building.ComputeWholeLifeCarbonByUse()
+	assert.InDelta(t, 2000.0, byUse[model.UseResidential], 1e-9)
+	assert.InDelta(t, 1000.0, byUse[model.UseRetail], 1e-9)
+
+	areas := building.AreaByUse()
+	assert.InDelta(t, 800.0, areas[model.UseResidential], 1e-9)
+	assert.InDelta(t, 200.0, areas[model.UseRetail], 1e-9)
+
+	fs := service.NewFloorService(nil, &foundBuildingRepository{building: building}, nil, service.NewCalculationService())
+	result, err := fs.ComputeCarbonByUse(1, service.NormalisePerOccupant, "")
+	assert.NoError(t, err)
+	assert.InDelta(t, 300.2, result.Total, 1e-9)
+	assert.InDelta(t, 0.2, result.Shared, 1e-9)
+	assert.InDelta(t, 200.0, result.ByUse[0].Carbon, 1e-9)
+	assert.InDelta(t, 2.5, result.ByUse[0].CarbonPerArea, 1e-9)
+	assert.Equal(t, "occupant", result.Normalisation.Unit)
+
+	_, err = fs.ComputeCarbonByFloor(1, service.NormalisePerGFA, "")
+	assert.ErrorIs(t, err, service.ErrNoDenominator)
+}
+
+// fakeFloorRepository serves a single floor and records the floors it saves.
+type fakeFloorRepository struct {
+	repository.FloorRepository
+	floor *model.Floor
+	saved int
+}
+
+func (r *fakeFloorRepository) FindByID(id uint) (*model.Floor, error) {
+	if r.floor.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.floor, nil
+}
+
+func (r *fakeFloorRepository) Save(floor *model.Floor) error {
+	r.saved++
+	return nil
+}
+
+func (r *fakeFloorRepository) SaveAssemblyLink(link *model.FloorAssembly) error {
+	r.saved++
+	return nil
+}
+
+// TestUpdateFloorKeepsZones tests that a floor cannot shrink below the area
+// of its zones.
+func TestUpdateFloorKeepsZones(t *testing.T) {
+	floors := &fakeFloorRepository{floor: &model.Floor{
+		Model:      gorm.Model{ID: 2},
+		BuildingID: 1,
+		Area:       500,
+		Height:     4,
+		Use:        model.UseResidential,
+		Zones: []*model.Zone{
+			{FloorID: 2, Area: 200, Use: model.UseRetail},
+			{FloorID: 2, Area: 150, Use: model.UseOffice},
+		},
+	}}
+	floorService := service.NewFloorService(floors, nil, nil, service.NewCalculationService())
+	req := service.FloorRequest{Name: "Ground", Area: 300, Height: 4, Use: model.UseResidential}
+
+	_, err := floorService.UpdateFloor(1, 2, req)
+	assert.Error(t, err)
+	assert.Equal(t, 500.0, floors.floor.Area)
+	assert.Equal(t, 0, floors.saved)
+
+	req.Area = 350
+	floor, err := floorService.UpdateFloor(1, 2, req)
+	assert.NoError(t, err)
+	assert.Equal(t, 350.0, floor.Area)
+	assert.Equal(t, 1, floors.saved)
+}
+
+// TestAssignAssemblyQuantity tests that an assembly assigned without a
+// quantity is used once, while an explicit quantity of zero is kept.
+func TestAssignAssemblyQuantity(t *testing.T) {
+	floors := &fakeFloorRepository{floor: &model.Floor{Model: gorm.Model{ID: 2}, BuildingID: 1}}
+	assemblies := &fakeAssemblyRepository{assembly: &model.Assembly{Model: gorm.Model{ID: 10}}}
+	floorService := service.NewFloorService(floors, &pinnedBuildingRepository{}, assemblies, service.NewCalculationService())
+
+	link, err := floorService.AssignAssembly(1, 2, service.FloorAssemblyRequest{AssemblyID: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, link.Quantity)
+
+	zero := 0.0
+	link, err = floorService.AssignAssembly(1, 2, service.FloorAssemblyRequest{AssemblyID: 10, Quantity: &zero})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, link.Quantity)
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeMaterialRepository serves a single material.
+type fakeMaterialRepository struct {
+	repository.MaterialRepository
+	material *model.Material
+}
+
+func (r *fakeMaterialRepository) EagerFindByID(id uint) (*model.Material, error) {
+	if r.material.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.material, nil
+}
+
+// fakeBuildingRepository builds the buildings afresh on every lookup.
+type fakeBuildingRepository struct {
+	repository.BuildingRepository
+	newBuildings func() []model.Building
+	byAssembly   map[uint][]uint
+}
+
+func (r *fakeBuildingRepository) EagerFindByIDs(ids []uint) ([]model.Building, error) {
+	var found []model.Building
+	for _, building := range r.newBuildings() {
+		for _, id := range ids {
+			if building.ID == id {
+				found = append(found, building)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeBuildingRepository) FindIDsByAssemblies(assemblyIDs []uint) (map[uint][]uint, error) {
+	ids := make(map[uint][]uint)
+	for _, assemblyID := range assemblyIDs {
+		if buildingIDs, ok := r.byAssembly[assemblyID]; ok {
+			ids[assemblyID] = buildingIDs
+		}
+	}
+	return ids, nil
+}
+
+// fakeAssemblyTree serves assemblies, the materials they contain directly
+// and the assemblies they are sub-assemblies of.
+type fakeAssemblyTree struct {
+	repository.AssemblyRepository
+	assemblies map[uint]*model.Assembly
+	byMaterial map[uint][]uint
+	parents    map[uint][]uint
+}
+
+func (r *fakeAssemblyTree) FindByIDs(ids []uint) ([]model.Assembly, error) {
+	var found []model.Assembly
+	for _, id := range ids {
+		if assembly, ok := r.assemblies[id]; ok {
+			found = append(found, *assembly)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeAssemblyTree) FindIDsByMaterial(materialID uint) ([]uint, error) {
+	return r.byMaterial[materialID], nil
+}
+
+func (r *fakeAssemblyTree) FindParentIDsOf(childIDs []uint) ([]uint, error) {
+	var ids []uint
+	for _, childID := range childIDs {
+		ids = append(ids, r.parents[childID]...)
+	}
+	return ids, nil
+}
+
+// newMaterialUsageService returns a material service for a building using
+// 3 walls, each made of a frame of 2 kg steel (A1 10, renewed at 12) and
+// 4 kg timber (A1 5). The steel is only used inside the frame sub-assembly,
+// and the building is pinned to its first version.
+func newMaterialUsageService() service.MaterialService {
+	newBuildings := func() []model.Building {
+		frame := &model.Assembly{
+			Model: gorm.Model{ID: 3},
+			MaterialLinks: []*model.AssemblyMaterial{
+				{MaterialID: 1, Material: newVersionedMaterial(1, 10, 12), DeclaredQuantity: 2},
+				{MaterialID: 2, Material: newVersionedMaterial(2, 5), DeclaredQuantity: 4},
+			},
+		}
+		return []model.Building{{
+			Model: gorm.Model{ID: 7},
+			Name:  "Tower",
+			Assemblies: []*model.Assembly{{
+				Model:      gorm.Model{ID: 4},
+				Components: []*model.AssemblyComponent{{ParentID: 4, ChildID: 3, Child: frame, Quantity: 1}},
+			}},
+			AssemblyLinks: []*model.BuildingAssembly{{BuildingID: 7, AssemblyID: 4, Quantity: 3}},
+			MaterialPins:  []*model.MaterialPin{{BuildingID: 7, MaterialID: 1, MaterialVersionID: 100}},
+		}}
+	}
+	assemblies := &fakeAssemblyTree{
+		assemblies: map[uint]*model.Assembly{
+			3: {Model: gorm.Model{ID: 3}, Name: "Frame"},
+			4: {Model: gorm.Model{ID: 4}, Name: "Wall"},
+		},
+		byMaterial: map[uint][]uint{1: {3}, 2: {3}},
+		parents:    map[uint][]uint{3: {4}},
+	}
+	return service.NewMaterialService(
+		&fakeMaterialRepository{material: newVersionedMaterial(1, 10, 12)},
+		assemblies,
+		&fakeBuildingRepository{newBuildings: newBuildings, byAssembly: map[uint][]uint{4: {7}}},
+		service.NewCalculationService(),
+	)
+}
+
+// TestGetWhereUsed tests that buildings using the material in a sub-assembly
+// are found, and that the material's share of each building is calculated
+// with the version the building is pinned to.
+func TestGetWhereUsed(t *testing.T) {
+	usage, err := newMaterialUsageService().GetWhereUsed(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []service.AssemblyUsage{
+		{AssemblyID: 3, AssemblyName: "Frame", BuildingIDs: []uint{}},
+		{AssemblyID: 4, AssemblyName: "Wall", BuildingIDs: []uint{7}},
+	}, usage.Assemblies)
+	// 3 x (2 x 10 + 4 x 5) = 120, of which 3 x 2 x 10 = 60 is steel
+	assert.Equal(t, []service.BuildingUsage{{
+		BuildingID:     7,
+		BuildingName:   "Tower",
+		TotalCarbon:    120,
+		MaterialCarbon: 60,
+		Share:          0.5,
+	}}, usage.Buildings)
+}
+
+// TestDryRunMaterialChange tests that a proposed GWP is compared with the
+// building's pinned version without changing anything.
+func TestDryRunMaterialChange(t *testing.T) {
+	dryRun, err := newMaterialUsageService().DryRunMaterialChange(1, model.Gwp{A1: 15})
+
+	assert.NoError(t, err)
+	// 3 x (2 x 15 + 4 x 5) = 150
+	assert.Equal(t, []service.BuildingCarbonDelta{{
+		BuildingID:     7,
+		BuildingName:   "Tower",
+		PinnedVersion:  1,
+		CurrentCarbon:  120,
+		UpgradedCarbon: 150,
+		Delta:          30,
+	}}, dryRun.Buildings)
+}
+
+// TestPreviewMaterialUpgrade tests that buildings pinned to an older version
+// are compared with the latest version.
+func TestPreviewMaterialUpgrade(t *testing.T) {
+	upgrade, err := newMaterialUsageService().PreviewMaterialUpgrade(1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, upgrade.LatestVersion)
+	// 3 x (2 x 12 + 4 x 5) = 132
+	assert.Equal(t, []service.BuildingCarbonDelta{{
+		BuildingID:     7,
+		BuildingName:   "Tower",
+		PinnedVersion:  1,
+		CurrentCarbon:  120,
+		UpgradedCarbon: 132,
+		Delta:          12,
+	}}, upgrade.Buildings)
+}
+
+// savingMaterialRepository records the materials it saves.
+type savingMaterialRepository struct {
+	repository.MaterialRepository
+	saved []*model.Material
+}
+
+func (r *savingMaterialRepository) ExistsByMaterialName(name string) bool {
+	return false
+}
+
+func (r *savingMaterialRepository) Save(material *model.Material) error {
+	r.saved = append(r.saved, material)
+	return nil
+}
+
+// TestCreateMaterialDeclaredUnit tests that materials are declared per kg
+// unless the request declares another unit.
+func TestCreateMaterialDeclaredUnit(t *testing.T) {
+	repo := &savingMaterialRepository{}
+	materialService := service.NewMaterialService(repo, nil, nil, service.NewCalculationService())
+
+	material, err := materialService.CreateMaterial(service.CreateMaterialRequest{Name: "Steel"})
+	assert.NoError(t, err)
+	assert.Equal(t, "kg", material.DeclaredUnit)
+
+	material, err = materialService.CreateMaterial(service.CreateMaterialRequest{Name: "Concrete", DeclaredUnit: "m3"})
+	assert.NoError(t, err)
+	assert.Equal(t, "m3", material.DeclaredUnit)
+
+	_, err = materialService.CreateMaterial(service.CreateMaterialRequest{Name: "Glass", DeclaredUnit: "parsec"})
+	assert.Error(t, err)
+	assert.Len(t, repo.saved, 2)
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newVersionedMaterial creates a material with one version per A1 value given.
+func newVersionedMaterial(id uint, a1Values ...float64) *model.Material {
+	material := &model.Material{Model: gorm.Model{ID: id}}
+	for i, a1 := range a1Values {
+		material.Versions = append(material.Versions, &model.MaterialVersion{
+			Model:      gorm.Model{ID: id*100 + uint(i)},
+			MaterialID: id,
+			Version:    i + 1,
+			Gwp:        model.Gwp{A1: a1},
+		})
+	}
+	return material
+}
+
+// TestPinMaterialVersions tests that unpinned materials are pinned to their
+// latest version and pinned materials keep calculating with their pinned version.
+func TestPinMaterialVersions(t *testing.T) {
+	steel := newVersionedMaterial(1, 10, 12)
+	timber := newVersionedMaterial(2, 5, 4)
+	building := &model.Building{
+		Model: gorm.Model{ID: 7},
+		Assemblies: []*model.Assembly{{MaterialLinks: []*model.AssemblyMaterial{
+			{MaterialID: 1, Material: steel, DeclaredQuantity: 1},
+			{MaterialID: 2, Material: timber, DeclaredQuantity: 1},
+		}}},
+		MaterialPins: []*model.MaterialPin{{BuildingID: 7, MaterialID: 1, MaterialVersionID: 100}},
+	}
+
+	newPins := building.PinMaterialVersions()
+
+	assert.Len(t, newPins, 1)
+	assert.Equal(t, uint(2), newPins[0].MaterialID)
+	assert.Equal(t, uint(201), newPins[0].MaterialVersionID)
+	assert.Equal(t, 14.0, building.ComputeWholeLifeCarbon())
+
+	// a second calculation must not create any new pins
+	assert.Empty(t, building.PinMaterialVersions())
+
+	building.UseMaterialVersion(1, steel.LatestVersion())
+	assert.Equal(t, 16.0, building.ComputeWholeLifeCarbon())
+}
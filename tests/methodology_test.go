@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestMethodologyAdjust tests that a methodology's biogenic accounting and
+// uplift are applied to a copy of the material's values.
+func TestMethodologyAdjust(t *testing.T) {
+	timber := model.Gwp{A1: -500, A2: 10, A4: 20, C3: 300, C4: 250, D: -50, Biogenic: -600}
+
+	zeroZero := &model.Methodology{Scope: model.ScopeWholeLife, Biogenic: model.BiogenicZeroZero, Uplift: 0.1}
+	adjusted := zeroZero.Adjust(timber).(model.Gwp)
+	assert.InDelta(t, 110.0, adjusted.A1, 1e-9, "uptake removed from A1, then uplifted")
+	assert.InDelta(t, 11.0, adjusted.A2, 1e-9)
+	assert.InDelta(t, 22.0, adjusted.A4, 1e-9)
+	assert.InDelta(t, 0.0, adjusted.C3, 1e-9, "release removed from C3 first")
+	assert.InDelta(t, -50.0, adjusted.C4, 1e-9, "and the rest from C4")
+	assert.InDelta(t, -500.0, timber.A1, 1e-9, "original values are kept")
+
+	minusOnePlusOne := &model.Methodology{Scope: model.ScopeWholeLife, Biogenic: model.BiogenicMinusOnePlusOne}
+	assert.Equal(t, timber, minusOnePlusOne.Adjust(timber))
+}
+
+// TestMethodologies tests the built-in methodologies and their modules.
+func TestMethodologies(t *testing.T) {
+	for _, builtIn := range service.DefaultMethodologies() {
+		assert.NoError(t, service.RegisterMethodology(builtIn), builtIn.ID)
+	}
+
+	methodology, err := service.FindMethodology(service.DefaultMethodology)
+	assert.NoError(t, err)
+	assert.Equal(t, "EN 15978", methodology.Name)
+	assert.NotEmpty(t, methodology.Version)
+
+	rics, err := service.FindMethodology(service.MethodologyRICS2023)
+	assert.NoError(t, err)
+	assert.Equal(t, 60, rics.StudyPeriod)
+
+	_, err = service.FindMethodology("unknown")
+	assert.Error(t, err)
+
+	iso, err := service.FindMethodology(service.MethodologyISO21930)
+	assert.NoError(t, err)
+	modules, err := iso.Modules()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A1", "A2", "A3"}, modules)
+
+	withD := &model.Methodology{Scope: "A1-A3", IncludeD: true}
+	modules, err = withD.Modules()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A1", "A2", "A3", "D"}, modules)
+}
+
+// TestUseMethodologyOnce tests that a material shared by several links of a
+// building is adjusted by the methodology once.
+func TestUseMethodologyOnce(t *testing.T) {
+	steel := newVersionedMaterial(1, 100)
+	building := &model.Building{
+		Model: gorm.Model{ID: 1},
+		Assemblies: []*model.Assembly{{
+			Model: gorm.Model{ID: 10},
+			MaterialLinks: []*model.AssemblyMaterial{
+				{MaterialID: 1, Material: steel, DeclaredQuantity: 1},
+				{MaterialID: 1, Material: steel, DeclaredQuantity: 1},
+			},
+		}},
+		AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Quantity: 1}},
+	}
+	building.PinMaterialVersions()
+
+	building.UseMethodology(&model.Methodology{Scope: model.ScopeUpfront, Uplift: 0.1})
+	assert.InDelta(t, 220.0, building.CalculateCarbonForPhase("A1"), 1e-9)
+}
+
+// fixedCalculationService calculates every building to the same result.
+type fixedCalculationService struct {
+	service.CalculationService
+	total float64
+}
+
+func (cs *fixedCalculationService) ComputeBuildingCarbon(building *model.Building, methodology *model.Methodology) (*service.CarbonResult, error) {
+	return &service.CarbonResult{Total: cs.total, ByElement: []service.ElementCarbon{}, Methodology: methodology}, nil
+}
+
+// TestMethodologyRules tests that buildings are calculated by the calculation
+// service, and that the embodied estimate follows the methodology's scope.
+func TestMethodologyRules(t *testing.T) {
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.25,
+		AboveGroundFloorCount: 2,
+	}
+	runs := &memoryRunRepository{}
+	bs := service.NewBuildingService(&foundBuildingRepository{building: building}, nil, runs, &fixedCalculationService{total: 42})
+	result, err := bs.ComputeTotalCarbon(1, service.CalculationOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, result.Total)
+	assert.Equal(t, service.DefaultMethodology, result.Methodology.ID)
+
+	bs = service.NewBuildingService(&foundBuildingRepository{building: building}, nil, nil, service.NewCalculationService())
+	_, err = bs.ComputeEmbodiedCarbon(1, service.CalculationOptions{Methodology: service.MethodologyISO21930})
+	assert.ErrorIs(t, err, service.ErrPartialScope, "A1-A3 covers part of the upfront rates")
+
+	assert.NoError(t, service.RegisterMethodology(&model.Methodology{ID: "in-use", Scope: model.ScopeUseAndEndOfLife}))
+	result, err = bs.ComputeEmbodiedCarbon(1, service.CalculationOptions{Methodology: "in-use"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, result.Total)
+}
+
+// TestModulesAddUpToTotal tests that the life cycle modules of a result add up
+// to its total under every methodology, and that modules out of its scope are
+// not reported.
+func TestModulesAddUpToTotal(t *testing.T) {
+	newBuilding := func() *model.Building {
+		gwp := model.Gwp{A1: 1, A2: 1, A3: 1, A4: 1, A5: 1, B1: 1, B4: 2, B6: 4, C1: 1, C3: 1, C4: 1, D: -2, Biogenic: -1}
+		material := &model.Material{
+			Model:    gorm.Model{ID: 1},
+			Versions: []*model.MaterialVersion{{Model: gorm.Model{ID: 100}, MaterialID: 1, Version: 1, Gwp: gwp}},
+		}
+		building := &model.Building{
+			Model: gorm.Model{ID: 1},
+			Assemblies: []*model.Assembly{{
+				Model:         gorm.Model{ID: 10},
+				MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: material, DeclaredQuantity: 1}},
+			}},
+			AssemblyLinks: []*model.BuildingAssembly{{AssemblyID: 10, Quantity: 1}},
+		}
+		building.PinMaterialVersions()
+		return building
+	}
+
+	cs := service.NewCalculationService()
+	for _, methodology := range service.Methodologies() {
+		result, err := cs.ComputeBuildingCarbon(newBuilding(), methodology)
+		assert.NoError(t, err, methodology.ID)
+		var sum float64
+		for _, module := range result.ByModule {
+			sum += module.Carbon
+		}
+		assert.InDelta(t, result.Total, sum, 1e-9, methodology.ID)
+	}
+
+	iso, _ := service.FindMethodology(service.MethodologyISO21930)
+	result, err := cs.ComputeBuildingCarbon(newBuilding(), iso)
+	assert.NoError(t, err)
+	assert.Equal(t, []service.ModuleCarbon{{Module: model.ModuleA1toA5, Carbon: 3}}, result.ByModule)
+
+	withD := &model.Methodology{ID: "with-d", Scope: model.ScopeUpfront, IncludeD: true}
+	result, err = cs.ComputeBuildingCarbon(newBuilding(), withD)
+	assert.NoError(t, err)
+	assert.Len(t, result.ByModule, 2)
+	assert.Equal(t, "D", result.ByModule[1].Module)
+	assert.InDelta(t, 3.0, result.Total, 1e-9)
+}
+
+// TestResultsByMethodology tests that scenario, floor, use, snapshot,
+// benchmark and hotspot results are calculated by the requested methodology
+// and say which one, matching the building's total carbon by it.
+func TestResultsByMethodology(t *testing.T) {
+	upfront := &model.Methodology{ID: "upfront-uplift", Version: "1", Scope: model.ScopeUpfront, Uplift: 0.1}
+	assert.NoError(t, service.RegisterMethodology(upfront))
+
+	newBuilding := func() *model.Building {
+		gwp := model.Gwp{A1: 1, A4: 1, B4: 2, C3: 1}
+		material := &model.Material{
+			Model:    gorm.Model{ID: 1},
+			Versions: []*model.MaterialVersion{{Model: gorm.Model{ID: 100}, MaterialID: 1, Version: 1, Gwp: gwp}},
+		}
+		slab := &model.Assembly{
+			Model:         gorm.Model{ID: 10},
+			Name:          "Slab",
+			MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: material, DeclaredQuantity: 1}},
+		}
+		return &model.Building{
+			Model:                 gorm.Model{ID: 1},
+			Typology:              model.UseResidential,
+			FTF:                   3,
+			GroundFloorArea:       100,
+			AboveGroundFloorCount: 1,
+			Assemblies:            []*model.Assembly{slab},
+			AssemblyLinks:         []*model.BuildingAssembly{{BuildingID: 1, AssemblyID: 10, Quantity: 1}},
+			Floors: []*model.Floor{{
+				Model: gorm.Model{ID: 1}, Area: 100, Use: model.UseResidential,
+				AssemblyLinks: []*model.FloorAssembly{{AssemblyID: 10, Assembly: slab, Quantity: 10}},
+			}},
+		}
+	}
+	// 11 slabs of 2 kgCO2e upfront, uplifted by 10%
+	const total = 11 * 2 * 1.1
+	cs := service.NewCalculationService()
+
+	scenarios := &fakeScenarioRepository{scenarios: []model.Scenario{{Model: gorm.Model{ID: 5}}}}
+	ss := service.NewScenarioService(scenarios, &foundBuildingRepository{building: newBuilding()}, nil, cs)
+	comparison, err := ss.CompareScenarios(1, nil, "", upfront.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, upfront, comparison.Baseline.Methodology)
+	assert.InDelta(t, total, comparison.Baseline.Total, 1e-9)
+	// the scenario's materials are not uplifted twice
+	assert.Equal(t, upfront, comparison.Scenarios[0].Methodology)
+	assert.InDelta(t, total, comparison.Scenarios[0].Total, 1e-9)
+
+	fs := service.NewFloorService(nil, &foundBuildingRepository{building: newBuilding()}, nil, cs)
+	byFloor, err := fs.ComputeCarbonByFloor(1, "", upfront.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, upfront, byFloor.Methodology)
+	assert.InDelta(t, total, byFloor.Total, 1e-9)
+	assert.InDelta(t, 2.2, byFloor.Shared, 1e-9)
+	assert.InDelta(t, 22.0, byFloor.Floors[0].Carbon, 1e-9)
+	byUse, err := fs.ComputeCarbonByUse(1, "", upfront.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, upfront, byUse.Methodology)
+	assert.InDelta(t, 22.0, byUse.ByUse[0].Carbon, 1e-9)
+
+	snapshots := &fakeSnapshotRepository{}
+	sns := service.NewSnapshotService(snapshots, &foundBuildingRepository{building: newBuilding()}, cs)
+	snapshot, err := sns.CreateSnapshot(1, service.CreateSnapshotRequest{Stage: model.StageConceptDesign, Methodology: upfront.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, upfront.ID, snapshot.Methodology)
+	assert.InDelta(t, total, snapshot.TotalCarbon, 1e-9)
+	assert.InDelta(t, total, snapshot.FindAssembly(10).Carbon, 1e-9)
+	trajectory, err := sns.GetTrajectory(1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, upfront.ID, trajectory[0].Methodology)
+
+	sets := &fakeBenchmarkRepository{sets: []model.BenchmarkSet{{
+		Name:       "Upfront",
+		Benchmarks: []*model.Benchmark{{Typology: model.UseResidential, Scope: model.ScopeUpfront, Band: "A", Limit: 1}},
+	}}}
+	bms := service.NewBenchmarkService(sets, &foundBuildingRepository{building: newBuilding()}, cs)
+	rating, err := bms.RateBuilding(1, "", upfront.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, upfront, rating.Methodology)
+	assert.InDelta(t, total, rating.Ratings[0].Carbon, 1e-9)
+
+	bs := service.NewBuildingService(&foundBuildingRepository{building: newBuilding()}, nil, nil, cs)
+	hotspots, err := bs.ComputeHotspots(1, "", upfront.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, upfront, hotspots.Methodology)
+	assert.Equal(t, model.ScopeUpfront, hotspots.Scope)
+	assert.InDelta(t, total, hotspots.Total, 1e-9)
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"carbon-service/helpers"
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestScenarioApply tests that a scenario replaces assemblies and overrides
+// geometry without changing the building it applies to.
+func TestScenarioApply(t *testing.T) {
+	concrete := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 300), DeclaredQuantity: 1}},
+	}
+	timber := &model.Assembly{
+		Model:         gorm.Model{ID: 11},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 2, Material: newVersionedMaterial(2, 100), DeclaredQuantity: 1}},
+	}
+	glazing := &model.Assembly{
+		Model:         gorm.Model{ID: 12},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 3, Material: newVersionedMaterial(3, 10), DeclaredQuantity: 1}},
+	}
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		WWR:                   0.5,
+		AboveGroundFloorCount: 3,
+		Assemblies:            []*model.Assembly{concrete, glazing},
+		AssemblyLinks: []*model.BuildingAssembly{
+			{BuildingID: 1, AssemblyID: 10, Element: model.ElementFrame, Quantity: 2},
+			{BuildingID: 1, AssemblyID: 12, Element: model.ElementWindows, Role: model.RoleGlazing},
+		},
+	}
+	building.UpdateGeometry()
+	building.PinMaterialVersions()
+
+	wwr := 0.25
+	timberID := timber.ID
+	scenario := &model.Scenario{
+		WWR:       &wwr,
+		Overrides: []*model.ScenarioAssembly{{AssemblyID: 10, ReplacementID: &timberID, Replacement: timber}},
+	}
+	variant := scenario.Apply(building)
+	variant.PinMaterialVersions()
+
+	// 360 m2 of glazing in the building, 180 m2 in the scenario
+	assert.InDelta(t, 2*300.0+3600, building.ComputeWholeLifeCarbon(), 1e-9)
+	assert.InDelta(t, 2*100.0+1800, variant.ComputeWholeLifeCarbon(), 1e-9)
+	assert.InDelta(t, 200.0, variant.ComputeWholeLifeCarbonByElement()[model.ElementFrame], 1e-9)
+	assert.Equal(t, 0.5, building.WWR)
+	assert.Equal(t, uint(10), building.Assemblies[0].ID)
+
+	scenario.Overrides = []*model.ScenarioAssembly{{AssemblyID: 12, Removed: true}}
+	assert.InDelta(t, 2*300.0, scenario.Apply(building).ComputeWholeLifeCarbon(), 1e-9)
+}
+
+// foundBuildingRepository finds only the given building, deriving its areas
+// like a building loaded from the database.
+type foundBuildingRepository struct {
+	repository.BuildingRepository
+	building   *model.Building
+	eagerLoads int
+}
+
+func (r *foundBuildingRepository) FindByID(id uint) (*model.Building, error) {
+	if r.building.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	r.building.UpdateGeometry()
+	return r.building, nil
+}
+
+func (r *foundBuildingRepository) EagerFindByID(id uint) (*model.Building, error) {
+	r.eagerLoads++
+	return r.FindByID(id)
+}
+
+func (r *foundBuildingRepository) FindCalculationInputs(id uint) (*model.Building, map[uint]uint, error) {
+	building, err := r.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	latest := make(map[uint]uint)
+	for _, assembly := range building.Assemblies {
+		for _, link := range assembly.MaterialLinks {
+			if version := link.Material.LatestVersion(); version != nil {
+				latest[link.MaterialID] = version.ID
+			}
+		}
+	}
+	return building, latest, nil
+}
+
+// fakeScenarioRepository serves fixed scenarios, or fails with err.
+type fakeScenarioRepository struct {
+	repository.ScenarioRepository
+	scenarios []model.Scenario
+	err       error
+}
+
+func (r *fakeScenarioRepository) EagerFindByBuildingID(buildingID uint, scenarioIDs []uint) ([]model.Scenario, error) {
+	return r.scenarios, r.err
+}
+
+// TestCompareScenariosErrors tests that comparing scenarios tells a missing
+// building or scenario apart from a failure to load them.
+func TestCompareScenariosErrors(t *testing.T) {
+	buildings := &foundBuildingRepository{building: &model.Building{Model: gorm.Model{ID: 1}}}
+	scenarios := &fakeScenarioRepository{}
+	ss := service.NewScenarioService(scenarios, buildings, nil, nil)
+
+	_, err := ss.CompareScenarios(2, nil, "", "")
+	assert.Equal(t, http.StatusNotFound, helpers.ErrorStatus(err))
+
+	_, err = ss.CompareScenarios(1, []uint{5}, "", "")
+	assert.Equal(t, http.StatusNotFound, helpers.ErrorStatus(err))
+
+	scenarios.err = errors.New("connection refused")
+	_, err = ss.CompareScenarios(1, nil, "", "")
+	assert.Equal(t, http.StatusInternalServerError, helpers.ErrorStatus(err))
+}
+
+// TestCompareScenariosNormalised tests that the building and each scenario
+// are normalised by their own GFA before they are compared.
+func TestCompareScenariosNormalised(t *testing.T) {
+	frame := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 300), DeclaredQuantity: 1}},
+	}
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		AboveGroundFloorCount: 3,
+		Assemblies:            []*model.Assembly{frame},
+		AssemblyLinks:         []*model.BuildingAssembly{{BuildingID: 1, AssemblyID: 10, Element: model.ElementFrame, Quantity: 4}},
+	}
+	floors := 4
+	scenarios := &fakeScenarioRepository{scenarios: []model.Scenario{{Model: gorm.Model{ID: 5}, AboveGroundFloorCount: &floors}}}
+	ss := service.NewScenarioService(scenarios, &foundBuildingRepository{building: building}, nil, service.NewCalculationService())
+
+	comparison, err := ss.CompareScenarios(1, nil, service.NormalisePerGFA, "")
+	assert.NoError(t, err)
+	assert.InDelta(t, 600.0, comparison.Baseline.Normalisation.Value, 1e-9)
+	assert.InDelta(t, 2.0, comparison.Baseline.Total, 1e-9)
+	assert.InDelta(t, 800.0, comparison.Scenarios[0].Normalisation.Value, 1e-9)
+	assert.InDelta(t, 1.5, comparison.Scenarios[0].Total, 1e-9)
+	assert.InDelta(t, -0.5, comparison.Scenarios[0].Delta.Total, 1e-9)
+
+	_, err = ss.CompareScenarios(1, nil, service.NormalisePerOccupant, "")
+	assert.ErrorIs(t, err, service.ErrNoDenominator)
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"carbon-service/model"
+	"carbon-service/repository"
+	"carbon-service/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestNewSnapshot tests that a snapshot records the building's parameters,
+// state and results, and keeps them when the building changes afterwards.
+func TestNewSnapshot(t *testing.T) {
+	frame := &model.Assembly{
+		Model:         gorm.Model{ID: 10},
+		Name:          "Concrete frame",
+		MaterialLinks: []*model.AssemblyMaterial{{MaterialID: 1, Material: newVersionedMaterial(1, 300), DeclaredQuantity: 1}},
+	}
+	building := &model.Building{
+		Model:                 gorm.Model{ID: 1},
+		FTF:                   4,
+		GroundFloorArea:       200,
+		AboveGroundFloorCount: 3,
+		Assemblies:            []*model.Assembly{frame},
+		AssemblyLinks:         []*model.BuildingAssembly{{BuildingID: 1, AssemblyID: 10, Element: model.ElementFrame, Quantity: 2}},
+	}
+	building.UpdateGeometry()
+	building.PinMaterialVersions()
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	methodology := &model.Methodology{ID: "whole-life", Version: "1", Scope: model.ScopeWholeLife}
+	snapshot, err := model.NewSnapshot(building, methodology, model.StageConceptDesign, date, "Stage 2 report")
+	assert.NoError(t, err)
+	assert.Equal(t, "Concept Design", snapshot.Stage.Name())
+	assert.Equal(t, 600.0, snapshot.GFA)
+	assert.Equal(t, "whole-life", snapshot.Methodology)
+	assert.Equal(t, 2.0, snapshot.FindAssembly(10).Quantity)
+	assert.Equal(t, 600.0, snapshot.FindAssembly(10).Carbon)
+	assert.Contains(t, string(snapshot.State), `"Name":"Concrete frame"`)
+
+	building.AssemblyLinks[0].Quantity = 3
+	assert.InDelta(t, 900.0, building.ComputeWholeLifeCarbon(), 1e-9)
+
+	// the recorded state still calculates to the recorded results
+	recorded, err := snapshot.Restore()
+	assert.NoError(t, err)
+	assert.Equal(t, 600.0, recorded.GFA)
+	assert.Equal(t, 2.0, recorded.AssemblyLinks[0].Quantity)
+	assert.InDelta(t, snapshot.FindAssembly(10).Carbon, recorded.ComputeWholeLifeCarbon(), 1e-9)
+}
+
+// fakeSnapshotRepository serves fixed snapshots of a building and records
+// those it creates.
+type fakeSnapshotRepository struct {
+	repository.SnapshotRepository
+	snapshots []model.Snapshot
+}
+
+func (r *fakeSnapshotRepository) Create(snapshot *model.Snapshot) error {
+	r.snapshots = append(r.snapshots, *snapshot)
+	return nil
+}
+
+func (r *fakeSnapshotRepository) FindByBuildingID(buildingID uint) ([]model.Snapshot, error) {
+	return r.snapshots, nil
+}
+
+// TestTrajectoryNormalised tests that each snapshot is normalised by the
+// building as it was recorded, not as it is now.
+func TestTrajectoryNormalised(t *testing.T) {
+	snapshots := &fakeSnapshotRepository{snapshots: []model.Snapshot{
+		{
+			Model: gorm.Model{ID: 1}, GroundFloorArea: 200, AboveGroundFloorCount: 3, TotalCarbon: 600,
+			Elements: []*model.SnapshotElement{{Element: model.ElementFrame, Carbon: 600}},
+		},
+		{
+			Model: gorm.Model{ID: 2}, GroundFloorArea: 200, AboveGroundFloorCount: 4, TotalCarbon: 1200,
+			Elements: []*model.SnapshotElement{{Element: model.ElementFrame, Carbon: 1200}},
+		},
+	}}
+	ss := service.NewSnapshotService(snapshots, nil, nil)
+
+	trajectory, err := ss.GetTrajectory(1, service.NormalisePerGFA)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0, trajectory[0].TotalCarbon, 1e-9)
+	assert.InDelta(t, 1.5, trajectory[1].TotalCarbon, 1e-9)
+	assert.InDelta(t, 1.5, trajectory[1].ByElement[0].Carbon, 1e-9)
+	assert.InDelta(t, 800.0, trajectory[1].Normalisation.Value, 1e-9)
+
+	_, err = ss.GetTrajectory(1, service.NormalisePerBedroom)
+	assert.ErrorIs(t, err, service.ErrNoDenominator)
+}